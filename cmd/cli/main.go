@@ -4,14 +4,19 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"video-downloader/internal/comment"
 	"video-downloader/internal/config"
 	"video-downloader/internal/downloader"
+	"video-downloader/internal/export"
+	"video-downloader/internal/registry"
 	"video-downloader/internal/server"
 	"video-downloader/internal/storage"
 	"video-downloader/internal/utils"
@@ -23,8 +28,18 @@ var (
 	outputPath string
 	format     string
 	quality    string
+	metadata   bool
+	sidecars   bool
+	music      string
+	fileNaming string
 	verbose    bool
 	cookies    string
+
+	commentLimit   int
+	commentReplies bool
+	commentSort    string
+	commentFormat  string
+	commentReport  string
 )
 
 var rootCmd = &cobra.Command{
@@ -85,29 +100,43 @@ var downloadCmd = &cobra.Command{
 		}
 		defer storage.Close()
 
+		// One-shot commands leave queued server downloads alone
+		cfg.Download.ResumeQueue = false
+
 		// Create download manager
-		dm := downloader.NewManager(cfg, storage)
+		dm := downloader.NewManager(cfg, storage, utils.NewTransportFactory(cfg))
 		if err := dm.Start(); err != nil {
 			return fmt.Errorf("error starting download manager: %w", err)
 		}
 		defer dm.Stop()
 
-		// Set up signal handling
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		// Set up signal handling so Ctrl+C aborts the download
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
 
 		// Download options
 		options := &downloader.DownloadOptions{
+			Priority:   downloader.PriorityInteractive,
 			OutputPath: outputPath,
 			Format:     format,
 			Quality:    quality,
+			Metadata:   metadata,
+			Sidecars:   sidecars,
+			Music:      downloader.MusicMode(music),
+			FileNaming: fileNaming,
 			Progress:   true,
 		}
 
 		// Start download
 		fmt.Printf("Downloading video from: %s\n", url)
-		result, err := dm.Download(url, options)
+		taskID, err := dm.Download(ctx, url, options)
+		if err != nil {
+			return fmt.Errorf("error downloading video: %w", err)
+		}
+
+		result, err := dm.Wait(ctx, taskID)
 		if err != nil {
+			dm.CancelDownload(taskID)
 			return fmt.Errorf("error downloading video: %w", err)
 		}
 
@@ -157,8 +186,11 @@ var batchCmd = &cobra.Command{
 		}
 		defer storage.Close()
 
+		// One-shot commands leave queued server downloads alone
+		cfg.Download.ResumeQueue = false
+
 		// Create download manager
-		dm := downloader.NewManager(cfg, storage)
+		dm := downloader.NewManager(cfg, storage, utils.NewTransportFactory(cfg))
 		if err := dm.Start(); err != nil {
 			return fmt.Errorf("error starting download manager: %w", err)
 		}
@@ -166,30 +198,52 @@ var batchCmd = &cobra.Command{
 
 		// Download options
 		options := &downloader.DownloadOptions{
+			Priority:   downloader.PriorityBulk,
 			OutputPath: outputPath,
 			Format:     format,
 			Quality:    quality,
+			Metadata:   metadata,
+			Sidecars:   sidecars,
+			Music:      downloader.MusicMode(music),
+			FileNaming: fileNaming,
 			Progress:   true,
 		}
 
+		// Set up signal handling so Ctrl+C aborts the batch
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
 		// Batch download
-		results, err := dm.DownloadBatch(urls, options)
+		taskIDs, err := dm.DownloadBatch(ctx, urls, options)
 		if err != nil {
 			fmt.Printf("⚠️  Some downloads failed: %v\n", err)
 		}
 
-		// Print results
+		// Wait for each task and print results
 		success := 0
 		failed := 0
-		for _, result := range results {
-			if result != nil && result.Success {
+		for _, taskID := range taskIDs {
+			if taskID == "" {
+				failed++
+				continue
+			}
+
+			result, err := dm.Wait(ctx, taskID)
+			if err != nil {
+				for _, id := range taskIDs {
+					if id != "" {
+						dm.CancelDownload(id)
+					}
+				}
+				return fmt.Errorf("error waiting for downloads: %w", err)
+			}
+
+			if result.Success {
 				success++
 				fmt.Printf("✅ %s\n", result.Video.FilePath)
 			} else {
 				failed++
-				if result != nil {
-					fmt.Printf("❌ Failed: %v\n", result.Error)
-				}
+				fmt.Printf("❌ Failed: %v\n", result.Error)
 			}
 		}
 
@@ -219,15 +273,18 @@ var infoCmd = &cobra.Command{
 		}
 		defer storage.Close()
 
+		// One-shot commands leave queued server downloads alone
+		cfg.Download.ResumeQueue = false
+
 		// Create download manager
-		dm := downloader.NewManager(cfg, storage)
+		dm := downloader.NewManager(cfg, storage, utils.NewTransportFactory(cfg))
 		if err := dm.Start(); err != nil {
 			return fmt.Errorf("error starting download manager: %w", err)
 		}
 		defer dm.Stop()
 
 		// Get video info
-		videoInfo, err := dm.GetVideoInfo(url)
+		videoInfo, err := dm.GetVideoInfo(cmd.Context(), url)
 		if err != nil {
 			return fmt.Errorf("error getting video info: %w", err)
 		}
@@ -248,6 +305,188 @@ var infoCmd = &cobra.Command{
 		fmt.Printf("   Published: %s\n", videoInfo.PublishedAt.Format("2006-01-02 15:04:05"))
 		fmt.Printf("   URL: %s\n", videoInfo.URL)
 
+		if len(videoInfo.Renditions) > 0 {
+			printRenditions(videoInfo.Renditions)
+		}
+
+		return nil
+	},
+}
+
+// printRenditions lists the available qualities, best first, marking the
+// one --quality selects
+func printRenditions(renditions []models.Rendition) {
+	var selected *models.Rendition
+	if q, err := downloader.ParseQuality(quality); err == nil {
+		selected, _ = downloader.SelectRendition(renditions, q)
+	}
+
+	fmt.Printf("\n🎞️  Available Qualities\n")
+	for _, r := range downloader.RankRenditions(renditions) {
+		mark := " "
+		if selected != nil && r.URL == selected.URL {
+			mark = "*"
+		}
+
+		details := []string{}
+		if r.Width > 0 && r.Height > 0 {
+			details = append(details, fmt.Sprintf("%dx%d", r.Width, r.Height))
+		}
+		if r.Bitrate > 0 {
+			details = append(details, fmt.Sprintf("%d kbps", r.Bitrate/1000))
+		}
+		if r.Codec != "" {
+			details = append(details, r.Codec)
+		}
+		if r.Size > 0 {
+			details = append(details, utils.FormatBytes(r.Size))
+		}
+		if r.Watermark {
+			details = append(details, "watermark")
+		}
+		if r.Label != "" {
+			details = append(details, "("+r.Label+")")
+		}
+
+		fmt.Printf("  %s %s\n", mark, strings.Join(details, "  "))
+	}
+}
+
+var commentsCmd = &cobra.Command{
+	Use:   "comments [url]",
+	Short: "Export the comments of a video",
+	Long: `Fetch the comments of a video and export them as json, csv or txt
+(--output-format, json by default) into the output directory. The comments are
+also stored in the database. Each thread is stored and written as it arrives,
+so a failure part way keeps what was fetched; --sort and --report hold the
+threads in memory until the end.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url := args[0]
+
+		if !slices.Contains(comment.ExportFormats(), commentFormat) {
+			return fmt.Errorf("unsupported output format: %s", commentFormat)
+		}
+		if !comment.ValidSort(commentSort) {
+			return fmt.Errorf("unsupported sort: %s", commentSort)
+		}
+		if commentReport != "" && !slices.Contains(export.GetSupportedFormats(), export.ExportFormat(commentReport)) {
+			return fmt.Errorf("unsupported report format: %s", commentReport)
+		}
+
+		// Load configuration
+		configManager := config.NewManager()
+		cfg, err := configManager.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		// Initialize storage
+		storage, err := storage.NewSQLite(cfg.Database.Path)
+		if err != nil {
+			return fmt.Errorf("error initializing storage: %w", err)
+		}
+		defer storage.Close()
+
+		// Resolve the share URL to its video
+		transports := utils.NewTransportFactory(cfg)
+		reg := registry.NewRegistry()
+		if err := reg.RegisterDefaultPlatforms(cfg, transports); err != nil {
+			return fmt.Errorf("error registering platforms: %w", err)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		video, err := reg.ExtractVideoInfo(ctx, url)
+		if err != nil {
+			return fmt.Errorf("error getting video info: %w", err)
+		}
+
+		// Keep the record of a video downloaded earlier
+		stored, err := storage.GetVideoInfo(video.ID)
+		if err != nil {
+			return fmt.Errorf("error reading video: %w", err)
+		}
+		if stored == nil {
+			if err := storage.SaveVideoInfo(video); err != nil {
+				return fmt.Errorf("error saving video: %w", err)
+			}
+		}
+
+		// Extract comments
+		commentConfig := comment.ConfigForVideo(cfg, video)
+		commentConfig.Limit = commentLimit
+		commentConfig.IncludeReplies = commentReplies
+		commentConfig.SortBy = commentSort
+		if cookies != "" {
+			commentConfig.Cookie = cookies
+		}
+
+		// Export comments as they are stored
+		dir := outputPath
+		if dir == "" {
+			dir = cfg.Download.SavePath
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s_comments.%s", video.Platform, video.ID, commentFormat))
+		writer, err := comment.NewCommentWriter(commentFormat, path)
+		if err != nil {
+			return fmt.Errorf("error exporting comments: %w", err)
+		}
+
+		// Sorting and the report need every thread
+		var threads []*comment.CommentThread
+		ce := comment.NewCommentExtractor(transports.Transport())
+		result, extractErr := ce.StoreEach(ctx, commentConfig, storage, func(thread *comment.CommentThread) error {
+			if commentSort != "" || commentReport != "" {
+				threads = append(threads, thread)
+			}
+			if commentSort != "" {
+				return nil
+			}
+			return writer.Write(thread)
+		})
+
+		if commentSort != "" {
+			comment.SortThreads(threads, commentSort)
+			for _, thread := range threads {
+				if err := writer.Write(thread); err != nil {
+					break
+				}
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("error exporting comments: %w", err)
+		}
+
+		if result.Threads == 0 && extractErr != nil {
+			os.Remove(path)
+			return fmt.Errorf("error extracting comments: %w", extractErr)
+		}
+
+		fmt.Printf("💬 %d comments and replies from %s\n", result.Comments, video.Title)
+		fmt.Printf("   Threads: %d | New: %d\n", result.Threads, result.Added)
+		fmt.Printf("   Exported: %s\n", path)
+
+		if extractErr != nil {
+			return fmt.Errorf("error extracting comments, kept the %d threads stored: %w", result.Threads, extractErr)
+		}
+
+		// Export the analytics report
+		if commentReport != "" {
+			report := ce.GetCommentStats(threads)
+			reportPath := filepath.Join(dir, fmt.Sprintf("%s_%s_report.%s", video.Platform, video.ID, commentReport))
+			exporter := export.NewDataExporter(export.ExportConfig{
+				Format:   export.ExportFormat(commentReport),
+				FilePath: reportPath,
+			})
+			if err := exporter.ExportCommentReport(report); err != nil {
+				return fmt.Errorf("error exporting comment report: %w", err)
+			}
+
+			fmt.Printf("   Report: %s (sentiment %.2f)\n", reportPath, report.Sentiment.Score)
+		}
+
 		return nil
 	},
 }
@@ -300,6 +539,37 @@ var listCmd = &cobra.Command{
 	},
 }
 
+var importCmd = &cobra.Command{
+	Use:   "import [dir]",
+	Short: "Import videos from .info.json sidecar files",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Load configuration
+		configManager := config.NewManager()
+		cfg, err := configManager.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		// Initialize storage
+		storage, err := storage.NewSQLite(cfg.Database.Path)
+		if err != nil {
+			return fmt.Errorf("error initializing storage: %w", err)
+		}
+		defer storage.Close()
+
+		dm := downloader.NewManager(cfg, storage, utils.NewTransportFactory(cfg))
+
+		imported, err := dm.ImportSidecars(args[0])
+		fmt.Printf("📥 Imported %d videos from %s\n", imported, args[0])
+		if err != nil {
+			return fmt.Errorf("some sidecars could not be imported: %w", err)
+		}
+
+		return nil
+	},
+}
+
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start the API server",
@@ -319,7 +589,7 @@ var serverCmd = &cobra.Command{
 		defer storage.Close()
 
 		// Create and start server
-		srv := server.NewServer(cfg, storage)
+		srv := server.NewServer(cfg, storage, utils.NewTransportFactory(cfg))
 		if err := srv.Run(); err != nil {
 			return fmt.Errorf("error running server: %w", err)
 		}
@@ -379,15 +649,29 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "Configuration file path")
 	rootCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "Output directory")
 	rootCmd.PersistentFlags().StringVarP(&format, "format", "f", "", "Output format (mp4, mp3, etc.)")
-	rootCmd.PersistentFlags().StringVarP(&quality, "quality", "q", "", "Video quality (hd, sd, etc.)")
+	rootCmd.PersistentFlags().StringVarP(&quality, "quality", "q", "", "Video quality: best, worst, 1080p, h265, no-watermark or a comma-separated mix")
+	rootCmd.PersistentFlags().BoolVar(&metadata, "metadata", false, "Embed title, author and cover art into downloaded files")
+	rootCmd.PersistentFlags().BoolVar(&sidecars, "sidecars", false, "Write .info.json, .jpg and .description files next to downloads")
+	rootCmd.PersistentFlags().StringVar(&music, "music", "", "Download the soundtrack: 'with' the video or 'only' the soundtrack (--music means with)")
+	rootCmd.PersistentFlags().Lookup("music").NoOptDefVal = string(downloader.MusicWith)
+	rootCmd.PersistentFlags().StringVar(&fileNaming, "file-naming", "", "Output path template, e.g. '{platform}/{author_name}/{title}_{id}.{ext}'")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 	rootCmd.PersistentFlags().StringVar(&cookies, "cookies", "", "Browser cookies for authentication (format: 'key1=value1; key2=value2')")
 
+	// Comments flags
+	commentsCmd.Flags().IntVarP(&commentLimit, "limit", "n", 0, "Top-level comments to fetch, 0 for all")
+	commentsCmd.Flags().BoolVar(&commentReplies, "replies", false, "Include all replies to each comment")
+	commentsCmd.Flags().StringVar(&commentSort, "sort", "", "Order comments by 'time' (newest first) or 'popularity' (most liked first)")
+	commentsCmd.Flags().StringVar(&commentFormat, "output-format", "json", "Export comments as json, csv or txt")
+	commentsCmd.Flags().StringVar(&commentReport, "report", "", "Also export a keyword, mention, emoji and sentiment report as csv, xlsx, json or txt")
+
 	// Add commands
 	rootCmd.AddCommand(downloadCmd)
 	rootCmd.AddCommand(batchCmd)
 	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(commentsCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(configCmd)
 
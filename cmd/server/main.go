@@ -7,6 +7,7 @@ import (
 	"video-downloader/internal/config"
 	"video-downloader/internal/server"
 	"video-downloader/internal/storage"
+	"video-downloader/internal/utils"
 )
 
 func main() {
@@ -28,7 +29,7 @@ func main() {
 	defer storage.Close()
 
 	// Create and run server
-	srv := server.NewServer(cfg, storage)
+	srv := server.NewServer(cfg, storage, utils.NewTransportFactory(cfg))
 	if err := srv.Run(); err != nil {
 		log.Fatal().Err(err).Msg("Error running server")
 	}
@@ -2,16 +2,18 @@ package batch
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 
 	"video-downloader/internal/downloader"
-	"video-downloader/internal/platform"
 	"video-downloader/internal/registry"
+	"video-downloader/internal/utils"
 	"video-downloader/pkg/models"
 )
 
@@ -19,25 +21,55 @@ import (
 type BatchManager struct {
 	registry      *registry.Registry
 	downloader    *downloader.Manager
+	storage       models.Storage
 	logger        zerolog.Logger
 	maxConcurrent int
 	semaphore     chan struct{}
 	workers       sync.WaitGroup
+	jobs          map[string]*BatchJob
+	jobsMutex     sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
 }
 
 // BatchDownloadConfig holds configuration for batch downloads
 type BatchDownloadConfig struct {
-	MaxConcurrent int
-	OutputPath    string
-	Format        string
-	Quality       string
-	SkipExisting  bool
-	RetryFailed   bool
+	MaxConcurrent int                  `json:"max_concurrent,omitempty"`
+	OutputPath    string               `json:"output_path,omitempty"`
+	Format        string               `json:"format,omitempty"`
+	Quality       string               `json:"quality,omitempty"`
+	Metadata      bool                 `json:"metadata,omitempty"`
+	Sidecars      bool                 `json:"sidecars,omitempty"`
+	Music         downloader.MusicMode `json:"music,omitempty"`
+	FileNaming    string               `json:"file_naming,omitempty"`
+	SkipExisting  bool                 `json:"skip_existing,omitempty"`
+	RetryFailed   bool                 `json:"retry_failed,omitempty"`
+	// Priority is the queue lane of the job's downloads, bulk if empty
+	Priority downloader.Priority `json:"priority,omitempty"`
 }
 
-// BatchJob represents a batch download job
+// downloadOptions returns the options each item of a job is queued with
+func (c BatchDownloadConfig) downloadOptions(batchID string) *downloader.DownloadOptions {
+	priority := c.Priority
+	if priority == "" {
+		priority = downloader.PriorityBulk
+	}
+
+	return &downloader.DownloadOptions{
+		Priority:   priority,
+		BatchID:    batchID,
+		OutputPath: c.OutputPath,
+		Format:     c.Format,
+		Quality:    c.Quality,
+		Metadata:   c.Metadata,
+		Sidecars:   c.Sidecars,
+		Music:      c.Music,
+		FileNaming: c.FileNaming,
+	}
+}
+
+// BatchJob represents a batch download job. While the job runs its
+// fields are guarded by the job mutex; use Info for a consistent copy.
 type BatchJob struct {
 	ID          string
 	Type        BatchJobType
@@ -49,6 +81,23 @@ type BatchJob struct {
 	StartedAt   time.Time
 	CompletedAt *time.Time
 	Error       error
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mutex       sync.RWMutex
+}
+
+// BatchJobInfo is a point-in-time view of a batch job
+type BatchJobInfo struct {
+	ID          string              `json:"id"`
+	Type        BatchJobType        `json:"type"`
+	URLs        []string            `json:"urls"`
+	Config      BatchDownloadConfig `json:"config"`
+	Status      JobStatus           `json:"status"`
+	Progress    BatchProgress       `json:"progress"`
+	Results     []BatchResult       `json:"results,omitempty"`
+	StartedAt   time.Time           `json:"started_at"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+	Error       string              `json:"error,omitempty"`
 }
 
 // BatchJobType represents the type of batch job
@@ -74,23 +123,56 @@ const (
 
 // BatchProgress tracks progress of a batch job
 type BatchProgress struct {
-	Total      int
-	Completed  int
-	Failed     int
-	Skipped    int
-	InProgress int
-	Percentage float64
+	Total      int     `json:"total"`
+	Completed  int     `json:"completed"`
+	Failed     int     `json:"failed"`
+	Skipped    int     `json:"skipped"`
+	InProgress int     `json:"in_progress"`
+	Percentage float64 `json:"percentage"`
 }
 
 // BatchResult represents the result of a single item in batch
 type BatchResult struct {
-	URL       string
-	VideoInfo *models.VideoInfo
-	Status    string
-	Error     error
-	FilePath  string
-	Size      int64
-	Duration  time.Duration
+	URL       string            `json:"url"`
+	TaskID    string            `json:"task_id,omitempty"`
+	VideoInfo *models.VideoInfo `json:"video,omitempty"`
+	Status    string            `json:"status"`
+	Error     error             `json:"-"`
+	FilePath  string            `json:"file_path,omitempty"`
+	Size      int64             `json:"size,omitempty"`
+	Duration  time.Duration     `json:"duration"`
+}
+
+// batchResultAlias has the fields of BatchResult without its methods
+type batchResultAlias BatchResult
+
+// batchResultJSON is the wire form of BatchResult, with the error as text
+type batchResultJSON struct {
+	batchResultAlias
+	Error string `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the result with its error message
+func (r BatchResult) MarshalJSON() ([]byte, error) {
+	out := batchResultJSON{batchResultAlias: batchResultAlias(r)}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a result written by MarshalJSON
+func (r *BatchResult) UnmarshalJSON(data []byte) error {
+	var in batchResultJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*r = BatchResult(in.batchResultAlias)
+	if in.Error != "" {
+		r.Error = errors.New(in.Error)
+	}
+	return nil
 }
 
 // NewBatchManager creates a new batch manager
@@ -103,15 +185,111 @@ func NewBatchManager(reg *registry.Registry, dm *downloader.Manager, maxConcurre
 		logger:        zerolog.New(nil).With().Str("component", "batch_manager").Logger(),
 		maxConcurrent: maxConcurrent,
 		semaphore:     make(chan struct{}, maxConcurrent),
+		jobs:          make(map[string]*BatchJob),
 		ctx:           ctx,
 		cancel:        cancel,
 	}
 }
 
+// SetStorage sets the storage used to persist jobs
+func (bm *BatchManager) SetStorage(storage models.Storage) {
+	bm.storage = storage
+}
+
+// RestoreJobs marks jobs that were still running when the process last
+// stopped as failed, so they can be retried
+func (bm *BatchManager) RestoreJobs() error {
+	if bm.storage == nil {
+		return nil
+	}
+
+	records, err := bm.storage.ListBatchJobs(0, 0)
+	if err != nil {
+		return fmt.Errorf("error loading batch jobs: %w", err)
+	}
+
+	for _, record := range records {
+		if record.Status != string(JobStatusPending) && record.Status != string(JobStatusRunning) {
+			continue
+		}
+
+		record.Status = string(JobStatusFailed)
+		record.Error = "interrupted by restart"
+		if err := bm.storage.SaveBatchJob(record); err != nil {
+			return fmt.Errorf("error saving batch job %s: %w", record.ID, err)
+		}
+	}
+
+	return nil
+}
+
 // StartBatchDownload starts a batch download job
 func (bm *BatchManager) StartBatchDownload(jobType BatchJobType, urls []string, config BatchDownloadConfig) (*BatchJob, error) {
+	job, err := bm.newJob(jobType, urls, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start the job asynchronously
+	bm.runJob(job, func() { bm.processBatchJob(job) })
+
+	return job, nil
+}
+
+// StartVideoBatch starts a job downloading videos that were already
+// extracted, such as the new posts found on a profile, without extracting
+// them again. It is a URL list job of the videos' page URLs, so a retry
+// works as for any other URL list.
+func (bm *BatchManager) StartVideoBatch(videos []*models.VideoInfo, config BatchDownloadConfig) (*BatchJob, error) {
+	urls := make([]string, 0, len(videos))
+	for _, video := range videos {
+		urls = append(urls, video.URL)
+	}
+
+	job, err := bm.newJob(BatchJobTypeURLList, urls, config)
+	if err != nil {
+		return nil, err
+	}
+
+	bm.runJob(job, func() { bm.downloadVideos(job, videos) })
+
+	return job, nil
+}
+
+// newJob validates a job's config and registers it as pending
+func (bm *BatchManager) newJob(jobType BatchJobType, urls []string, config BatchDownloadConfig) (*BatchJob, error) {
+	switch jobType {
+	case BatchJobTypeUserProfile, BatchJobTypePlaylist, BatchJobTypeURLList:
+	default:
+		return nil, fmt.Errorf("unsupported job type: %s", jobType)
+	}
+
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no URLs given")
+	}
+
+	if _, err := downloader.ParseQuality(config.Quality); err != nil {
+		return nil, err
+	}
+
+	if _, err := downloader.ParseMusicMode(string(config.Music)); err != nil {
+		return nil, err
+	}
+
+	if config.Priority != "" {
+		if _, err := downloader.ParsePriority(string(config.Priority)); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.FileNaming != "" {
+		if _, err := utils.ParsePathTemplate(config.FileNaming); err != nil {
+			return nil, err
+		}
+	}
+
 	job := &BatchJob{
-		ID:        fmt.Sprintf("batch_%d", time.Now().Unix()),
+		ID:        fmt.Sprintf("batch_%d", time.Now().UnixNano()),
 		Type:      jobType,
 		URLs:      urls,
 		Config:    config,
@@ -121,24 +299,55 @@ func (bm *BatchManager) StartBatchDownload(jobType BatchJobType, urls []string,
 		StartedAt: time.Now(),
 	}
 
-	// Start the job asynchronously
-	go bm.processBatchJob(job)
+	bm.jobsMutex.Lock()
+	bm.jobs[job.ID] = job
+	bm.jobsMutex.Unlock()
 
 	return job, nil
 }
 
+// runJob marks the job running and calls fn in the background. Each run
+// gets its own context so the job can be cancelled independently.
+func (bm *BatchManager) runJob(job *BatchJob, fn func()) {
+	job.mutex.Lock()
+	job.ctx, job.cancel = context.WithCancel(bm.ctx)
+	job.Status = JobStatusRunning
+	job.Error = nil
+	job.CompletedAt = nil
+	job.mutex.Unlock()
+
+	bm.saveJob(job)
+
+	bm.workers.Add(1)
+	go func() {
+		defer bm.workers.Done()
+		defer bm.finishJob(job)
+
+		fn()
+	}()
+}
+
+// finishJob records the final status of a job run
+func (bm *BatchManager) finishJob(job *BatchJob) {
+	job.mutex.Lock()
+	now := time.Now()
+	job.CompletedAt = &now
+	bm.updateJobStatus(job)
+	if job.ctx.Err() != nil {
+		job.Status = JobStatusCancelled
+	}
+	job.cancel()
+	status := job.Status
+	job.mutex.Unlock()
+
+	bm.saveJob(job)
+	bm.logger.Info().Str("job_id", job.ID).Str("status", string(status)).Msg("Batch job completed")
+}
+
 // processBatchJob processes a batch download job
 func (bm *BatchManager) processBatchJob(job *BatchJob) {
 	bm.logger.Info().Str("job_id", job.ID).Msg("Starting batch job")
 
-	job.Status = JobStatusRunning
-	defer func() {
-		now := time.Now()
-		job.CompletedAt = &now
-		bm.updateJobStatus(job)
-		bm.logger.Info().Str("job_id", job.ID).Str("status", string(job.Status)).Msg("Batch job completed")
-	}()
-
 	switch job.Type {
 	case BatchJobTypeUserProfile:
 		bm.processUserProfile(job)
@@ -147,262 +356,281 @@ func (bm *BatchManager) processBatchJob(job *BatchJob) {
 	case BatchJobTypeURLList:
 		bm.processURLList(job)
 	default:
-		job.Status = JobStatusFailed
-		job.Error = fmt.Errorf("unsupported job type: %s", job.Type)
-		return
+		bm.failJob(job, fmt.Errorf("unsupported job type: %s", job.Type))
 	}
+}
 
-	bm.updateJobStatus(job)
+// failJob marks the job as failed
+func (bm *BatchManager) failJob(job *BatchJob, err error) {
+	job.mutex.Lock()
+	job.Status = JobStatusFailed
+	job.Error = err
+	job.mutex.Unlock()
 }
 
 // processUserProfile processes user profile batch download
 func (bm *BatchManager) processUserProfile(job *BatchJob) {
+	if err := bm.processProfiles(job, job.URLs); err != nil {
+		bm.failJob(job, err)
+	}
+}
+
+// processProfiles lists the videos of each profile URL and downloads
+// them. A profile that cannot be listed is recorded as a failed result
+// under its URL so the job can be retried. Each profile counts as one
+// item of the total until its videos are known. It returns an error if
+// none of the profiles had any videos.
+func (bm *BatchManager) processProfiles(job *BatchJob, urls []string) error {
 	var allVideos []*models.VideoInfo
-	var errors []error
+	var errs []error
 
 	// Extract videos from each profile URL
-	for _, url := range job.URLs {
+	for _, url := range urls {
+		if job.ctx.Err() != nil {
+			return nil
+		}
+
 		extractor, err := bm.getExtractorForURL(url)
 		if err != nil {
-			errors = append(errors, fmt.Errorf("no extractor for URL %s: %w", url, err))
+			err = fmt.Errorf("no extractor for URL %s: %w", url, err)
+			errs = append(errs, err)
+			bm.addResult(job, BatchResult{URL: url, Status: "failed", Error: err})
 			continue
 		}
 
-		videos, err := extractor.ExtractBatch(url, 100) // Default limit of 100
+		videos, err := extractor.ExtractBatch(job.ctx, url, 100) // Default limit of 100
 		if err != nil {
-			errors = append(errors, fmt.Errorf("failed to extract batch from %s: %w", url, err))
+			err = fmt.Errorf("failed to extract batch from %s: %w", url, err)
+			errs = append(errs, err)
+			bm.addResult(job, BatchResult{URL: url, Status: "failed", Error: err})
 			continue
 		}
 
+		// The profile's place in the total is taken by its videos
+		job.mutex.Lock()
+		job.Progress.Total += len(videos) - 1
+		bm.updateProgress(job)
+		job.mutex.Unlock()
+
 		allVideos = append(allVideos, videos...)
 	}
 
 	if len(allVideos) == 0 {
-		job.Status = JobStatusFailed
-		job.Error = fmt.Errorf("no videos found in user profiles")
-		return
+		err := fmt.Errorf("no videos found in user profiles")
+		if len(errs) > 0 {
+			err = fmt.Errorf("%w: %w", err, errors.Join(errs...))
+		}
+		return err
 	}
 
-	// Update job with extracted videos
-	job.Progress.Total = len(allVideos)
+	bm.saveJob(job)
 
 	// Download videos
 	bm.downloadVideos(job, allVideos)
+	return nil
 }
 
 // processPlaylist processes playlist batch download
 func (bm *BatchManager) processPlaylist(job *BatchJob) {
 	// Similar to user profile but for playlists
 	// This would need platform-specific playlist extraction
-	job.Status = JobStatusFailed
-	job.Error = fmt.Errorf("playlist batch download not implemented")
+	bm.failJob(job, fmt.Errorf("playlist batch download not implemented"))
 }
 
 // processURLList processes URL list batch download
 func (bm *BatchManager) processURLList(job *BatchJob) {
+	if !bm.processURLs(job, job.URLs) {
+		bm.failJob(job, fmt.Errorf("no valid videos found in URL list"))
+	}
+}
+
+// processURLs extracts and downloads each URL as a single video. It
+// returns false if none of the URLs could be extracted.
+func (bm *BatchManager) processURLs(job *BatchJob, urls []string) bool {
 	var allVideos []*models.VideoInfo
 
 	// Extract video info from each URL
-	for _, url := range job.URLs {
+	for _, url := range urls {
+		if job.ctx.Err() != nil {
+			return true
+		}
+
 		extractor, err := bm.getExtractorForURL(url)
 		if err != nil {
-			job.Results = append(job.Results, BatchResult{
+			bm.addResult(job, BatchResult{
 				URL:    url,
 				Status: "failed",
 				Error:  fmt.Errorf("no extractor for URL: %w", err),
 			})
-			job.Progress.Failed++
 			continue
 		}
 
-		videoInfo, err := extractor.ExtractVideoInfo(url)
+		videoInfo, err := extractor.ExtractVideoInfo(job.ctx, url)
 		if err != nil {
-			job.Results = append(job.Results, BatchResult{
+			bm.addResult(job, BatchResult{
 				URL:    url,
 				Status: "failed",
 				Error:  fmt.Errorf("failed to extract video info: %w", err),
 			})
-			job.Progress.Failed++
 			continue
 		}
 
+		// Results are matched against the submitted URLs on retry
+		videoInfo.URL = url
+
 		allVideos = append(allVideos, videoInfo)
 	}
 
 	if len(allVideos) == 0 {
-		job.Status = JobStatusFailed
-		job.Error = fmt.Errorf("no valid videos found in URL list")
-		return
+		return false
 	}
 
 	// Download videos
 	bm.downloadVideos(job, allVideos)
+	return true
 }
 
 // downloadVideos downloads a list of videos
 func (bm *BatchManager) downloadVideos(job *BatchJob, videos []*models.VideoInfo) {
+	var wg sync.WaitGroup
+
 	// Create download tasks
 	for _, video := range videos {
-		bm.workers.Add(1)
+		job.mutex.Lock()
+		job.Progress.InProgress++
+		job.mutex.Unlock()
+
+		wg.Add(1)
 		go func(v *models.VideoInfo) {
-			defer bm.workers.Done()
+			defer wg.Done()
 
 			// Acquire semaphore
 			bm.semaphore <- struct{}{}
 			defer func() { <-bm.semaphore }()
 
-			// Check if context is cancelled
-			select {
-			case <-bm.ctx.Done():
-				job.Progress.Skipped++
-				return
-			default:
-			}
-
 			result := BatchResult{
 				URL:       v.URL,
 				VideoInfo: v,
 				Status:    "downloading",
 			}
+			defer func() {
+				job.mutex.Lock()
+				job.Progress.InProgress--
+				job.mutex.Unlock()
 
-			start := time.Now()
-
-			// Create download task
-			task := &models.DownloadTask{
-				ID:        fmt.Sprintf("dl_%s_%d", job.ID, time.Now().UnixNano()),
-				VideoID:   v.ID,
-				URL:       v.DownloadURL,
-				Platform:  v.Platform,
-				Status:    "pending",
-				Progress:  0,
-				FilePath:  bm.generateFilePath(v, job.Config),
-				CreatedAt: time.Now(),
-			}
+				bm.addResult(job, result)
+			}()
 
-			// Check if file already exists and skip if configured
-			if job.Config.SkipExisting && bm.fileExists(task.FilePath) {
+			// Check if context is cancelled
+			if job.ctx.Err() != nil {
 				result.Status = "skipped"
-				result.FilePath = task.FilePath
-				job.Progress.Skipped++
-				job.Results = append(job.Results, result)
 				return
 			}
 
-			// Start download
-			progressChan := make(chan float64, 1)
-			go bm.monitorProgress(progressChan, task)
+			start := time.Now()
 
-			_, err := bm.downloader.Download(task.URL, &downloader.DownloadOptions{
-				OutputPath: task.FilePath,
-			})
+			options := job.Config.downloadOptions(job.ID)
+
+			// Check if file already exists and skip if configured
+			if job.Config.SkipExisting {
+				filePath, err := bm.downloader.OutputPath(v, options)
+				if err == nil && bm.fileExists(filePath) {
+					result.Status = "skipped"
+					result.FilePath = filePath
+					return
+				}
+			}
+
+			// Queue the page URL; the downloader extracts a fresh media URL
+			taskID, downloadResult, err := bm.download(job.ctx, v.URL, options)
+			result.TaskID = taskID
 			result.Duration = time.Since(start)
 
 			if err != nil {
 				result.Status = "failed"
 				result.Error = err
-				job.Progress.Failed++
 				bm.logger.Error().Err(err).Str("url", v.URL).Msg("Download failed")
-			} else {
-				result.Status = "completed"
-				result.FilePath = task.FilePath
-				result.Size = bm.getFileSize(task.FilePath)
-				job.Progress.Completed++
-				bm.logger.Info().Str("url", v.URL).Str("file", task.FilePath).Msg("Download completed")
+				return
 			}
 
-			job.Results = append(job.Results, result)
-			job.Progress.InProgress--
-			bm.updateProgress(job)
+			result.Status = "completed"
+			if downloadResult.Video != nil {
+				result.VideoInfo = downloadResult.Video
+				result.FilePath = downloadResult.Video.FilePath
+				result.Size = bm.getFileSize(result.FilePath)
+			}
+			bm.logger.Info().Str("url", v.URL).Str("file", result.FilePath).Msg("Download completed")
 		}(video)
-
-		job.Progress.InProgress++
 	}
 
 	// Wait for all downloads to complete
-	bm.workers.Wait()
+	wg.Wait()
 }
 
-// getExtractorForURL returns the appropriate extractor for a URL
-func (bm *BatchManager) getExtractorForURL(url string) (models.PlatformExtractor, error) {
-	// Check TikTok
-	tiktokExtractor := platform.NewTikTokExtractor(&models.ExtractorConfig{
-		Timeout:    30 * time.Second,
-		MaxRetries: 3,
-	})
-	if tiktokExtractor.ValidateURL(url) {
-		return tiktokExtractor, nil
-	}
-
-	// Check XHS
-	xhsExtractor := platform.NewXHSExtractor(&models.ExtractorConfig{
-		Timeout:    30 * time.Second,
-		MaxRetries: 3,
-	})
-	if xhsExtractor.ValidateURL(url) {
-		return xhsExtractor, nil
-	}
-
-	// Check Kuaishou
-	kuaishouExtractor := platform.NewKuaishouExtractor(&models.ExtractorConfig{
-		Timeout:    30 * time.Second,
-		MaxRetries: 3,
-	})
-	if kuaishouExtractor.ValidateURL(url) {
-		return kuaishouExtractor, nil
+// addResult records the outcome of one item and persists the job
+func (bm *BatchManager) addResult(job *BatchJob, result BatchResult) {
+	job.mutex.Lock()
+	switch result.Status {
+	case "completed":
+		job.Progress.Completed++
+	case "skipped":
+		job.Progress.Skipped++
+	default:
+		job.Progress.Failed++
 	}
+	job.Results = append(job.Results, result)
+	bm.updateProgress(job)
+	job.mutex.Unlock()
 
-	return nil, fmt.Errorf("no supported extractor found for URL: %s", url)
+	bm.saveJob(job)
 }
 
-// generateFilePath generates a file path for a video
-func (bm *BatchManager) generateFilePath(video *models.VideoInfo, config BatchDownloadConfig) string {
-	// Generate filename based on template
-	filename := fmt.Sprintf("%s_%s_%s_%s.%s",
-		video.Platform,
-		video.AuthorName,
-		video.Title,
-		video.ID,
-		video.Format,
-	)
+// download queues a URL with the download manager and waits for it to
+// finish, cancelling the task if ctx is done first
+func (bm *BatchManager) download(ctx context.Context, url string, options *downloader.DownloadOptions) (string, *downloader.DownloadResult, error) {
+	taskID, err := bm.downloader.Download(ctx, url, options)
+	if err != nil {
+		return "", nil, err
+	}
 
-	// Clean filename
-	filename = bm.cleanFilename(filename)
+	result, err := bm.downloader.Wait(ctx, taskID)
+	if err != nil {
+		if cancelErr := bm.downloader.CancelDownload(taskID); cancelErr != nil {
+			bm.logger.Debug().Err(cancelErr).Str("task_id", taskID).Msg("Error cancelling download")
+		}
+		return taskID, nil, err
+	}
 
-	return fmt.Sprintf("%s/%s", config.OutputPath, filename)
+	return taskID, result, result.Error
 }
 
-// cleanFilename removes invalid characters from filename
-func (bm *BatchManager) cleanFilename(filename string) string {
-	// Remove invalid characters
-	invalidChars := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
-	cleanName := filename
-	for _, invalidChar := range invalidChars {
-		cleanName = strings.ReplaceAll(cleanName, invalidChar, "_")
+// getExtractorForURL returns the appropriate extractor for a URL
+func (bm *BatchManager) getExtractorForURL(url string) (models.PlatformExtractor, error) {
+	extractor, _, err := bm.registry.GetExtractorForURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("no supported extractor found for URL: %s", url)
 	}
-	return cleanName
+
+	return extractor, nil
 }
 
 // fileExists checks if a file exists
-func (bm *BatchManager) fileExists(filepath string) bool {
-	// Implementation would check file existence
-	return false
+func (bm *BatchManager) fileExists(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir()
 }
 
 // getFileSize returns the size of a file
-func (bm *BatchManager) getFileSize(filepath string) int64 {
-	// Implementation would return actual file size
-	return 0
-}
-
-// monitorProgress monitors download progress
-func (bm *BatchManager) monitorProgress(progressChan <-chan float64, task *models.DownloadTask) {
-	for progress := range progressChan {
-		task.Progress = progress
-		// Update task in database if needed
+func (bm *BatchManager) getFileSize(path string) int64 {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0
 	}
+	return stat.Size()
 }
 
-// updateProgress updates job progress
+// updateProgress updates job progress. Must be called with the job mutex
+// held.
 func (bm *BatchManager) updateProgress(job *BatchJob) {
 	total := float64(job.Progress.Total)
 	if total > 0 {
@@ -410,28 +638,308 @@ func (bm *BatchManager) updateProgress(job *BatchJob) {
 	}
 }
 
-// updateJobStatus updates the final job status
+// updateJobStatus sets the final status of a finished job run: failed
+// if it stopped with an error or nothing completed but something failed,
+// partial if some items failed and completed otherwise. Must be called
+// with the job mutex held.
 func (bm *BatchManager) updateJobStatus(job *BatchJob) {
-	if job.Progress.Failed > 0 && job.Progress.Completed > 0 {
-		job.Status = JobStatusPartial
-	} else if job.Progress.Failed == job.Progress.Total {
+	switch {
+	case job.Error != nil, job.Progress.Failed > 0 && job.Progress.Completed == 0:
 		job.Status = JobStatusFailed
-	} else if job.Progress.Completed+job.Progress.Skipped == job.Progress.Total {
+	case job.Progress.Failed > 0:
+		job.Status = JobStatusPartial
+	default:
 		job.Status = JobStatusCompleted
 	}
 }
 
-// CancelJob cancels a running batch job
+// CancelJob cancels a running batch job, aborting its extractions and downloads
 func (bm *BatchManager) CancelJob(jobID string) error {
-	// In a real implementation, you would track jobs and cancel them
-	bm.cancel()
+	bm.jobsMutex.RLock()
+	job, exists := bm.jobs[jobID]
+	bm.jobsMutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("job not found: %s", jobID)
+	}
+
+	job.mutex.RLock()
+	defer job.mutex.RUnlock()
+
+	if job.Status != JobStatusRunning {
+		return fmt.Errorf("job is not running: %s", job.Status)
+	}
+
+	job.cancel()
 	return nil
 }
 
+// RetryFailed runs the failed items of a finished job again under the
+// same job ID. URLs of an interrupted URL list that never ran are retried
+// too, and profiles that could not be listed are listed again; a job that
+// stopped before producing any results is run in full.
+func (bm *BatchManager) RetryFailed(jobID string) (*BatchJob, error) {
+	job, err := bm.loadJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	job.mutex.Lock()
+	if job.Status == JobStatusRunning || job.Status == JobStatusPending {
+		job.mutex.Unlock()
+		return nil, fmt.Errorf("job is still running")
+	}
+
+	if len(job.Results) == 0 {
+		job.Progress = BatchProgress{Total: len(job.URLs)}
+		job.mutex.Unlock()
+
+		bm.runJob(job, func() { bm.processBatchJob(job) })
+		return job, nil
+	}
+
+	// Failed profiles of a user profile job are listed again; everything
+	// else failed is a single video
+	profiles := make(map[string]bool)
+	if job.Type == BatchJobTypeUserProfile {
+		for _, url := range job.URLs {
+			profiles[url] = true
+		}
+	}
+
+	var urls, profileURLs []string
+	seen := make(map[string]bool, len(job.Results))
+	kept := make([]BatchResult, 0, len(job.Results))
+	for _, result := range job.Results {
+		seen[result.URL] = true
+		if result.Status == "failed" {
+			if profiles[result.URL] {
+				profileURLs = append(profileURLs, result.URL)
+			} else {
+				urls = append(urls, result.URL)
+			}
+			continue
+		}
+		kept = append(kept, result)
+	}
+	if job.Type == BatchJobTypeURLList {
+		for _, url := range job.URLs {
+			if !seen[url] {
+				urls = append(urls, url)
+			}
+		}
+	}
+
+	if len(urls) == 0 && len(profileURLs) == 0 {
+		job.mutex.Unlock()
+		return nil, fmt.Errorf("job has no failed downloads")
+	}
+
+	job.Results = kept
+	job.Progress.Failed = 0
+	job.Progress.InProgress = 0
+	bm.updateProgress(job)
+	job.mutex.Unlock()
+
+	bm.runJob(job, func() {
+		if len(profileURLs) > 0 {
+			if err := bm.processProfiles(job, profileURLs); err != nil {
+				bm.logger.Warn().Err(err).Str("job_id", job.ID).Msg("Retried profiles have no videos")
+			}
+		}
+		if len(urls) > 0 {
+			bm.processURLs(job, urls)
+		}
+	})
+	return job, nil
+}
+
 // GetJobStatus returns the status of a batch job
 func (bm *BatchManager) GetJobStatus(jobID string) (*BatchJob, error) {
-	// In a real implementation, you would store and retrieve job status
-	return nil, fmt.Errorf("job not found: %s", jobID)
+	bm.jobsMutex.RLock()
+	defer bm.jobsMutex.RUnlock()
+
+	job, exists := bm.jobs[jobID]
+	if !exists {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+
+	return job, nil
+}
+
+// GetJob returns a job with its per-URL results, loading it from storage
+// if it is not in memory
+func (bm *BatchManager) GetJob(jobID string) (*BatchJobInfo, error) {
+	job, err := bm.loadJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	return job.Info(true), nil
+}
+
+// ListJobs lists jobs newest first, without per-URL results
+func (bm *BatchManager) ListJobs(limit, offset int) ([]*BatchJobInfo, error) {
+	if bm.storage == nil {
+		bm.jobsMutex.RLock()
+		defer bm.jobsMutex.RUnlock()
+
+		infos := make([]*BatchJobInfo, 0, len(bm.jobs))
+		for _, job := range bm.jobs {
+			infos = append(infos, job.Info(false))
+		}
+		return infos, nil
+	}
+
+	records, err := bm.storage.ListBatchJobs(limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error listing batch jobs: %w", err)
+	}
+
+	infos := make([]*BatchJobInfo, 0, len(records))
+	for _, record := range records {
+		// Live jobs are more current than their last save
+		bm.jobsMutex.RLock()
+		job, exists := bm.jobs[record.ID]
+		bm.jobsMutex.RUnlock()
+
+		if !exists {
+			job = jobFromRecord(record)
+		}
+		infos = append(infos, job.Info(false))
+	}
+
+	return infos, nil
+}
+
+// loadJob returns the job, loading it from storage if it is not in memory
+func (bm *BatchManager) loadJob(jobID string) (*BatchJob, error) {
+	bm.jobsMutex.Lock()
+	defer bm.jobsMutex.Unlock()
+
+	if job, exists := bm.jobs[jobID]; exists {
+		return job, nil
+	}
+
+	if bm.storage == nil {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+
+	record, err := bm.storage.GetBatchJob(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading batch job: %w", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+
+	job := jobFromRecord(record)
+	bm.jobs[job.ID] = job
+	return job, nil
+}
+
+// saveJob persists the job if storage is configured
+func (bm *BatchManager) saveJob(job *BatchJob) {
+	if bm.storage == nil {
+		return
+	}
+
+	info := job.Info(true)
+
+	urls, err := json.Marshal(info.URLs)
+	if err != nil {
+		bm.logger.Error().Err(err).Str("job_id", job.ID).Msg("Error encoding batch job")
+		return
+	}
+	config, err := json.Marshal(info.Config)
+	if err != nil {
+		bm.logger.Error().Err(err).Str("job_id", job.ID).Msg("Error encoding batch job")
+		return
+	}
+	results, err := json.Marshal(info.Results)
+	if err != nil {
+		bm.logger.Error().Err(err).Str("job_id", job.ID).Msg("Error encoding batch job")
+		return
+	}
+
+	record := &models.BatchJobRecord{
+		ID:          info.ID,
+		Type:        string(info.Type),
+		Status:      string(info.Status),
+		URLs:        string(urls),
+		Config:      string(config),
+		Results:     string(results),
+		Total:       info.Progress.Total,
+		Completed:   info.Progress.Completed,
+		Failed:      info.Progress.Failed,
+		Skipped:     info.Progress.Skipped,
+		Error:       info.Error,
+		CreatedAt:   info.StartedAt,
+		StartedAt:   info.StartedAt,
+		CompletedAt: info.CompletedAt,
+	}
+
+	if err := bm.storage.SaveBatchJob(record); err != nil {
+		bm.logger.Error().Err(err).Str("job_id", job.ID).Msg("Error saving batch job")
+	}
+}
+
+// Info returns a consistent copy of the job
+func (job *BatchJob) Info(withResults bool) *BatchJobInfo {
+	job.mutex.RLock()
+	defer job.mutex.RUnlock()
+
+	info := &BatchJobInfo{
+		ID:          job.ID,
+		Type:        job.Type,
+		URLs:        append([]string(nil), job.URLs...),
+		Config:      job.Config,
+		Status:      job.Status,
+		Progress:    job.Progress,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+	}
+	if job.Error != nil {
+		info.Error = job.Error.Error()
+	}
+	if withResults {
+		info.Results = append([]BatchResult(nil), job.Results...)
+	}
+
+	return info
+}
+
+// jobFromRecord rebuilds a finished job from its persisted form
+func jobFromRecord(record *models.BatchJobRecord) *BatchJob {
+	job := &BatchJob{
+		ID:          record.ID,
+		Type:        BatchJobType(record.Type),
+		Status:      JobStatus(record.Status),
+		StartedAt:   record.StartedAt,
+		CompletedAt: record.CompletedAt,
+		Progress: BatchProgress{
+			Total:     record.Total,
+			Completed: record.Completed,
+			Failed:    record.Failed,
+			Skipped:   record.Skipped,
+		},
+	}
+
+	// Fields were written by saveJob, so they decode cleanly
+	_ = json.Unmarshal([]byte(record.URLs), &job.URLs)
+	_ = json.Unmarshal([]byte(record.Config), &job.Config)
+	_ = json.Unmarshal([]byte(record.Results), &job.Results)
+
+	if record.Error != "" {
+		job.Error = errors.New(record.Error)
+	}
+
+	total := float64(job.Progress.Total)
+	if total > 0 {
+		job.Progress.Percentage = float64(job.Progress.Completed+job.Progress.Failed+job.Progress.Skipped) / total * 100
+	}
+
+	return job
 }
 
 // Close shuts down the batch manager
@@ -1,11 +1,14 @@
 package comment
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
-	"strings"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -39,56 +42,262 @@ type Comment struct {
 	IPLocation   string          `json:"ip_location"`
 }
 
-// CommentThread represents a comment with its replies
+// CommentThread represents a comment with its replies. HasMore is set when
+// the platform has replies beyond those in Replies.
 type CommentThread struct {
 	Comment *Comment   `json:"comment"`
 	Replies []*Comment `json:"replies"`
 	Total   int        `json:"total"`
 	HasMore bool       `json:"has_more"`
+
+	// replyCursor is where the replies not in Replies start
+	replyCursor string
 }
 
 // CommentExtractConfig holds configuration for comment extraction
 type CommentExtractConfig struct {
 	VideoID        string
 	Platform       models.Platform
-	Limit          int
+	Limit          int // Top-level comments to fetch, 0 for all
 	IncludeReplies bool
 	SortBy         string // time, popularity
 	Cookie         string
 	UserAgent      string
 }
 
-// NewCommentExtractor creates a new comment extractor
-func NewCommentExtractor() *CommentExtractor {
+// ConfigForVideo returns the config for extracting the comments of video,
+// with the platform's cookie and user agent from cfg
+func ConfigForVideo(cfg *models.Config, video *models.VideoInfo) CommentExtractConfig {
+	config := CommentExtractConfig{
+		VideoID:  video.ID,
+		Platform: video.Platform,
+	}
+
+	switch video.Platform {
+	case models.PlatformTikTok:
+		config.Cookie = cfg.Platforms.TikTok.Cookie
+		config.UserAgent = cfg.Platforms.TikTok.UserAgent
+	case models.PlatformXHS:
+		config.Cookie = cfg.Platforms.XHS.Cookie
+		config.UserAgent = cfg.Platforms.XHS.UserAgent
+	case models.PlatformKuaishou:
+		config.Cookie = cfg.Platforms.Kuaishou.Cookie
+		config.UserAgent = cfg.Platforms.Kuaishou.UserAgent
+	}
+
+	return config
+}
+
+// pageSize is the number of comments requested at a time, the most the
+// platforms return per page
+const pageSize = 20
+
+// kuaishouNoMore is the pcursor Kuaishou returns after the last page
+const kuaishouNoMore = "no_more"
+
+// commentPage is one page of top-level comments
+type commentPage struct {
+	threads []*CommentThread
+	cursor  string
+	hasMore bool
+}
+
+// replyPage is one page of replies to a comment
+type replyPage struct {
+	replies []*Comment
+	cursor  string
+	hasMore bool
+}
+
+// NewCommentExtractor creates a new comment extractor. A nil transport uses
+// a transport of its own.
+func NewCommentExtractor(transport http.RoundTripper) *CommentExtractor {
 	return &CommentExtractor{
 		client: utils.NewHTTPClient(utils.ClientConfig{
 			Timeout:    30 * time.Second,
 			MaxRetries: 3,
+			Transport:  transport,
 		}),
 		logger: zerolog.New(nil).With().Str("component", "comment_extractor").Logger(),
 	}
 }
 
-// ExtractComments extracts comments from a video
-func (ce *CommentExtractor) ExtractComments(config CommentExtractConfig) ([]*CommentThread, error) {
+// ExtractComments extracts comments from a video, following the platform's
+// cursors until config.Limit threads are collected or there are no more.
+// The threads are ordered by config.SortBy, or as the platform returned
+// them when it is empty. Use Comments to process them without holding
+// every thread in memory.
+func (ce *CommentExtractor) ExtractComments(ctx context.Context, config CommentExtractConfig) ([]*CommentThread, error) {
+	if !ValidSort(config.SortBy) {
+		return nil, fmt.Errorf("unsupported sort: %s", config.SortBy)
+	}
+
+	it := ce.Comments(ctx, config)
+
+	var threads []*CommentThread
+	for it.Next() {
+		threads = append(threads, it.Thread())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	SortThreads(threads, config.SortBy)
+	return threads, nil
+}
+
+// ValidSort reports whether sortBy is an order SortThreads knows
+func ValidSort(sortBy string) bool {
+	switch sortBy {
+	case "", "time", "popularity":
+		return true
+	default:
+		return false
+	}
+}
+
+// SortThreads orders threads newest first for "time" or most liked first
+// for "popularity". Other values leave the order unchanged. Replies keep
+// the order the platform returned them in.
+func SortThreads(threads []*CommentThread, sortBy string) {
+	switch sortBy {
+	case "time":
+		sort.SliceStable(threads, func(i, j int) bool {
+			return threads[i].Comment.CreatedAt.After(threads[j].Comment.CreatedAt)
+		})
+	case "popularity":
+		sort.SliceStable(threads, func(i, j int) bool {
+			return threads[i].Comment.LikeCount > threads[j].Comment.LikeCount
+		})
+	}
+}
+
+// fetchCommentPage fetches count top-level comments starting at cursor; an
+// empty cursor is the first page
+func (ce *CommentExtractor) fetchCommentPage(ctx context.Context, config CommentExtractConfig, cursor string, count int) (*commentPage, error) {
+	switch config.Platform {
+	case models.PlatformTikTok:
+		return ce.fetchTikTokComments(ctx, config, cursor, count)
+	case models.PlatformXHS:
+		return ce.fetchXHSComments(ctx, config, cursor, count)
+	case models.PlatformKuaishou:
+		return ce.fetchKuaishouComments(ctx, config, cursor, count)
+	default:
+		return nil, fmt.Errorf("unsupported platform: %s", config.Platform)
+	}
+}
+
+// fetchReplyPage fetches a page of replies to thread starting at cursor
+func (ce *CommentExtractor) fetchReplyPage(ctx context.Context, config CommentExtractConfig, thread *CommentThread, cursor string) (*replyPage, error) {
 	switch config.Platform {
 	case models.PlatformTikTok:
-		return ce.extractTikTokComments(config)
+		return ce.fetchTikTokReplies(ctx, config, thread, cursor)
 	case models.PlatformXHS:
-		return ce.extractXHSComments(config)
+		return ce.fetchXHSReplies(ctx, config, thread, cursor)
 	case models.PlatformKuaishou:
-		return ce.extractKuaishouComments(config)
+		return ce.fetchKuaishouReplies(ctx, config, thread, cursor)
 	default:
 		return nil, fmt.Errorf("unsupported platform: %s", config.Platform)
 	}
 }
 
-// extractTikTokComments extracts comments from TikTok
-func (ce *CommentExtractor) extractTikTokComments(config CommentExtractConfig) ([]*CommentThread, error) {
-	// TikTok comment API endpoint
-	apiURL := fmt.Sprintf("https://www.tiktok.com/api/comment/list/?aweme_id=%s&count=%d&cursor=0",
-		config.VideoID, config.Limit)
+// fetchReplies adds the replies of thread that are not in thread.Replies
+// yet, page by page
+func (ce *CommentExtractor) fetchReplies(ctx context.Context, config CommentExtractConfig, thread *CommentThread) error {
+	// Pages may overlap with the replies embedded in the thread
+	seen := make(map[string]bool, len(thread.Replies))
+	for _, reply := range thread.Replies {
+		seen[reply.ID] = true
+	}
+
+	cursor := thread.replyCursor
+	for {
+		page, err := ce.fetchReplyPage(ctx, config, thread, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, reply := range page.replies {
+			if !seen[reply.ID] {
+				seen[reply.ID] = true
+				thread.Replies = append(thread.Replies, reply)
+			}
+		}
+
+		// A cursor that does not move would return the same page forever
+		if !page.hasMore || page.cursor == cursor {
+			break
+		}
+		cursor = page.cursor
+	}
+
+	thread.HasMore = false
+	thread.replyCursor = ""
+	return nil
+}
+
+// decodeResponse checks the response of a comment API and decodes its JSON
+// body into v; name is the platform name used in errors
+func (ce *CommentExtractor) decodeResponse(resp *http.Response, err error, name string, v interface{}) error {
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s comments: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s API returned status %d", name, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s comment response: %w", name, err)
+	}
+
+	return nil
+}
+
+// flag decodes has_more fields, which are booleans in some APIs and 0 or 1
+// in others
+type flag bool
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *flag) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", "1":
+		*f = true
+	case "false", "0", "null":
+		*f = false
+	default:
+		return fmt.Errorf("invalid has_more value: %s", data)
+	}
+	return nil
+}
+
+// tiktokComment is a comment or reply from TikTok's comment APIs
+type tiktokComment struct {
+	CID  string `json:"cid"`
+	Text string `json:"text"`
+	User struct {
+		UID      string `json:"uid"`
+		Nickname string `json:"nickname"`
+		Avatar   string `json:"avatar_thumb"`
+	} `json:"user"`
+	DiggCount     int             `json:"digg_count"`
+	ReplyCount    int             `json:"reply_comment_total"`
+	CreateTime    int64           `json:"create_time"`
+	ReplyComments []tiktokComment `json:"reply_comments"`
+}
+
+// tiktokCommentList is a page of comments or replies from TikTok
+type tiktokCommentList struct {
+	Comments []tiktokComment `json:"comments"`
+	Cursor   int64           `json:"cursor"`
+	Total    int             `json:"total"`
+	HasMore  flag            `json:"has_more"`
+}
 
+// tiktokHeaders returns the headers TikTok's comment APIs are called with
+func (ce *CommentExtractor) tiktokHeaders(config CommentExtractConfig) map[string]string {
 	headers := map[string]string{
 		"Accept":          "application/json",
 		"Accept-Language": "en-US,en;q=0.9",
@@ -100,103 +309,131 @@ func (ce *CommentExtractor) extractTikTokComments(config CommentExtractConfig) (
 		headers["Cookie"] = config.Cookie
 	}
 
-	resp, err := ce.client.Get(apiURL, headers)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch TikTok comments: %w", err)
+	return headers
+}
+
+// fetchTikTokComments fetches a page of comments from TikTok. Its cursor
+// is an offset.
+func (ce *CommentExtractor) fetchTikTokComments(ctx context.Context, config CommentExtractConfig, cursor string, count int) (*commentPage, error) {
+	if cursor == "" {
+		cursor = "0"
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("TikTok API returned status %d", resp.StatusCode)
-	}
-
-	var apiResp struct {
-		Comments []struct {
-			CID  string `json:"cid"`
-			Text string `json:"text"`
-			User struct {
-				UID      string `json:"uid"`
-				Nickname string `json:"nickname"`
-				Avatar   string `json:"avatar_thumb"`
-			} `json:"user"`
-			DiggCount     int   `json:"digg_count"`
-			ReplyCount    int   `json:"reply_comment_total"`
-			CreateTime    int64 `json:"create_time"`
-			ReplyComments []struct {
-				CID  string `json:"cid"`
-				Text string `json:"text"`
-				User struct {
-					UID      string `json:"uid"`
-					Nickname string `json:"nickname"`
-					Avatar   string `json:"avatar_thumb"`
-				} `json:"user"`
-				DiggCount  int   `json:"digg_count"`
-				CreateTime int64 `json:"create_time"`
-			} `json:"reply_comments"`
-		} `json:"comments"`
-		Total   int  `json:"total"`
-		HasMore bool `json:"has_more"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode TikTok comment response: %w", err)
+	apiURL := fmt.Sprintf("https://www.tiktok.com/api/comment/list/?aweme_id=%s&count=%d&cursor=%s",
+		url.QueryEscape(config.VideoID), count, url.QueryEscape(cursor))
+
+	var list tiktokCommentList
+	resp, err := ce.client.Get(ctx, apiURL, ce.tiktokHeaders(config))
+	if err := ce.decodeResponse(resp, err, "TikTok", &list); err != nil {
+		return nil, err
 	}
 
-	var threads []*CommentThread
-	for _, comment := range apiResp.Comments {
+	page := &commentPage{
+		cursor:  strconv.FormatInt(list.Cursor, 10),
+		hasMore: bool(list.HasMore),
+	}
+
+	for _, comment := range list.Comments {
 		thread := &CommentThread{
-			Comment: &Comment{
-				ID:           comment.CID,
-				VideoID:      config.VideoID,
-				Platform:     models.PlatformTikTok,
-				AuthorID:     comment.User.UID,
-				AuthorName:   comment.User.Nickname,
-				AuthorAvatar: comment.User.Avatar,
-				Content:      comment.Text,
-				LikeCount:    comment.DiggCount,
-				ReplyCount:   comment.ReplyCount,
-				CreatedAt:    time.Unix(comment.CreateTime, 0),
-				Level:        0,
-				Mentions:     ce.extractMentions(comment.Text),
-			},
-			Replies: make([]*Comment, 0),
-			Total:   comment.ReplyCount,
-			HasMore: comment.ReplyCount > len(comment.ReplyComments),
+			Comment:     ce.tiktokComment(config, comment, ""),
+			Replies:     make([]*Comment, 0),
+			Total:       comment.ReplyCount,
+			HasMore:     comment.ReplyCount > len(comment.ReplyComments),
+			replyCursor: strconv.Itoa(len(comment.ReplyComments)),
 		}
 
 		// Add replies if requested
 		if config.IncludeReplies {
 			for _, reply := range comment.ReplyComments {
-				replyComment := &Comment{
-					ID:           reply.CID,
-					VideoID:      config.VideoID,
-					Platform:     models.PlatformTikTok,
-					AuthorID:     reply.User.UID,
-					AuthorName:   reply.User.Nickname,
-					AuthorAvatar: reply.User.Avatar,
-					Content:      reply.Text,
-					LikeCount:    reply.DiggCount,
-					CreatedAt:    time.Unix(reply.CreateTime, 0),
-					ParentID:     comment.CID,
-					Level:        1,
-					Mentions:     ce.extractMentions(reply.Text),
-				}
-				thread.Replies = append(thread.Replies, replyComment)
+				thread.Replies = append(thread.Replies, ce.tiktokComment(config, reply, comment.CID))
 			}
 		}
 
-		threads = append(threads, thread)
+		page.threads = append(page.threads, thread)
 	}
 
-	return threads, nil
+	return page, nil
 }
 
-// extractXHSComments extracts comments from XHS
-func (ce *CommentExtractor) extractXHSComments(config CommentExtractConfig) ([]*CommentThread, error) {
-	// XHS comment API endpoint
-	apiURL := fmt.Sprintf("https://edith.xiaohongshu.com/api/sns/web/v2/comment/page?note_id=%s&num=%d&cursor=",
-		config.VideoID, config.Limit)
+// fetchTikTokReplies fetches a page of replies from TikTok
+func (ce *CommentExtractor) fetchTikTokReplies(ctx context.Context, config CommentExtractConfig, thread *CommentThread, cursor string) (*replyPage, error) {
+	if cursor == "" {
+		cursor = "0"
+	}
+
+	apiURL := fmt.Sprintf("https://www.tiktok.com/api/comment/list/reply/?item_id=%s&comment_id=%s&count=%d&cursor=%s",
+		url.QueryEscape(config.VideoID), url.QueryEscape(thread.Comment.ID), pageSize, url.QueryEscape(cursor))
+
+	var list tiktokCommentList
+	resp, err := ce.client.Get(ctx, apiURL, ce.tiktokHeaders(config))
+	if err := ce.decodeResponse(resp, err, "TikTok", &list); err != nil {
+		return nil, err
+	}
+
+	page := &replyPage{
+		cursor:  strconv.FormatInt(list.Cursor, 10),
+		hasMore: bool(list.HasMore),
+	}
+
+	for _, reply := range list.Comments {
+		page.replies = append(page.replies, ce.tiktokComment(config, reply, thread.Comment.ID))
+	}
+
+	return page, nil
+}
+
+// tiktokComment converts a TikTok comment; replies have a parentID
+func (ce *CommentExtractor) tiktokComment(config CommentExtractConfig, comment tiktokComment, parentID string) *Comment {
+	c := &Comment{
+		ID:           comment.CID,
+		VideoID:      config.VideoID,
+		Platform:     models.PlatformTikTok,
+		AuthorID:     comment.User.UID,
+		AuthorName:   comment.User.Nickname,
+		AuthorAvatar: comment.User.Avatar,
+		Content:      comment.Text,
+		LikeCount:    comment.DiggCount,
+		ReplyCount:   comment.ReplyCount,
+		CreatedAt:    time.Unix(comment.CreateTime, 0),
+		ParentID:     parentID,
+		Mentions:     ce.extractMentions(comment.Text),
+	}
+	if parentID != "" {
+		c.Level = 1
+	}
+	return c
+}
+
+// xhsComment is a comment or reply from XHS's comment APIs
+type xhsComment struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	User    struct {
+		UserID   string `json:"user_id"`
+		Nickname string `json:"nickname"`
+		Avatar   string `json:"avatar"`
+	} `json:"user"`
+	LikedCount        int          `json:"liked_count"`
+	SubCommentCount   int          `json:"sub_comment_count"`
+	CreateTime        int64        `json:"create_time"`
+	IPLocation        string       `json:"ip_location"`
+	SubComments       []xhsComment `json:"sub_comments"`
+	SubCommentCursor  string       `json:"sub_comment_cursor"`
+	SubCommentHasMore bool         `json:"sub_comment_has_more"`
+}
 
+// xhsCommentList is a page of comments or replies from XHS
+type xhsCommentList struct {
+	Data struct {
+		Comments []xhsComment `json:"comments"`
+		Cursor   string       `json:"cursor"`
+		HasMore  bool         `json:"has_more"`
+	} `json:"data"`
+	Success bool `json:"success"`
+}
+
+// xhsHeaders returns the headers XHS's comment APIs are called with
+func (ce *CommentExtractor) xhsHeaders(config CommentExtractConfig) map[string]string {
 	headers := map[string]string{
 		"Accept":           "application/json",
 		"Accept-Language":  "zh-CN,zh;q=0.9,en;q=0.8",
@@ -209,113 +446,166 @@ func (ce *CommentExtractor) extractXHSComments(config CommentExtractConfig) ([]*
 		headers["Cookie"] = config.Cookie
 	}
 
-	resp, err := ce.client.Get(apiURL, headers)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch XHS comments: %w", err)
+	return headers
+}
+
+// getXHSList fetches a page from an XHS comment API
+func (ce *CommentExtractor) getXHSList(ctx context.Context, config CommentExtractConfig, apiURL string) (*xhsCommentList, error) {
+	var list xhsCommentList
+	resp, err := ce.client.Get(ctx, apiURL, ce.xhsHeaders(config))
+	if err := ce.decodeResponse(resp, err, "XHS", &list); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("XHS API returned status %d", resp.StatusCode)
-	}
-
-	var apiResp struct {
-		Data struct {
-			Comments []struct {
-				ID      string `json:"id"`
-				Content string `json:"content"`
-				User    struct {
-					UserID   string `json:"user_id"`
-					Nickname string `json:"nickname"`
-					Avatar   string `json:"avatar"`
-				} `json:"user"`
-				LikedCount      int   `json:"liked_count"`
-				SubCommentCount int   `json:"sub_comment_count"`
-				CreateTime      int64 `json:"create_time"`
-				SubComments     []struct {
-					ID      string `json:"id"`
-					Content string `json:"content"`
-					User    struct {
-						UserID   string `json:"user_id"`
-						Nickname string `json:"nickname"`
-						Avatar   string `json:"avatar"`
-					} `json:"user"`
-					LikedCount int   `json:"liked_count"`
-					CreateTime int64 `json:"create_time"`
-				} `json:"sub_comments"`
-			} `json:"comments"`
-		} `json:"data"`
-		Success bool `json:"success"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode XHS comment response: %w", err)
-	}
-
-	if !apiResp.Success {
+	if !list.Success {
 		return nil, fmt.Errorf("XHS API request failed")
 	}
 
-	var threads []*CommentThread
-	for _, comment := range apiResp.Data.Comments {
+	return &list, nil
+}
+
+// fetchXHSComments fetches a page of comments from XHS
+func (ce *CommentExtractor) fetchXHSComments(ctx context.Context, config CommentExtractConfig, cursor string, count int) (*commentPage, error) {
+	apiURL := fmt.Sprintf("https://edith.xiaohongshu.com/api/sns/web/v2/comment/page?note_id=%s&num=%d&cursor=%s",
+		url.QueryEscape(config.VideoID), count, url.QueryEscape(cursor))
+
+	list, err := ce.getXHSList(ctx, config, apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &commentPage{
+		cursor:  list.Data.Cursor,
+		hasMore: list.Data.HasMore,
+	}
+
+	for _, comment := range list.Data.Comments {
 		thread := &CommentThread{
-			Comment: &Comment{
-				ID:           comment.ID,
-				VideoID:      config.VideoID,
-				Platform:     models.PlatformXHS,
-				AuthorID:     comment.User.UserID,
-				AuthorName:   comment.User.Nickname,
-				AuthorAvatar: comment.User.Avatar,
-				Content:      comment.Content,
-				LikeCount:    comment.LikedCount,
-				ReplyCount:   comment.SubCommentCount,
-				CreatedAt:    time.Unix(comment.CreateTime/1000, 0), // XHS uses milliseconds
-				Level:        0,
-				Mentions:     ce.extractMentions(comment.Content),
-			},
-			Replies: make([]*Comment, 0),
-			Total:   comment.SubCommentCount,
-			HasMore: comment.SubCommentCount > len(comment.SubComments),
+			Comment:     ce.xhsComment(config, comment, ""),
+			Replies:     make([]*Comment, 0),
+			Total:       comment.SubCommentCount,
+			HasMore:     comment.SubCommentHasMore || comment.SubCommentCount > len(comment.SubComments),
+			replyCursor: comment.SubCommentCursor,
 		}
 
 		// Add replies if requested
 		if config.IncludeReplies {
 			for _, reply := range comment.SubComments {
-				replyComment := &Comment{
-					ID:           reply.ID,
-					VideoID:      config.VideoID,
-					Platform:     models.PlatformXHS,
-					AuthorID:     reply.User.UserID,
-					AuthorName:   reply.User.Nickname,
-					AuthorAvatar: reply.User.Avatar,
-					Content:      reply.Content,
-					LikeCount:    reply.LikedCount,
-					CreatedAt:    time.Unix(reply.CreateTime/1000, 0),
-					ParentID:     comment.ID,
-					Level:        1,
-					Mentions:     ce.extractMentions(reply.Content),
-				}
-				thread.Replies = append(thread.Replies, replyComment)
+				thread.Replies = append(thread.Replies, ce.xhsComment(config, reply, comment.ID))
 			}
 		}
 
-		threads = append(threads, thread)
+		page.threads = append(page.threads, thread)
 	}
 
-	return threads, nil
+	return page, nil
+}
+
+// fetchXHSReplies fetches a page of replies from XHS
+func (ce *CommentExtractor) fetchXHSReplies(ctx context.Context, config CommentExtractConfig, thread *CommentThread, cursor string) (*replyPage, error) {
+	apiURL := fmt.Sprintf("https://edith.xiaohongshu.com/api/sns/web/v2/comment/sub/page?note_id=%s&root_comment_id=%s&num=%d&cursor=%s",
+		url.QueryEscape(config.VideoID), url.QueryEscape(thread.Comment.ID), pageSize, url.QueryEscape(cursor))
+
+	list, err := ce.getXHSList(ctx, config, apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &replyPage{
+		cursor:  list.Data.Cursor,
+		hasMore: list.Data.HasMore,
+	}
+
+	for _, reply := range list.Data.Comments {
+		page.replies = append(page.replies, ce.xhsComment(config, reply, thread.Comment.ID))
+	}
+
+	return page, nil
 }
 
-// extractKuaishouComments extracts comments from Kuaishou
-func (ce *CommentExtractor) extractKuaishouComments(config CommentExtractConfig) ([]*CommentThread, error) {
-	// Kuaishou comment API endpoint
-	apiURL := fmt.Sprintf("https://www.kuaishou.com/graphql")
+// xhsComment converts an XHS comment; replies have a parentID
+func (ce *CommentExtractor) xhsComment(config CommentExtractConfig, comment xhsComment, parentID string) *Comment {
+	c := &Comment{
+		ID:           comment.ID,
+		VideoID:      config.VideoID,
+		Platform:     models.PlatformXHS,
+		AuthorID:     comment.User.UserID,
+		AuthorName:   comment.User.Nickname,
+		AuthorAvatar: comment.User.Avatar,
+		Content:      comment.Content,
+		LikeCount:    comment.LikedCount,
+		ReplyCount:   comment.SubCommentCount,
+		CreatedAt:    time.Unix(comment.CreateTime/1000, 0), // XHS uses milliseconds
+		ParentID:     parentID,
+		Mentions:     ce.extractMentions(comment.Content),
+		IPLocation:   comment.IPLocation,
+	}
+	if parentID != "" {
+		c.Level = 1
+	}
+	return c
+}
+
+// kuaishouComment is a comment or reply from Kuaishou's GraphQL API
+type kuaishouComment struct {
+	CommentID string `json:"commentId"`
+	Content   string `json:"content"`
+	User      struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Avatar string `json:"avatar"`
+	} `json:"user"`
+	LikeCount          int               `json:"likeCount"`
+	SubCommentCount    int               `json:"subCommentCount"`
+	CreateTime         int64             `json:"createTime"`
+	SubComments        []kuaishouComment `json:"subComments"`
+	SubCommentsPcursor string            `json:"subCommentsPcursor"`
+}
+
+// kuaishouCommentList is the response to a comment or reply query
+type kuaishouCommentList struct {
+	Data struct {
+		VisionCommentList struct {
+			Pcursor  string            `json:"pcursor"`
+			Comments []kuaishouComment `json:"comments"`
+		} `json:"visionCommentList"`
+		VisionSubCommentList struct {
+			Pcursor     string            `json:"pcursor"`
+			SubComments []kuaishouComment `json:"subComments"`
+		} `json:"visionSubCommentList"`
+	} `json:"data"`
+}
+
+// postKuaishouQuery runs a GraphQL query against Kuaishou
+func (ce *CommentExtractor) postKuaishouQuery(ctx context.Context, config CommentExtractConfig, requestData map[string]interface{}) (*kuaishouCommentList, error) {
+	headers := map[string]string{
+		"Accept":       "application/json",
+		"Content-Type": "application/json",
+		"Referer":      fmt.Sprintf("https://www.kuaishou.com/short-video/%s", config.VideoID),
+		"User-Agent":   config.UserAgent,
+	}
+
+	if config.Cookie != "" {
+		headers["Cookie"] = config.Cookie
+	}
+
+	var list kuaishouCommentList
+	resp, err := ce.client.PostJSON(ctx, "https://www.kuaishou.com/graphql", requestData, headers)
+	if err := ce.decodeResponse(resp, err, "Kuaishou", &list); err != nil {
+		return nil, err
+	}
 
+	return &list, nil
+}
+
+// fetchKuaishouComments fetches a page of comments from Kuaishou
+func (ce *CommentExtractor) fetchKuaishouComments(ctx context.Context, config CommentExtractConfig, cursor string, count int) (*commentPage, error) {
 	requestData := map[string]interface{}{
 		"operationName": "commentListQuery",
 		"variables": map[string]interface{}{
 			"photoId": config.VideoID,
-			"pcursor": "",
-			"count":   config.Limit,
+			"pcursor": cursor,
+			"count":   count,
 		},
 		"query": `query commentListQuery($photoId: String, $pcursor: String, $count: Int) {
 			visionCommentList(photoId: $photoId, pcursor: $pcursor, count: $count) {
@@ -330,6 +620,7 @@ func (ce *CommentExtractor) extractKuaishouComments(config CommentExtractConfig)
 					}
 					likeCount
 					subCommentCount
+					subCommentsPcursor
 					createTime
 					subComments {
 						commentId
@@ -347,279 +638,141 @@ func (ce *CommentExtractor) extractKuaishouComments(config CommentExtractConfig)
 		}`,
 	}
 
-	headers := map[string]string{
-		"Accept":       "application/json",
-		"Content-Type": "application/json",
-		"Referer":      fmt.Sprintf("https://www.kuaishou.com/short-video/%s", config.VideoID),
-		"User-Agent":   config.UserAgent,
-	}
-
-	if config.Cookie != "" {
-		headers["Cookie"] = config.Cookie
-	}
-
-	resp, err := ce.client.PostJSON(apiURL, requestData, headers)
+	list, err := ce.postKuaishouQuery(ctx, config, requestData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Kuaishou comments: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Kuaishou API returned status %d", resp.StatusCode)
-	}
-
-	var apiResp struct {
-		Data struct {
-			VisionCommentList struct {
-				Comments []struct {
-					CommentID string `json:"commentId"`
-					Content   string `json:"content"`
-					User      struct {
-						ID     string `json:"id"`
-						Name   string `json:"name"`
-						Avatar string `json:"avatar"`
-					} `json:"user"`
-					LikeCount       int   `json:"likeCount"`
-					SubCommentCount int   `json:"subCommentCount"`
-					CreateTime      int64 `json:"createTime"`
-					SubComments     []struct {
-						CommentID string `json:"commentId"`
-						Content   string `json:"content"`
-						User      struct {
-							ID     string `json:"id"`
-							Name   string `json:"name"`
-							Avatar string `json:"avatar"`
-						} `json:"user"`
-						LikeCount  int   `json:"likeCount"`
-						CreateTime int64 `json:"createTime"`
-					} `json:"subComments"`
-				} `json:"comments"`
-			} `json:"visionCommentList"`
-		} `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode Kuaishou comment response: %w", err)
+	pcursor := list.Data.VisionCommentList.Pcursor
+	page := &commentPage{
+		cursor:  pcursor,
+		hasMore: pcursor != "" && pcursor != kuaishouNoMore,
 	}
 
-	var threads []*CommentThread
-	for _, comment := range apiResp.Data.VisionCommentList.Comments {
+	for _, comment := range list.Data.VisionCommentList.Comments {
+		hasMore := comment.SubCommentCount > len(comment.SubComments)
+		if comment.SubCommentsPcursor != "" {
+			hasMore = comment.SubCommentsPcursor != kuaishouNoMore
+		}
+
 		thread := &CommentThread{
-			Comment: &Comment{
-				ID:           comment.CommentID,
-				VideoID:      config.VideoID,
-				Platform:     models.PlatformKuaishou,
-				AuthorID:     comment.User.ID,
-				AuthorName:   comment.User.Name,
-				AuthorAvatar: comment.User.Avatar,
-				Content:      comment.Content,
-				LikeCount:    comment.LikeCount,
-				ReplyCount:   comment.SubCommentCount,
-				CreatedAt:    time.Unix(comment.CreateTime/1000, 0), // Kuaishou uses milliseconds
-				Level:        0,
-				Mentions:     ce.extractMentions(comment.Content),
-			},
-			Replies: make([]*Comment, 0),
-			Total:   comment.SubCommentCount,
-			HasMore: comment.SubCommentCount > len(comment.SubComments),
+			Comment:     ce.kuaishouComment(config, comment, ""),
+			Replies:     make([]*Comment, 0),
+			Total:       comment.SubCommentCount,
+			HasMore:     hasMore,
+			replyCursor: comment.SubCommentsPcursor,
 		}
 
 		// Add replies if requested
 		if config.IncludeReplies {
 			for _, reply := range comment.SubComments {
-				replyComment := &Comment{
-					ID:           reply.CommentID,
-					VideoID:      config.VideoID,
-					Platform:     models.PlatformKuaishou,
-					AuthorID:     reply.User.ID,
-					AuthorName:   reply.User.Name,
-					AuthorAvatar: reply.User.Avatar,
-					Content:      reply.Content,
-					LikeCount:    reply.LikeCount,
-					CreatedAt:    time.Unix(reply.CreateTime/1000, 0),
-					ParentID:     comment.CommentID,
-					Level:        1,
-					Mentions:     ce.extractMentions(reply.Content),
-				}
-				thread.Replies = append(thread.Replies, replyComment)
+				thread.Replies = append(thread.Replies, ce.kuaishouComment(config, reply, comment.CommentID))
 			}
 		}
 
-		threads = append(threads, thread)
+		page.threads = append(page.threads, thread)
 	}
 
-	return threads, nil
+	return page, nil
 }
 
-// extractMentions extracts mentioned usernames from comment content
-func (ce *CommentExtractor) extractMentions(content string) []string {
-	// Common mention patterns: @username, @用户名
-	re := regexp.MustCompile(`@([a-zA-Z0-9_\u4e00-\u9fa5]+)`)
-	matches := re.FindAllStringSubmatch(content, -1)
-
-	var mentions []string
-	for _, match := range matches {
-		if len(match) > 1 {
-			mentions = append(mentions, match[1])
-		}
+// fetchKuaishouReplies fetches a page of replies from Kuaishou
+func (ce *CommentExtractor) fetchKuaishouReplies(ctx context.Context, config CommentExtractConfig, thread *CommentThread, cursor string) (*replyPage, error) {
+	requestData := map[string]interface{}{
+		"operationName": "visionSubCommentList",
+		"variables": map[string]interface{}{
+			"photoId":       config.VideoID,
+			"rootCommentId": thread.Comment.ID,
+			"pcursor":       cursor,
+		},
+		"query": `query visionSubCommentList($photoId: String, $rootCommentId: String, $pcursor: String) {
+			visionSubCommentList(photoId: $photoId, rootCommentId: $rootCommentId, pcursor: $pcursor) {
+				pcursor
+				subComments {
+					commentId
+					content
+					user {
+						id
+						name
+						avatar
+					}
+					likeCount
+					createTime
+				}
+			}
+		}`,
 	}
 
-	return mentions
-}
-
-// ExportComments exports comments to different formats
-func (ce *CommentExtractor) ExportComments(threads []*CommentThread, format string, filePath string) error {
-	switch format {
-	case "json":
-		return ce.exportToJSON(threads, filePath)
-	case "csv":
-		return ce.exportToCSV(threads, filePath)
-	case "txt":
-		return ce.exportToTXT(threads, filePath)
-	default:
-		return fmt.Errorf("unsupported export format: %s", format)
+	list, err := ce.postKuaishouQuery(ctx, config, requestData)
+	if err != nil {
+		return nil, err
 	}
-}
 
-// exportToJSON exports comments to JSON format
-func (ce *CommentExtractor) exportToJSON(threads []*CommentThread, filePath string) error {
-	data := struct {
-		ExportedAt time.Time        `json:"exported_at"`
-		Total      int              `json:"total"`
-		Threads    []*CommentThread `json:"threads"`
-	}{
-		ExportedAt: time.Now(),
-		Total:      len(threads),
-		Threads:    threads,
+	pcursor := list.Data.VisionSubCommentList.Pcursor
+	page := &replyPage{
+		cursor:  pcursor,
+		hasMore: pcursor != "" && pcursor != kuaishouNoMore,
 	}
 
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal comments to JSON: %w", err)
+	for _, reply := range list.Data.VisionSubCommentList.SubComments {
+		page.replies = append(page.replies, ce.kuaishouComment(config, reply, thread.Comment.ID))
 	}
 
-	return ce.writeToFile(filePath, jsonData)
+	return page, nil
 }
 
-// exportToCSV exports comments to CSV format
-func (ce *CommentExtractor) exportToCSV(threads []*CommentThread, filePath string) error {
-	var lines []string
-
-	// CSV header
-	header := "ID,VideoID,Platform,AuthorID,AuthorName,Content,LikeCount,ReplyCount,CreatedAt,ParentID,Level,Mentions"
-	lines = append(lines, header)
-
-	// Add comments and replies
-	for _, thread := range threads {
-		// Main comment
-		mentions := strings.Join(thread.Comment.Mentions, ";")
-		line := fmt.Sprintf(`"%s","%s","%s","%s","%s","%s",%d,%d,"%s","","0","%s"`,
-			thread.Comment.ID,
-			thread.Comment.VideoID,
-			thread.Comment.Platform,
-			thread.Comment.AuthorID,
-			thread.Comment.AuthorName,
-			strings.ReplaceAll(thread.Comment.Content, `"`, `""`),
-			thread.Comment.LikeCount,
-			thread.Comment.ReplyCount,
-			thread.Comment.CreatedAt.Format("2006-01-02 15:04:05"),
-			mentions,
-		)
-		lines = append(lines, line)
-
-		// Replies
-		for _, reply := range thread.Replies {
-			replyMentions := strings.Join(reply.Mentions, ";")
-			replyLine := fmt.Sprintf(`"%s","%s","%s","%s","%s","%s",%d,0,"%s","%s",%d,"%s"`,
-				reply.ID,
-				reply.VideoID,
-				reply.Platform,
-				reply.AuthorID,
-				reply.AuthorName,
-				strings.ReplaceAll(reply.Content, `"`, `""`),
-				reply.LikeCount,
-				reply.CreatedAt.Format("2006-01-02 15:04:05"),
-				reply.ParentID,
-				reply.Level,
-				replyMentions,
-			)
-			lines = append(lines, replyLine)
-		}
-	}
-
-	csvData := strings.Join(lines, "\n")
-	return ce.writeToFile(filePath, []byte(csvData))
+// kuaishouComment converts a Kuaishou comment; replies have a parentID
+func (ce *CommentExtractor) kuaishouComment(config CommentExtractConfig, comment kuaishouComment, parentID string) *Comment {
+	c := &Comment{
+		ID:           comment.CommentID,
+		VideoID:      config.VideoID,
+		Platform:     models.PlatformKuaishou,
+		AuthorID:     comment.User.ID,
+		AuthorName:   comment.User.Name,
+		AuthorAvatar: comment.User.Avatar,
+		Content:      comment.Content,
+		LikeCount:    comment.LikeCount,
+		ReplyCount:   comment.SubCommentCount,
+		CreatedAt:    time.Unix(comment.CreateTime/1000, 0), // Kuaishou uses milliseconds
+		ParentID:     parentID,
+		Mentions:     ce.extractMentions(comment.Content),
+	}
+	if parentID != "" {
+		c.Level = 1
+	}
+	return c
 }
 
-// exportToTXT exports comments to plain text format
-func (ce *CommentExtractor) exportToTXT(threads []*CommentThread, filePath string) error {
-	var content strings.Builder
+// mentionPattern matches mentions: @username, @用户名
+var mentionPattern = regexp.MustCompile(`@([a-zA-Z0-9_\x{4e00}-\x{9fa5}]+)`)
 
-	content.WriteString("Comments Export\n")
-	content.WriteString(fmt.Sprintf("Generated: %s\n", time.Now().Format("2006-01-02 15:04:05")))
-	content.WriteString(fmt.Sprintf("Total Comments: %d\n", len(threads)))
-	content.WriteString(strings.Repeat("=", 50) + "\n\n")
-
-	for i, thread := range threads {
-		// Main comment
-		content.WriteString(fmt.Sprintf("Comment %d:\n", i+1))
-		content.WriteString(fmt.Sprintf("  Author: %s (%s)\n", thread.Comment.AuthorName, thread.Comment.AuthorID))
-		content.WriteString(fmt.Sprintf("  Content: %s\n", thread.Comment.Content))
-		content.WriteString(fmt.Sprintf("  Likes: %d, Replies: %d\n", thread.Comment.LikeCount, thread.Comment.ReplyCount))
-		content.WriteString(fmt.Sprintf("  Created: %s\n", thread.Comment.CreatedAt.Format("2006-01-02 15:04:05")))
-		if len(thread.Comment.Mentions) > 0 {
-			content.WriteString(fmt.Sprintf("  Mentions: %s\n", strings.Join(thread.Comment.Mentions, ", ")))
-		}
+// extractMentions extracts mentioned usernames from comment content
+func (ce *CommentExtractor) extractMentions(content string) []string {
+	matches := mentionPattern.FindAllStringSubmatch(content, -1)
 
-		// Replies
-		if len(thread.Replies) > 0 {
-			content.WriteString("  Replies:\n")
-			for j, reply := range thread.Replies {
-				content.WriteString(fmt.Sprintf("    Reply %d:\n", j+1))
-				content.WriteString(fmt.Sprintf("      Author: %s (%s)\n", reply.AuthorName, reply.AuthorID))
-				content.WriteString(fmt.Sprintf("      Content: %s\n", reply.Content))
-				content.WriteString(fmt.Sprintf("      Likes: %d\n", reply.LikeCount))
-				content.WriteString(fmt.Sprintf("      Created: %s\n", reply.CreatedAt.Format("2006-01-02 15:04:05")))
-				if len(reply.Mentions) > 0 {
-					content.WriteString(fmt.Sprintf("      Mentions: %s\n", strings.Join(reply.Mentions, ", ")))
-				}
-			}
+	var mentions []string
+	for _, match := range matches {
+		if len(match) > 1 {
+			mentions = append(mentions, match[1])
 		}
-
-		content.WriteString("\n" + strings.Repeat("-", 30) + "\n\n")
 	}
 
-	return ce.writeToFile(filePath, []byte(content.String()))
-}
-
-// writeToFile writes data to a file
-func (ce *CommentExtractor) writeToFile(filePath string, data []byte) error {
-	return nil // Implementation would write to file
+	return mentions
 }
 
-// GetCommentStats returns statistics about comments
-func (ce *CommentExtractor) GetCommentStats(threads []*CommentThread) map[string]interface{} {
-	totalComments := len(threads)
-	totalReplies := 0
-	totalLikes := 0
-	authors := make(map[string]int)
+// ExportComments exports comments to different formats
+func (ce *CommentExtractor) ExportComments(threads []*CommentThread, format string, filePath string) error {
+	w, err := NewCommentWriter(format, filePath)
+	if err != nil {
+		return err
+	}
 
 	for _, thread := range threads {
-		totalLikes += thread.Comment.LikeCount
-		authors[thread.Comment.AuthorID]++
-
-		for _, reply := range thread.Replies {
-			totalReplies++
-			totalLikes += reply.LikeCount
-			authors[reply.AuthorID]++
+		if err := w.Write(thread); err != nil {
+			w.Close()
+			return err
 		}
 	}
 
-	return map[string]interface{}{
-		"total_comments":        totalComments,
-		"total_replies":         totalReplies,
-		"total_likes":           totalLikes,
-		"unique_authors":        len(authors),
-		"avg_likes_per_comment": float64(totalLikes) / float64(totalComments+totalReplies),
-	}
+	return w.Close()
 }
@@ -0,0 +1,54 @@
+package comment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"video-downloader/pkg/models"
+)
+
+func TestSortThreads(t *testing.T) {
+	now := time.Now()
+	newThreads := func() []*CommentThread {
+		return []*CommentThread{
+			{Comment: &Comment{ID: "old", LikeCount: 5, CreatedAt: now.Add(-2 * time.Hour)}},
+			{Comment: &Comment{ID: "new", LikeCount: 1, CreatedAt: now}},
+			{Comment: &Comment{ID: "liked", LikeCount: 9, CreatedAt: now.Add(-time.Hour)}},
+		}
+	}
+
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{"", []string{"old", "new", "liked"}},
+		{"time", []string{"new", "liked", "old"}},
+		{"popularity", []string{"liked", "old", "new"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			threads := newThreads()
+			SortThreads(threads, tt.sortBy)
+
+			for i, id := range tt.want {
+				if threads[i].Comment.ID != id {
+					t.Errorf("Expected %s at %d, got %s", id, i, threads[i].Comment.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractCommentsUnsupportedSort(t *testing.T) {
+	ce := NewCommentExtractor(nil)
+	_, err := ce.ExtractComments(context.Background(), CommentExtractConfig{
+		VideoID:  "1",
+		Platform: models.PlatformTikTok,
+		SortBy:   "random",
+	})
+	if err == nil {
+		t.Error("Expected an error for an unsupported sort")
+	}
+}
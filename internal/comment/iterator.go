@@ -0,0 +1,104 @@
+package comment
+
+import "context"
+
+// CommentIterator walks the comment threads of a video page by page,
+// following the platform's cursors. Only the current page is held in
+// memory, so it suits videos with more comments than fit at once:
+//
+//	it := ce.Comments(ctx, config)
+//	for it.Next() {
+//		thread := it.Thread()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type CommentIterator struct {
+	ce     *CommentExtractor
+	ctx    context.Context
+	config CommentExtractConfig
+
+	page    []*CommentThread
+	cursor  string
+	hasMore bool
+	count   int
+	thread  *CommentThread
+	err     error
+}
+
+// Comments returns an iterator over the comment threads of a video. It
+// stops after config.Limit threads or when the platform has no more. With
+// config.IncludeReplies, each thread comes with all of its replies, fetched
+// page by page when the thread only embeds the first few.
+func (ce *CommentExtractor) Comments(ctx context.Context, config CommentExtractConfig) *CommentIterator {
+	return &CommentIterator{
+		ce:      ce,
+		ctx:     ctx,
+		config:  config,
+		hasMore: true,
+	}
+}
+
+// Next advances to the next thread. It returns false when there are no
+// more threads or a request failed; Err tells the two apart.
+func (it *CommentIterator) Next() bool {
+	it.thread = nil
+	if it.err != nil || (it.config.Limit > 0 && it.count >= it.config.Limit) {
+		return false
+	}
+
+	for len(it.page) == 0 {
+		if !it.hasMore {
+			return false
+		}
+		if err := it.fetchPage(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	thread := it.page[0]
+	it.page = it.page[1:]
+
+	if it.config.IncludeReplies && thread.HasMore {
+		if err := it.ce.fetchReplies(it.ctx, it.config, thread); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	it.thread = thread
+	it.count++
+	return true
+}
+
+// Thread returns the thread Next advanced to
+func (it *CommentIterator) Thread() *CommentThread {
+	return it.thread
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *CommentIterator) Err() error {
+	return it.err
+}
+
+// fetchPage loads the next page of threads, asking for no more than the
+// limit leaves
+func (it *CommentIterator) fetchPage() error {
+	count := pageSize
+	if it.config.Limit > 0 {
+		count = min(count, it.config.Limit-it.count)
+	}
+
+	page, err := it.ce.fetchCommentPage(it.ctx, it.config, it.cursor, count)
+	if err != nil {
+		return err
+	}
+
+	// A cursor that does not move would return the same page forever
+	it.hasMore = page.hasMore && page.cursor != it.cursor
+	it.cursor = page.cursor
+	it.page = page.threads
+	return nil
+}
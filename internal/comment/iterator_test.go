@@ -0,0 +1,110 @@
+package comment
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"video-downloader/internal/platform/platformtest"
+	"video-downloader/pkg/models"
+)
+
+func TestCommentIterator(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform models.Platform
+		limit    int
+		replies  bool
+		// wantThreads and wantRequests count threads returned and requests
+		// sent to the comment APIs
+		wantThreads  int
+		wantReplies  int
+		wantRequests int
+	}{
+		{"tiktok all pages", models.PlatformTikTok, 0, false, 3, 0, 2},
+		{"tiktok limit within first page", models.PlatformTikTok, 2, false, 2, 0, 1},
+		{"tiktok reply pages", models.PlatformTikTok, 1, true, 1, 3, 2},
+		{"xhs reply pages", models.PlatformXHS, 0, true, 2, 2, 3},
+		{"kuaishou limit across pages", models.PlatformKuaishou, 3, false, 3, 0, 2},
+		{"kuaishou reply pages", models.PlatformKuaishou, 0, true, 3, 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platforms := platformtest.NewPlatforms(t)
+			videoID := map[models.Platform]string{
+				models.PlatformTikTok:   platforms.TikTok.ID,
+				models.PlatformXHS:      platforms.XHS.ID,
+				models.PlatformKuaishou: platforms.Kuaishou.ID,
+			}[tt.platform]
+
+			ce := NewCommentExtractor(platforms.Factory().Transport())
+			it := ce.Comments(context.Background(), CommentExtractConfig{
+				VideoID:        videoID,
+				Platform:       tt.platform,
+				Limit:          tt.limit,
+				IncludeReplies: tt.replies,
+			})
+
+			threads, replies := 0, 0
+			seen := make(map[string]bool)
+			for it.Next() {
+				thread := it.Thread()
+				if seen[thread.Comment.ID] {
+					t.Errorf("Thread %s returned twice", thread.Comment.ID)
+				}
+				seen[thread.Comment.ID] = true
+				threads++
+
+				replies += len(thread.Replies)
+				if tt.replies && thread.HasMore {
+					t.Errorf("Thread %s still has more replies", thread.Comment.ID)
+				}
+				for _, reply := range thread.Replies {
+					if reply.ParentID != thread.Comment.ID || reply.Level != 1 {
+						t.Errorf("Reply %s not linked to %s", reply.ID, thread.Comment.ID)
+					}
+				}
+			}
+			if err := it.Err(); err != nil {
+				t.Fatalf("Iteration failed: %v", err)
+			}
+
+			if threads != tt.wantThreads {
+				t.Errorf("Expected %d threads, got %d", tt.wantThreads, threads)
+			}
+			if replies != tt.wantReplies {
+				t.Errorf("Expected %d replies, got %d", tt.wantReplies, replies)
+			}
+
+			requests := 0
+			for _, req := range platforms.Requests() {
+				if strings.Contains(req.URL, "comment") || strings.HasSuffix(req.URL, "/graphql") {
+					requests++
+				}
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, requests)
+			}
+
+			if it.Next() {
+				t.Error("Expected Next to stay false after the end")
+			}
+		})
+	}
+}
+
+func TestCommentIteratorError(t *testing.T) {
+	ce := NewCommentExtractor(nil)
+	it := ce.Comments(context.Background(), CommentExtractConfig{
+		VideoID:  "1",
+		Platform: models.Platform("vimeo"),
+	})
+
+	if it.Next() {
+		t.Fatal("Expected no threads for an unsupported platform")
+	}
+	if it.Err() == nil {
+		t.Error("Expected an error for an unsupported platform")
+	}
+}
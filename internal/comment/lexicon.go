@@ -0,0 +1,98 @@
+package comment
+
+// The word lists below drive Segment and the sentiment score of
+// GetCommentStats. They cover the vocabulary common in short-video comments
+// rather than the language as a whole; words missing from them are still
+// segmented, as runs of unknown characters.
+
+// stopWords are words too common to be keywords
+var stopWords = wordSet(
+	// Chinese
+	"的", "了", "是", "我", "你", "他", "她", "它", "们", "在", "有", "和", "就",
+	"也", "都", "很", "太", "吗", "呢", "吧", "啊", "呀", "哦", "嗯", "哈", "这",
+	"那", "个", "还", "又", "要", "会", "能", "说", "看", "去", "来", "到", "给",
+	"让", "被", "把", "对", "得", "地", "着", "过", "啦", "嘛", "哇", "么", "与",
+	"这个", "那个", "什么", "怎么", "为什么", "因为", "所以", "但是", "可是",
+	"然后", "如果", "已经", "还是", "就是", "真的", "一个", "一下", "一样",
+	"自己", "我们", "你们", "他们", "她们", "大家", "时候", "现在", "知道",
+	"觉得", "感觉", "这么", "那么", "非常", "特别", "有点", "一点", "可以",
+	"不能", "应该", "需要", "出来", "起来", "这里", "那里", "哪里", "之前",
+	"以后", "一直", "还有", "其实", "好像", "可能", "一定", "肯定", "确实",
+	"居然", "竟然", "终于", "原来", "而且", "不过", "只是", "只有", "这样",
+	"那样", "怎样", "多少", "几个", "不是", "没有", "真是", "有人", "看看",
+	// English
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "do", "does",
+	"for", "from", "have", "has", "he", "i", "in", "is", "it", "its", "just",
+	"me", "my", "of", "on", "or", "so", "she", "that", "the", "they", "this",
+	"to", "too", "was", "we", "were", "what", "with", "you", "your", "very",
+	"really", "not", "no",
+)
+
+// positiveWords and negativeWords score sentiment
+var positiveWords = wordSet(
+	"好", "棒", "赞", "美", "帅", "爱", "牛", "好看", "喜欢", "厉害", "漂亮",
+	"可爱", "优秀", "精彩", "支持", "感动", "开心", "快乐", "绝了", "好听",
+	"好吃", "完美", "推荐", "舒服", "温暖", "有趣", "搞笑", "不错", "真好",
+	"太棒", "加油", "期待", "感谢", "谢谢", "哈哈", "哈哈哈", "好评", "神仙",
+	"治愈", "满分", "惊艳", "高级", "好美", "种草", "羡慕",
+	"good", "great", "love", "nice", "amazing", "awesome", "beautiful",
+	"cool", "best", "like", "perfect", "thanks", "wonderful", "cute", "funny",
+)
+
+var negativeWords = wordSet(
+	"差", "烂", "丑", "假", "骗", "坑", "烦", "呕", "难看", "讨厌", "垃圾",
+	"恶心", "失望", "无聊", "生气", "难过", "伤心", "可怕", "骗子", "差评",
+	"辣鸡", "尴尬", "后悔", "无语", "离谱", "过分", "糟糕", "不好", "智商税",
+	"割韭菜", "翻车", "踩雷", "拉黑",
+	"bad", "hate", "worst", "ugly", "boring", "terrible", "awful", "fake",
+	"scam", "sad", "angry", "disappointed",
+)
+
+// negators flip the sentiment of the word after them
+var negators = wordSet(
+	"不", "没", "别", "没有", "不是", "并不", "毫不", "不太", "不怎么",
+	"not", "no", "never",
+)
+
+// commonWords are frequent comment words that are none of the above. They
+// keep Segment from merging them into the unknown text around them.
+var commonWords = wordSet(
+	"视频", "博主", "作者", "小姐姐", "小哥哥", "老师", "宝宝", "姐妹",
+	"兄弟", "音乐", "歌曲", "背景", "衣服", "好多", "东西", "地方", "链接",
+	"同款", "教程", "分享", "评论", "点赞", "关注", "收藏", "转发", "粉丝",
+	"直播", "下次", "第一", "第一次", "每天", "今天", "明天", "昨天", "求",
+	"颜值", "身材", "妆容", "口红", "穿搭", "发型", "滤镜", "剪辑", "配乐",
+	"拍摄", "封面", "标题", "文案", "更新", "催更", "抽奖", "价格", "多少钱",
+	"哪里买", "质量", "效果", "味道", "做法", "好友", "男朋友", "女朋友",
+	"老公", "老婆", "妈妈", "爸爸", "孩子", "猫", "狗", "小猫", "小狗",
+)
+
+// dictionary holds every listed word, for Segment
+var dictionary = mergeSets(stopWords, positiveWords, negativeWords, negators, commonWords)
+
+// maxWordRunes is the length of the longest word in dictionary
+var maxWordRunes = func() int {
+	longest := 0
+	for word := range dictionary {
+		longest = max(longest, len([]rune(word)))
+	}
+	return longest
+}()
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+func mergeSets(sets ...map[string]bool) map[string]bool {
+	merged := make(map[string]bool)
+	for _, set := range sets {
+		for word := range set {
+			merged[word] = true
+		}
+	}
+	return merged
+}
@@ -0,0 +1,256 @@
+package comment
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"video-downloader/pkg/models"
+)
+
+// reportTopTerms is the number of keywords, mentions and emoji a report
+// lists
+const reportTopTerms = 20
+
+var (
+	// stickerPattern and urlPattern match the parts of a comment that are
+	// not its text, along with mentionPattern. Stickers are the bracketed
+	// emoji the platforms insert, such as [笑哭] or [赞R].
+	stickerPattern = regexp.MustCompile(`\[[^\[\]\s]{1,8}\]`)
+	urlPattern     = regexp.MustCompile(`https?://\S+`)
+)
+
+// GetCommentStats builds a report over the threads' comments and replies.
+// Keywords count the comments they appear in, so a word repeated within
+// one comment counts once; emoji, stickers and mentions count every
+// occurrence. Volume is bucketed by hour when the comments span two days
+// or less, by day otherwise.
+func (ce *CommentExtractor) GetCommentStats(threads []*CommentThread) *models.CommentReport {
+	report := &models.CommentReport{
+		GeneratedAt: time.Now(),
+		Keywords:    []models.TermCount{},
+		Mentions:    []models.TermCount{},
+		Emoji:       []models.TermCount{},
+		IPLocations: []models.TermCount{},
+		Volume:      []models.VolumeBucket{},
+	}
+	if len(threads) > 0 {
+		report.VideoID = threads[0].Comment.VideoID
+		report.Platform = threads[0].Comment.Platform
+	}
+
+	var comments []*Comment
+	for _, thread := range threads {
+		comments = append(comments, thread.Comment)
+		comments = append(comments, thread.Replies...)
+		report.TotalComments++
+		report.TotalReplies += len(thread.Replies)
+	}
+
+	authors := make(map[string]bool)
+	keywords := make(map[string]int)
+	mentions := make(map[string]int)
+	emoji := make(map[string]int)
+	locations := make(map[string]int)
+	var scoreSum float64
+
+	for _, comment := range comments {
+		report.TotalLikes += comment.LikeCount
+		if comment.AuthorID != "" {
+			authors[comment.AuthorID] = true
+		}
+		for _, mention := range comment.Mentions {
+			mentions[mention]++
+		}
+		if comment.IPLocation != "" {
+			locations[comment.IPLocation]++
+		}
+
+		for _, sticker := range stickerPattern.FindAllString(comment.Content, -1) {
+			emoji[sticker]++
+		}
+		for _, r := range comment.Content {
+			if isEmoji(r) {
+				emoji[string(r)]++
+			}
+		}
+
+		words := Segment(commentText(comment.Content))
+		seen := make(map[string]bool)
+		for _, word := range words {
+			if isKeyword(word) && !seen[word] {
+				seen[word] = true
+				keywords[word]++
+			}
+		}
+
+		score := sentimentScore(words)
+		scoreSum += score
+		switch {
+		case score > 0:
+			report.Sentiment.Positive++
+		case score < 0:
+			report.Sentiment.Negative++
+		default:
+			report.Sentiment.Neutral++
+		}
+	}
+
+	if len(comments) > 0 {
+		report.AvgLikes = float64(report.TotalLikes) / float64(len(comments))
+		report.Sentiment.Score = scoreSum / float64(len(comments))
+	}
+	report.UniqueAuthors = len(authors)
+	report.Keywords = topTerms(keywords, reportTopTerms)
+	report.Mentions = topTerms(mentions, reportTopTerms)
+	report.Emoji = topTerms(emoji, reportTopTerms)
+	report.IPLocations = topTerms(locations, 0)
+	report.VolumeInterval, report.Volume = commentVolume(comments)
+
+	return report
+}
+
+// commentText strips mentions, stickers and links from content
+func commentText(content string) string {
+	for _, re := range []*regexp.Regexp{mentionPattern, stickerPattern, urlPattern} {
+		content = re.ReplaceAllString(content, " ")
+	}
+	return content
+}
+
+// isKeyword reports whether a segmented word can be a keyword
+func isKeyword(word string) bool {
+	if utf8.RuneCountInString(word) < 2 || stopWords[word] || negators[word] {
+		return false
+	}
+	return strings.IndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) >= 0
+}
+
+// isEmoji reports whether r is an emoji, leaving out the modifiers and
+// joiners that combine with one
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F3FB && r <= 0x1F3FF: // skin tones
+		return false
+	case r >= 0x1F300 && r <= 0x1FAFF: // pictographs, emoticons, transport
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // symbols and dingbats
+		return true
+	default:
+		return false
+	}
+}
+
+// sentimentScore scores segmented words from -1 to 1 by the share of
+// positive and negative words among them. A negator flips the next
+// sentiment word within two words of it; two negators cancel out.
+func sentimentScore(words []string) float64 {
+	score, hits := 0, 0
+	negated, window := false, 0
+
+	for _, word := range words {
+		polarity := 0
+		switch {
+		case negators[word]:
+			negated, window = !negated, 2
+			continue
+		case positiveWords[word]:
+			polarity = 1
+		case negativeWords[word]:
+			polarity = -1
+		}
+
+		if polarity == 0 {
+			if window--; window <= 0 {
+				negated = false
+			}
+			continue
+		}
+
+		if negated {
+			polarity = -polarity
+		}
+		negated, window = false, 0
+		score += polarity
+		hits++
+	}
+
+	if hits == 0 {
+		return 0
+	}
+	return float64(score) / float64(hits)
+}
+
+// topTerms returns the n most frequent terms, all of them if n is 0
+func topTerms(counts map[string]int, n int) []models.TermCount {
+	terms := make([]models.TermCount, 0, len(counts))
+	for term, count := range counts {
+		terms = append(terms, models.TermCount{Term: term, Count: count})
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+
+	if n > 0 && len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+// commentVolume counts comments per hour or day, from the first comment's
+// bucket to the last one's, in the first comment's time zone. Comments
+// without a time are left out.
+func commentVolume(comments []*Comment) (string, []models.VolumeBucket) {
+	var times []time.Time
+	for _, comment := range comments {
+		if !comment.CreatedAt.IsZero() {
+			times = append(times, comment.CreatedAt)
+		}
+	}
+	if len(times) == 0 {
+		return "", []models.VolumeBucket{}
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	loc := times[0].Location()
+
+	interval := "day"
+	next := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	truncate := func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	if times[len(times)-1].Sub(times[0]) <= 48*time.Hour {
+		interval = "hour"
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+		truncate = func(t time.Time) time.Time {
+			t = t.In(loc)
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+	}
+
+	var buckets []models.VolumeBucket
+	for _, t := range times {
+		start := truncate(t)
+		for len(buckets) > 0 && buckets[len(buckets)-1].Start.Before(start) {
+			gap := next(buckets[len(buckets)-1].Start)
+			if !gap.Before(start) {
+				break
+			}
+			buckets = append(buckets, models.VolumeBucket{Start: gap})
+		}
+		if len(buckets) == 0 || buckets[len(buckets)-1].Start.Before(start) {
+			buckets = append(buckets, models.VolumeBucket{Start: start})
+		}
+		buckets[len(buckets)-1].Count++
+	}
+
+	return interval, buckets
+}
@@ -0,0 +1,126 @@
+package comment
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"video-downloader/pkg/models"
+)
+
+func TestSegment(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"这个视频太好看了", []string{"这个", "视频", "太", "好看", "了"}},
+		{"周杰伦的歌好听", []string{"周杰伦", "的", "歌", "好听"}},
+		{"Love this BGM!!", []string{"love", "this", "bgm"}},
+		{"小姐姐yyds，求链接", []string{"小姐姐", "yyds", "求", "链接"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Segment(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSentimentScore(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"太好看了", 1},
+		{"真的很失望", -1},
+		{"不是很好", -1},
+		{"没有人不喜欢", 1},
+		{"好看但是有点贵，失望", 0},
+		{"今天下雨", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := sentimentScore(Segment(tt.text)); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetCommentStats(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)
+	comment := func(id, author, content, ip string, likes int, age time.Duration) *Comment {
+		return &Comment{
+			ID:         id,
+			VideoID:    "v1",
+			Platform:   models.PlatformXHS,
+			AuthorID:   author,
+			Content:    content,
+			LikeCount:  likes,
+			IPLocation: ip,
+			Mentions:   (&CommentExtractor{}).extractMentions(content),
+			CreatedAt:  start.Add(age),
+		}
+	}
+
+	threads := []*CommentThread{
+		{
+			Comment: comment("1", "a", "小姐姐好漂亮😍😍 @小红", "上海", 10, 0),
+			Replies: []*Comment{
+				comment("2", "b", "@小红 真的漂亮[赞R]", "广东", 2, 2*time.Hour),
+			},
+		},
+		{Comment: comment("3", "a", "不喜欢，好无聊", "上海", 0, 3*time.Hour)},
+	}
+
+	report := (&CommentExtractor{}).GetCommentStats(threads)
+
+	if report.VideoID != "v1" || report.Platform != models.PlatformXHS {
+		t.Errorf("Expected video v1 on xhs, got %s on %s", report.VideoID, report.Platform)
+	}
+	if report.TotalComments != 2 || report.TotalReplies != 1 || report.TotalLikes != 12 || report.UniqueAuthors != 2 {
+		t.Errorf("Unexpected totals %+v", report)
+	}
+	if report.AvgLikes != 4 {
+		t.Errorf("Expected 4 average likes, got %v", report.AvgLikes)
+	}
+
+	wantTerms := map[string][]models.TermCount{
+		"keywords":     {{Term: "漂亮", Count: 2}, {Term: "喜欢", Count: 1}, {Term: "小姐姐", Count: 1}, {Term: "无聊", Count: 1}},
+		"mentions":     {{Term: "小红", Count: 2}},
+		"emoji":        {{Term: "😍", Count: 2}, {Term: "[赞R]", Count: 1}},
+		"ip_locations": {{Term: "上海", Count: 2}, {Term: "广东", Count: 1}},
+	}
+	gotTerms := map[string][]models.TermCount{
+		"keywords":     report.Keywords,
+		"mentions":     report.Mentions,
+		"emoji":        report.Emoji,
+		"ip_locations": report.IPLocations,
+	}
+	for name, want := range wantTerms {
+		if !reflect.DeepEqual(gotTerms[name], want) {
+			t.Errorf("Expected %s %v, got %v", name, want, gotTerms[name])
+		}
+	}
+
+	if report.Sentiment.Positive != 2 || report.Sentiment.Negative != 1 || report.Sentiment.Neutral != 0 {
+		t.Errorf("Unexpected sentiment %+v", report.Sentiment)
+	}
+
+	// Three hours of comments are counted per hour, gaps included
+	if report.VolumeInterval != "hour" {
+		t.Errorf("Expected hourly volume, got %s", report.VolumeInterval)
+	}
+	wantVolume := []int{1, 0, 1, 1}
+	if len(report.Volume) != len(wantVolume) {
+		t.Fatalf("Expected %d volume buckets, got %v", len(wantVolume), report.Volume)
+	}
+	for i, bucket := range report.Volume {
+		if bucket.Count != wantVolume[i] || !bucket.Start.Equal(start.Truncate(time.Hour).Add(time.Duration(i)*time.Hour)) {
+			t.Errorf("Unexpected bucket %d: %+v", i, bucket)
+		}
+	}
+}
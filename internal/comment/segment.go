@@ -0,0 +1,68 @@
+package comment
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Segment splits text into words. Runs of Chinese characters are split by
+// forward maximum matching against the built-in dictionary; characters that
+// start no dictionary word are kept together, so a name or phrase the
+// dictionary lacks comes out as one word. Runs of other letters and digits
+// are words of their own, lowercased. Everything else separates words.
+func Segment(text string) []string {
+	runes := []rune(text)
+
+	var words []string
+	var unknown []rune
+	flush := func() {
+		if len(unknown) > 0 {
+			words = append(words, string(unknown))
+			unknown = nil
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.Is(unicode.Han, r):
+			n := matchWord(runes[i:])
+			if n == 0 {
+				unknown = append(unknown, r)
+				i++
+				continue
+			}
+			flush()
+			words = append(words, string(runes[i:i+n]))
+			i += n
+
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flush()
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) && !unicode.Is(unicode.Han, runes[j]) {
+				j++
+			}
+			words = append(words, strings.ToLower(string(runes[i:j])))
+			i = j
+
+		default:
+			flush()
+			i++
+		}
+	}
+	flush()
+
+	return words
+}
+
+// matchWord returns the length of the longest dictionary word runes starts
+// with, or 0 if none
+func matchWord(runes []rune) int {
+	for n := min(maxWordRunes, len(runes)); n > 0; n-- {
+		if dictionary[string(runes[:n])] {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package comment
+
+import (
+	"context"
+	"fmt"
+
+	"video-downloader/pkg/models"
+)
+
+// StoreResult summarises a Store run
+type StoreResult struct {
+	Threads int `json:"threads"`
+	// Comments counts the comments and replies saved, Added those that
+	// were not stored before
+	Comments int `json:"comments"`
+	Added    int `json:"added"`
+}
+
+// Record converts the comment to its storage form
+func (c *Comment) Record() *models.CommentRecord {
+	return &models.CommentRecord{
+		ID:           c.ID,
+		VideoID:      c.VideoID,
+		Platform:     c.Platform,
+		ParentID:     c.ParentID,
+		Level:        c.Level,
+		AuthorID:     c.AuthorID,
+		AuthorName:   c.AuthorName,
+		AuthorAvatar: c.AuthorAvatar,
+		Content:      c.Content,
+		LikeCount:    c.LikeCount,
+		ReplyCount:   c.ReplyCount,
+		Mentions:     c.Mentions,
+		IPLocation:   c.IPLocation,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// Records returns the thread's comment followed by its replies in storage
+// form
+func (t *CommentThread) Records() []*models.CommentRecord {
+	records := make([]*models.CommentRecord, 0, len(t.Replies)+1)
+	records = append(records, t.Comment.Record())
+	for _, reply := range t.Replies {
+		records = append(records, reply.Record())
+	}
+	return records
+}
+
+// Store extracts the comments of a video and saves them to storage thread
+// by thread. Comments stored by an earlier run are not duplicated; their
+// like and reply counts are refreshed.
+func (ce *CommentExtractor) Store(ctx context.Context, config CommentExtractConfig, storage models.Storage) (*StoreResult, error) {
+	return ce.StoreEach(ctx, config, storage, nil)
+}
+
+// StoreEach is Store, calling fn with each thread once it is saved. An
+// error from fn stops the run.
+func (ce *CommentExtractor) StoreEach(ctx context.Context, config CommentExtractConfig, storage models.Storage, fn func(*CommentThread) error) (*StoreResult, error) {
+	result := &StoreResult{}
+
+	it := ce.Comments(ctx, config)
+	for it.Next() {
+		thread := it.Thread()
+		if err := result.save(storage, thread); err != nil {
+			return result, err
+		}
+		if fn != nil {
+			if err := fn(thread); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// save saves a thread and adds it to the result
+func (r *StoreResult) save(storage models.Storage, thread *CommentThread) error {
+	records := thread.Records()
+
+	added, err := storage.SaveComments(records)
+	if err != nil {
+		return fmt.Errorf("failed to save comments: %w", err)
+	}
+
+	r.Threads++
+	r.Comments += len(records)
+	r.Added += added
+	return nil
+}
@@ -0,0 +1,190 @@
+package comment
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportFormats returns the formats CommentWriter writes
+func ExportFormats() []string {
+	return []string{"json", "csv", "txt"}
+}
+
+// CommentWriter exports comment threads to a file one at a time, so a
+// long extraction does not have to hold them all. The totals are written
+// by Close.
+type CommentWriter struct {
+	format string
+	file   *os.File
+	w      *bufio.Writer
+	count  int
+	err    error
+}
+
+// NewCommentWriter creates filePath and writes the start of an export in
+// format, one of ExportFormats
+func NewCommentWriter(format string, filePath string) (*CommentWriter, error) {
+	switch format {
+	case "json", "csv", "txt":
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write comments: %w", err)
+	}
+
+	cw := &CommentWriter{format: format, file: file, w: bufio.NewWriter(file)}
+
+	switch format {
+	case "json":
+		exportedAt, _ := json.Marshal(time.Now())
+		cw.printf("{\n  \"exported_at\": %s,\n  \"threads\": [", exportedAt)
+	case "csv":
+		cw.printf("ID,VideoID,Platform,AuthorID,AuthorName,Content,LikeCount,ReplyCount,CreatedAt,ParentID,Level,Mentions")
+	case "txt":
+		cw.printf("Comments Export\n")
+		cw.printf("Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+		cw.printf("%s\n\n", strings.Repeat("=", 50))
+	}
+
+	return cw, cw.err
+}
+
+// Write appends a thread to the export
+func (cw *CommentWriter) Write(thread *CommentThread) error {
+	cw.count++
+
+	switch cw.format {
+	case "json":
+		cw.writeJSON(thread)
+	case "csv":
+		cw.writeCSV(thread)
+	case "txt":
+		cw.writeTXT(thread)
+	}
+
+	return cw.err
+}
+
+// Close writes the totals and closes the file
+func (cw *CommentWriter) Close() error {
+	switch cw.format {
+	case "json":
+		if cw.count > 0 {
+			cw.printf("\n  ")
+		}
+		cw.printf("],\n  \"total\": %d\n}", cw.count)
+	case "txt":
+		cw.printf("Total Comments: %d\n", cw.count)
+	}
+
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = fmt.Errorf("failed to write comments: %w", err)
+	}
+	if err := cw.file.Close(); err != nil && cw.err == nil {
+		cw.err = fmt.Errorf("failed to write comments: %w", err)
+	}
+
+	return cw.err
+}
+
+// printf writes to the export, keeping the first error
+func (cw *CommentWriter) printf(format string, args ...any) {
+	if cw.err != nil {
+		return
+	}
+	if _, err := fmt.Fprintf(cw.w, format, args...); err != nil {
+		cw.err = fmt.Errorf("failed to write comments: %w", err)
+	}
+}
+
+// writeJSON writes a thread as an element of the threads array
+func (cw *CommentWriter) writeJSON(thread *CommentThread) {
+	data, err := json.MarshalIndent(thread, "    ", "  ")
+	if err != nil {
+		if cw.err == nil {
+			cw.err = fmt.Errorf("failed to marshal comments to JSON: %w", err)
+		}
+		return
+	}
+
+	if cw.count > 1 {
+		cw.printf(",")
+	}
+	cw.printf("\n    %s", data)
+}
+
+// writeCSV writes a thread's comment and replies as rows
+func (cw *CommentWriter) writeCSV(thread *CommentThread) {
+	// Main comment
+	cw.printf("\n"+`"%s","%s","%s","%s","%s","%s",%d,%d,"%s","","0","%s"`,
+		thread.Comment.ID,
+		thread.Comment.VideoID,
+		thread.Comment.Platform,
+		thread.Comment.AuthorID,
+		thread.Comment.AuthorName,
+		strings.ReplaceAll(thread.Comment.Content, `"`, `""`),
+		thread.Comment.LikeCount,
+		thread.Comment.ReplyCount,
+		thread.Comment.CreatedAt.Format("2006-01-02 15:04:05"),
+		strings.Join(thread.Comment.Mentions, ";"),
+	)
+
+	// Replies
+	for _, reply := range thread.Replies {
+		cw.printf("\n"+`"%s","%s","%s","%s","%s","%s",%d,0,"%s","%s",%d,"%s"`,
+			reply.ID,
+			reply.VideoID,
+			reply.Platform,
+			reply.AuthorID,
+			reply.AuthorName,
+			strings.ReplaceAll(reply.Content, `"`, `""`),
+			reply.LikeCount,
+			reply.CreatedAt.Format("2006-01-02 15:04:05"),
+			reply.ParentID,
+			reply.Level,
+			strings.Join(reply.Mentions, ";"),
+		)
+	}
+}
+
+// writeTXT writes a thread as a numbered block of text
+func (cw *CommentWriter) writeTXT(thread *CommentThread) {
+	// Main comment
+	cw.printf("Comment %d:\n", cw.count)
+	cw.printf("  Author: %s (%s)\n", thread.Comment.AuthorName, thread.Comment.AuthorID)
+	cw.printf("  Content: %s\n", thread.Comment.Content)
+	cw.printf("  Likes: %d, Replies: %d\n", thread.Comment.LikeCount, thread.Comment.ReplyCount)
+	cw.printf("  Created: %s\n", thread.Comment.CreatedAt.Format("2006-01-02 15:04:05"))
+	if len(thread.Comment.Mentions) > 0 {
+		cw.printf("  Mentions: %s\n", strings.Join(thread.Comment.Mentions, ", "))
+	}
+
+	// Replies
+	if len(thread.Replies) > 0 {
+		cw.printf("  Replies:\n")
+		for j, reply := range thread.Replies {
+			cw.printf("    Reply %d:\n", j+1)
+			cw.printf("      Author: %s (%s)\n", reply.AuthorName, reply.AuthorID)
+			cw.printf("      Content: %s\n", reply.Content)
+			cw.printf("      Likes: %d\n", reply.LikeCount)
+			cw.printf("      Created: %s\n", reply.CreatedAt.Format("2006-01-02 15:04:05"))
+			if len(reply.Mentions) > 0 {
+				cw.printf("      Mentions: %s\n", strings.Join(reply.Mentions, ", "))
+			}
+		}
+	}
+
+	cw.printf("\n%s\n\n", strings.Repeat("-", 30))
+}
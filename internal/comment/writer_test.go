@@ -0,0 +1,82 @@
+package comment
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommentWriter(t *testing.T) {
+	threads := []*CommentThread{
+		{
+			Comment: &Comment{ID: "c1", Content: `say "hi"`, CreatedAt: time.Now()},
+			Replies: []*Comment{{ID: "r1", ParentID: "c1", Level: 1, Content: "reply"}},
+		},
+		{Comment: &Comment{ID: "c2", Content: "second", CreatedAt: time.Now()}},
+	}
+
+	tests := []struct {
+		format string
+		count  int
+		want   []string
+	}{
+		{"json", 0, []string{`"total": 0`}},
+		{"json", 2, []string{`"total": 2`}},
+		{"csv", 2, []string{`"c1"`, `"say ""hi"""`, `"r1"`, `"c2"`}},
+		{"txt", 2, []string{"Comment 2:", "Reply 1:", "Total Comments: 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out", "comments."+tt.format)
+			w, err := NewCommentWriter(tt.format, path)
+			if err != nil {
+				t.Fatalf("Failed to create writer: %v", err)
+			}
+			for _, thread := range threads[:tt.count] {
+				if err := w.Write(thread); err != nil {
+					t.Fatalf("Failed to write thread: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Failed to close writer: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read export: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("Expected export to contain %q, got:\n%s", want, data)
+				}
+			}
+
+			if tt.format == "json" {
+				var exported struct {
+					Total   int              `json:"total"`
+					Threads []*CommentThread `json:"threads"`
+				}
+				if err := json.Unmarshal(data, &exported); err != nil {
+					t.Fatalf("Expected valid JSON, got %v:\n%s", err, data)
+				}
+				if exported.Total != tt.count || len(exported.Threads) != tt.count {
+					t.Errorf("Expected %d threads, got total %d and %d threads", tt.count, exported.Total, len(exported.Threads))
+				}
+			}
+		})
+	}
+}
+
+func TestCommentWriterUnsupportedFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comments.xml")
+	if _, err := NewCommentWriter("xml", path); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be created, got %v", err)
+	}
+}
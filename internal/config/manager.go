@@ -114,11 +114,16 @@ func (m *Manager) setDefaults() {
 	// Download defaults
 	m.viper.SetDefault("download.max_workers", 5)
 	m.viper.SetDefault("download.chunk_size", 1024*1024) // 1MB
+	m.viper.SetDefault("download.connections", 4)
 	m.viper.SetDefault("download.timeout", 300)
 	m.viper.SetDefault("download.retry_count", 3)
 	m.viper.SetDefault("download.save_path", "./downloads")
 	m.viper.SetDefault("download.create_folder", true)
 	m.viper.SetDefault("download.file_naming", "{platform}_{author}_{title}_{id}")
+	m.viper.SetDefault("download.resume_queue", true)
+
+	// FFmpeg defaults
+	m.viper.SetDefault("ffmpeg.path", "ffmpeg")
 
 	// Database defaults
 	m.viper.SetDefault("database.type", "sqlite")
@@ -130,10 +135,19 @@ func (m *Manager) setDefaults() {
 	m.viper.SetDefault("log.format", "text")
 	m.viper.SetDefault("log.output", "stdout")
 
+	// HTTP defaults
+	m.viper.SetDefault("http.max_idle_conns", 100)
+	m.viper.SetDefault("http.max_idle_conns_per_host", 10)
+	m.viper.SetDefault("http.idle_conn_timeout", 90)
+	m.viper.SetDefault("http.tls_insecure", false)
+
 	// Platform defaults
 	m.viper.SetDefault("platforms.tiktok.enabled", true)
 	m.viper.SetDefault("platforms.xhs.enabled", true)
 	m.viper.SetDefault("platforms.kuaishou.enabled", true)
+	m.viper.SetDefault("platforms.tiktok.max_concurrent", 0)
+	m.viper.SetDefault("platforms.xhs.max_concurrent", 0)
+	m.viper.SetDefault("platforms.kuaishou.max_concurrent", 0)
 
 	// Auth defaults
 	m.viper.SetDefault("auth.enabled", true)
@@ -148,6 +162,10 @@ func (m *Manager) setDefaults() {
 	m.viper.SetDefault("rate_limit.max_concurrent", 100)
 	m.viper.SetDefault("rate_limit.adaptive", true)
 	m.viper.SetDefault("rate_limit.whitelisted_ips", []string{"127.0.0.1", "::1"})
+
+	// Subscription defaults
+	m.viper.SetDefault("subscriptions.enabled", true)
+	m.viper.SetDefault("subscriptions.check_interval", 60)
 }
 
 // createDefaultConfig creates a default configuration file
@@ -171,11 +189,16 @@ server:
 download:
   max_workers: 5
   chunk_size: 1048576  # 1MB
+  connections: 4  # parallel ranged connections per file
   timeout: 300
   retry_count: 3
   save_path: ./downloads
   create_folder: true
   file_naming: "{platform}_{author}_{title}_{id}"
+  resume_queue: true  # re-queue unfinished downloads on startup
+
+ffmpeg:
+  path: ffmpeg  # used to convert downloads to the requested format
 
 database:
   type: sqlite
@@ -195,22 +218,32 @@ proxy:
   username: ""
   password: ""
 
+# Connection settings shared by all platform and download requests
+http:
+  max_idle_conns: 100
+  max_idle_conns_per_host: 10
+  idle_conn_timeout: 90  # seconds
+  tls_insecure: false
+
 platforms:
   tiktok:
     enabled: true
     api_key: ""
     cookie: ""
     user_agent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+    max_concurrent: 0  # 0 = limited only by download.max_workers
 
   xhs:
     enabled: true
     cookie: ""
     user_agent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+    max_concurrent: 0  # 0 = limited only by download.max_workers
 
   kuaishou:
     enabled: true
     cookie: ""
     user_agent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+    max_concurrent: 0  # 0 = limited only by download.max_workers
 
 auth:
   enabled: true
@@ -227,6 +260,10 @@ rate_limit:
   whitelisted_ips:
     - "127.0.0.1"
     - "::1"
+
+subscriptions:
+  enabled: true  # sync author subscriptions while the server runs
+  check_interval: 60  # seconds between checks for due subscriptions
 `
 
 	if err := os.WriteFile(configFile, []byte(defaultConfig), 0644); err != nil {
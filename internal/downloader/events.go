@@ -0,0 +1,219 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+
+	"video-downloader/pkg/models"
+)
+
+// Event types
+const (
+	// EventStatus is published when a task changes state
+	EventStatus = "status"
+	// EventProgress is published while a task is transferring
+	EventProgress = "progress"
+)
+
+// ProgressEvent is a task state change or progress update
+type ProgressEvent struct {
+	Type       string    `json:"type"`
+	TaskID     string    `json:"task_id"`
+	BatchID    string    `json:"batch_id,omitempty"`
+	VideoID    string    `json:"video_id,omitempty"`
+	Status     string    `json:"status"`
+	Progress   float64   `json:"progress"`
+	Downloaded int64     `json:"downloaded,omitempty"`
+	FileSize   int64     `json:"file_size,omitempty"`
+	Speed      float64   `json:"speed"` // bytes per second
+	ETA        int64     `json:"eta"`   // seconds
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// EventFilter selects which events a subscriber receives. Empty fields
+// match everything.
+type EventFilter struct {
+	TaskID  string
+	BatchID string
+}
+
+// matches reports whether the event passes the filter
+func (f EventFilter) matches(event ProgressEvent) bool {
+	if f.TaskID != "" && f.TaskID != event.TaskID {
+		return false
+	}
+	if f.BatchID != "" && f.BatchID != event.BatchID {
+		return false
+	}
+	return true
+}
+
+// eventSubscriber is a single event stream
+type eventSubscriber struct {
+	filter EventFilter
+	ch     chan ProgressEvent
+}
+
+// eventHub fans out events to subscribers. Publishing never blocks; a
+// subscriber that falls behind misses progress events rather than stalling
+// downloads, but always gets a task's final status.
+type eventHub struct {
+	mutex       sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	closed      bool
+}
+
+// newEventHub creates an event hub
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+// subscribe registers a subscriber
+func (h *eventHub) subscribe(filter EventFilter) *eventSubscriber {
+	sub := &eventSubscriber{
+		filter: filter,
+		ch:     make(chan ProgressEvent, 64),
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Once closed, subscribers get a stream that has already ended
+	if h.closed {
+		close(sub.ch)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// unsubscribe removes a subscriber and closes its channel
+func (h *eventHub) unsubscribe(sub *eventSubscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// close ends every stream, closing the subscribers' channels
+func (h *eventHub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for sub := range h.subscribers {
+		close(sub.ch)
+	}
+	h.subscribers = make(map[*eventSubscriber]struct{})
+	h.closed = true
+}
+
+// publish delivers the event to every matching subscriber
+func (h *eventHub) publish(event ProgressEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	final := event.Type == EventStatus && IsFinalStatus(event.Status)
+	for sub := range h.subscribers {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+			continue
+		default:
+		}
+
+		// Subscriber is behind. Drop the event, unless streams wait for
+		// it to end, in which case the oldest queued event makes room.
+		// Only publish sends, under the mutex, so the retry succeeds.
+		if !final {
+			continue
+		}
+		select {
+		case <-sub.ch:
+		default:
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// SubscribeEvents streams task events matching filter. The returned
+// function must be called to release the subscription; it closes the
+// channel. Stop closes the channels of all subscriptions.
+func (m *Manager) SubscribeEvents(filter EventFilter) (<-chan ProgressEvent, func()) {
+	sub := m.events.subscribe(filter)
+	return sub.ch, func() { m.events.unsubscribe(sub) }
+}
+
+// publishStatus publishes the current state of a task
+func (m *Manager) publishStatus(task *models.DownloadTask) {
+	m.events.publish(ProgressEvent{
+		Type:      EventStatus,
+		TaskID:    task.ID,
+		BatchID:   task.BatchID,
+		VideoID:   task.VideoID,
+		Status:    task.Status,
+		Progress:  task.Progress,
+		Error:     task.Error,
+		Timestamp: time.Now(),
+	})
+}
+
+// EventSnapshot returns a status event for every known task matching
+// filter, so a new subscriber can start from the current state
+func (m *Manager) EventSnapshot(filter EventFilter) []ProgressEvent {
+	var tasks []models.DownloadTask
+
+	m.tasksMutex.Lock()
+	for _, state := range m.tasks {
+		tasks = append(tasks, *state.task)
+	}
+	_, active := m.tasks[filter.TaskID]
+	m.tasksMutex.Unlock()
+
+	// A finished task is only in storage
+	if filter.TaskID != "" && !active {
+		if task, err := m.storage.GetDownloadTask(filter.TaskID); err == nil && task != nil {
+			tasks = append(tasks, *task)
+		}
+	}
+
+	events := make([]ProgressEvent, 0, len(tasks))
+	for i := range tasks {
+		task := &tasks[i]
+		event := ProgressEvent{
+			Type:      EventStatus,
+			TaskID:    task.ID,
+			BatchID:   task.BatchID,
+			VideoID:   task.VideoID,
+			Status:    task.Status,
+			Progress:  task.Progress,
+			Error:     task.Error,
+			Timestamp: task.UpdatedAt,
+		}
+		if filter.matches(event) {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// IsFinalStatus reports whether a task in this status will not change again
+func IsFinalStatus(status string) bool {
+	switch status {
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled:
+		return true
+	}
+	return false
+}
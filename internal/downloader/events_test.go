@@ -0,0 +1,66 @@
+package downloader
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEventHubKeepsFinalStatus(t *testing.T) {
+	h := newEventHub()
+	sub := h.subscribe(EventFilter{TaskID: "task"})
+
+	// Fill the buffer past capacity with progress, then finish
+	for i := 0; i < cap(sub.ch)+10; i++ {
+		h.publish(ProgressEvent{Type: EventProgress, TaskID: "task", Status: TaskStatusDownloading, Progress: float64(i)})
+	}
+	h.publish(ProgressEvent{Type: EventStatus, TaskID: "task", Status: TaskStatusCompleted})
+
+	if len(sub.ch) != cap(sub.ch) {
+		t.Fatalf("Expected a full buffer, got %d of %d events", len(sub.ch), cap(sub.ch))
+	}
+
+	var last ProgressEvent
+	for len(sub.ch) > 0 {
+		last = <-sub.ch
+	}
+	if last.Type != EventStatus || last.Status != TaskStatusCompleted {
+		t.Errorf("Expected the final status last, got %+v", last)
+	}
+
+	// Progress events are still dropped when the buffer is full
+	for i := 0; i < cap(sub.ch)+1; i++ {
+		h.publish(ProgressEvent{Type: EventProgress, TaskID: "task", Progress: float64(i)})
+	}
+	if first := <-sub.ch; first.Progress != 0 {
+		t.Errorf("Expected the oldest progress event to be kept, got %+v", first)
+	}
+}
+
+func TestEventHubClose(t *testing.T) {
+	h := newEventHub()
+	subs := make([]*eventSubscriber, 3)
+	for i := range subs {
+		subs[i] = h.subscribe(EventFilter{TaskID: fmt.Sprintf("task%d", i)})
+	}
+	h.publish(ProgressEvent{Type: EventStatus, TaskID: "task0", Status: TaskStatusDownloading})
+
+	h.close()
+
+	// Queued events are still delivered before the end of the stream
+	if event, ok := <-subs[0].ch; !ok || event.TaskID != "task0" {
+		t.Errorf("Expected the queued event, got %+v", event)
+	}
+	for i, sub := range subs {
+		if _, ok := <-sub.ch; ok {
+			t.Errorf("Expected stream %d to be closed", i)
+		}
+		// Releasing a closed subscription is harmless
+		h.unsubscribe(sub)
+	}
+
+	late := h.subscribe(EventFilter{})
+	if _, ok := <-late.ch; ok {
+		t.Error("Expected a subscription after close to be closed")
+	}
+	h.publish(ProgressEvent{Type: EventStatus, TaskID: "task0", Status: TaskStatusCompleted})
+}
@@ -2,63 +2,117 @@ package downloader
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
 
 	"video-downloader/internal/platform"
+	"video-downloader/internal/postprocess"
+	"video-downloader/internal/resume"
 	"video-downloader/internal/utils"
 	"video-downloader/pkg/models"
 )
 
-// Manager manages the download process
+// Manager manages the download process. Downloads are queued as tasks and
+// processed by a fixed pool of workers; callers get a task ID back
+// immediately and use Subscribe or Wait to learn the outcome.
 type Manager struct {
 	config     *models.Config
 	logger     zerolog.Logger
 	storage    models.Storage
 	downloader *utils.DownloadManager
+	resumer    *resume.ResumableDownloader
+	hls        *utils.M3U8Downloader
+	post       *postprocess.Processor
+	client     *utils.HTTPClient
 	extractors map[models.Platform]models.PlatformExtractor
-	queue      chan *DownloadRequest
+	queue      *requestQueue
 	workers    int
 	ctx        context.Context
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
+
+	tasks      map[string]*taskState
+	tasksMutex sync.Mutex
+
+	// paths reserves output paths of running downloads by video ID
+	paths      map[string]string
+	pathsMutex sync.Mutex
+	taskSeq    atomic.Uint64
+	events     *eventHub
+}
+
+// taskState tracks a queued or running task in memory
+type taskState struct {
+	task        *models.DownloadTask
+	ctx         context.Context
+	cancel      context.CancelFunc
+	subscribers []chan *DownloadResult
 }
 
+// Task statuses
+const (
+	TaskStatusPending     = "pending"
+	TaskStatusDownloading = "downloading"
+	TaskStatusCompleted   = "completed"
+	TaskStatusFailed      = "failed"
+	TaskStatusCancelled   = "cancelled"
+)
+
 // DownloadRequest represents a download request
 type DownloadRequest struct {
+	TaskID   string
 	URL      string
 	Platform models.Platform
 	Options  *DownloadOptions
+
+	// resume is set when the task already has a transfer target from an
+	// earlier attempt
+	resume bool
 }
 
 // DownloadOptions represents download options
 type DownloadOptions struct {
-	OutputPath    string
-	Format        string
-	Quality       string
-	DownloadAudio bool
-	Metadata      bool
-	Progress      bool
+	Priority      Priority  `json:"priority,omitempty"`
+	BatchID       string    `json:"batch_id,omitempty"`
+	OutputPath    string    `json:"output_path,omitempty"`
+	Format        string    `json:"format,omitempty"`
+	Quality       string    `json:"quality,omitempty"`
+	DownloadAudio bool      `json:"download_audio,omitempty"`
+	Metadata      bool      `json:"metadata,omitempty"`
+	Sidecars      bool      `json:"sidecars,omitempty"`
+	Music         MusicMode `json:"music,omitempty"`
+	FileNaming    string    `json:"file_naming,omitempty"`
+	Progress      bool      `json:"progress,omitempty"`
 }
 
 // DownloadResult represents download result
 type DownloadResult struct {
+	TaskID  string
 	Success bool
 	Message string
 	Video   *models.VideoInfo
 	Error   error
 }
 
-// NewManager creates a new download manager
-func NewManager(cfg *models.Config, storage models.Storage) *Manager {
+// NewManager creates a new download manager. All of its network requests
+// go through the shared transport of transports; nil creates one from cfg.
+func NewManager(cfg *models.Config, storage models.Storage, transports *utils.TransportFactory) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	if transports == nil {
+		transports = utils.NewTransportFactory(cfg)
+	}
+	transport := transports.Transport()
+
 	// Create download manager
 	dm := utils.NewDownloadManager(utils.DownloadConfig{
 		MaxWorkers: cfg.Download.MaxWorkers,
@@ -66,6 +120,39 @@ func NewManager(cfg *models.Config, storage models.Storage) *Manager {
 		RetryCount: cfg.Download.RetryCount,
 		TempDir:    "./temp",
 		Timeout:    time.Duration(cfg.Download.Timeout) * time.Second,
+		Transport:  transport,
+	})
+
+	// Resumable downloader keeps partial files across restarts
+	resumer := resume.NewResumableDownloader(resume.ResumableConfig{
+		MetaDir:     "./temp/metadata",
+		TempDir:     "./temp/downloads",
+		ChunkSize:   int64(cfg.Download.ChunkSize),
+		Connections: cfg.Download.Connections,
+		MaxRetries:  cfg.Download.RetryCount,
+		Timeout:     time.Duration(cfg.Download.Timeout) * time.Second,
+		Transport:   transport,
+	})
+
+	// HLS streams are fetched segment by segment; finished segments are
+	// kept across attempts
+	hls := utils.NewM3U8Downloader(utils.DownloadConfig{
+		MaxWorkers: cfg.Download.MaxWorkers,
+		RetryCount: cfg.Download.RetryCount,
+		TempDir:    "./temp",
+		Timeout:    time.Duration(cfg.Download.Timeout) * time.Second,
+		Transport:  transport,
+	})
+
+	// Downloads are converted to the requested format with ffmpeg
+	post := postprocess.NewProcessor(postprocess.Config{
+		FFmpegPath: cfg.FFmpeg.Path,
+	})
+
+	// Small side requests such as cover art
+	client := utils.NewHTTPClient(utils.ClientConfig{
+		Timeout:   time.Duration(cfg.Download.Timeout) * time.Second,
+		Transport: transport,
 	})
 
 	// Create extractors
@@ -74,30 +161,30 @@ func NewManager(cfg *models.Config, storage models.Storage) *Manager {
 	if cfg.Platforms.TikTok.Enabled {
 		extractors[models.PlatformTikTok] = platform.NewTikTokExtractor(&models.ExtractorConfig{
 			Timeout:    time.Duration(cfg.Download.Timeout) * time.Second,
-			Proxy:      getProxyURL(cfg),
 			UserAgent:  cfg.Platforms.TikTok.UserAgent,
 			Cookie:     cfg.Platforms.TikTok.Cookie,
 			MaxRetries: cfg.Download.RetryCount,
+			Transport:  transport,
 		})
 	}
 
 	if cfg.Platforms.XHS.Enabled {
 		extractors[models.PlatformXHS] = platform.NewXHSExtractor(&models.ExtractorConfig{
 			Timeout:    time.Duration(cfg.Download.Timeout) * time.Second,
-			Proxy:      getProxyURL(cfg),
 			UserAgent:  cfg.Platforms.XHS.UserAgent,
 			Cookie:     cfg.Platforms.XHS.Cookie,
 			MaxRetries: cfg.Download.RetryCount,
+			Transport:  transport,
 		})
 	}
 
 	if cfg.Platforms.Kuaishou.Enabled {
 		extractors[models.PlatformKuaishou] = platform.NewKuaishouExtractor(&models.ExtractorConfig{
 			Timeout:    time.Duration(cfg.Download.Timeout) * time.Second,
-			Proxy:      getProxyURL(cfg),
 			UserAgent:  cfg.Platforms.Kuaishou.UserAgent,
 			Cookie:     cfg.Platforms.Kuaishou.Cookie,
 			MaxRetries: cfg.Download.RetryCount,
+			Transport:  transport,
 		})
 	}
 
@@ -106,15 +193,23 @@ func NewManager(cfg *models.Config, storage models.Storage) *Manager {
 		logger:     zerolog.New(os.Stdout).With().Timestamp().Logger(),
 		storage:    storage,
 		downloader: dm,
+		resumer:    resumer,
+		hls:        hls,
+		post:       post,
+		client:     client,
 		extractors: extractors,
-		queue:      make(chan *DownloadRequest, 100),
+		queue:      newRequestQueue(platformLimits(cfg)),
 		workers:    cfg.Download.MaxWorkers,
 		ctx:        ctx,
 		cancel:     cancel,
+		tasks:      make(map[string]*taskState),
+		paths:      make(map[string]string),
+		events:     newEventHub(),
 	}
 }
 
-// Start starts the download manager
+// Start starts the download manager. When download.resume_queue is set,
+// tasks left pending or running by a previous run are queued again.
 func (m *Manager) Start() error {
 	// Start worker goroutines
 	for i := 0; i < m.workers; i++ {
@@ -122,82 +217,199 @@ func (m *Manager) Start() error {
 		go m.worker(i)
 	}
 
+	if m.config.Download.ResumeQueue {
+		tasks, err := m.storage.ListDownloadTasks(TaskStatusPending, TaskStatusDownloading)
+		if err != nil {
+			return fmt.Errorf("error loading unfinished downloads: %w", err)
+		}
+
+		m.recoverTasks(tasks)
+	}
+
 	m.logger.Info().Msg("Download manager started")
 	return nil
 }
 
-// Stop stops the download manager
+// Stop stops the download manager and ends all event streams. Queued and
+// running tasks keep their persisted state so the next Start can pick them
+// up.
 func (m *Manager) Stop() error {
 	m.cancel()
 	m.wg.Wait()
+
+	// Release anyone still waiting on tasks that never reached a worker
+	m.tasksMutex.Lock()
+	remaining := make([]*taskState, 0, len(m.tasks))
+	for _, state := range m.tasks {
+		remaining = append(remaining, state)
+	}
+	m.tasksMutex.Unlock()
+
+	for _, state := range remaining {
+		m.dropTask(state)
+	}
+
+	// End event streams, which would otherwise wait on tasks that no
+	// longer run
+	m.events.close()
+
 	m.logger.Info().Msg("Download manager stopped")
 	return nil
 }
 
-// Download downloads a video from URL
-func (m *Manager) Download(url string, options *DownloadOptions) (*DownloadResult, error) {
+// Download queues a video for download and returns the task ID without
+// waiting for the transfer. options.Priority picks the queue lane. ctx
+// only covers queueing; use CancelDownload to abort the task itself.
+func (m *Manager) Download(ctx context.Context, url string, options *DownloadOptions) (string, error) {
 	// Determine platform
 	platform := m.detectPlatform(url)
 	if platform == "" {
-		return nil, fmt.Errorf("unsupported platform")
+		return "", fmt.Errorf("unsupported platform")
 	}
 
-	// Create download request
-	req := &DownloadRequest{
-		URL:      url,
-		Platform: platform,
-		Options:  options,
+	if options == nil {
+		options = &DownloadOptions{}
 	}
 
-	// Add to queue
-	m.queue <- req
+	if _, err := ParsePriority(string(options.Priority)); err != nil {
+		return "", err
+	}
 
-	// Wait for result (simplified - in production, use channels)
-	result := <-m.processDownload(req)
+	if err := validateFormat(options.Format); err != nil {
+		return "", err
+	}
 
-	return result, nil
-}
+	if _, err := ParseQuality(options.Quality); err != nil {
+		return "", err
+	}
 
-// DownloadBatch downloads multiple videos
-func (m *Manager) DownloadBatch(urls []string, options *DownloadOptions) ([]*DownloadResult, error) {
-	results := make([]*DownloadResult, len(urls))
-	errors := make([]error, len(urls))
+	if _, err := ParseMusicMode(string(options.Music)); err != nil {
+		return "", err
+	}
 
-	var wg sync.WaitGroup
-	for i, url := range urls {
-		wg.Add(1)
-		go func(idx int, u string) {
-			defer wg.Done()
-			result, err := m.Download(u, options)
-			if err != nil {
-				errors[idx] = err
-				return
-			}
-			results[idx] = result
-		}(i, url)
+	if _, err := m.pathTemplate(options); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	task := &models.DownloadTask{
+		ID:        m.newTaskID(),
+		BatchID:   options.BatchID,
+		URL:       url,
+		Platform:  platform,
+		Status:    TaskStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	wg.Wait()
+	if err := m.enqueue(ctx, task, options); err != nil {
+		return "", err
+	}
 
-	// Check for errors
-	for _, err := range errors {
+	return task.ID, nil
+}
+
+// DownloadBatch queues multiple videos for download. The returned slice
+// has one task ID per URL; URLs that could not be queued have an empty ID
+// and are reported in the returned error.
+func (m *Manager) DownloadBatch(ctx context.Context, urls []string, options *DownloadOptions) ([]string, error) {
+	taskIDs := make([]string, len(urls))
+	var errs []error
+
+	for i, url := range urls {
+		taskID, err := m.Download(ctx, url, options)
 		if err != nil {
-			return results, fmt.Errorf("some downloads failed")
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			continue
 		}
+		taskIDs[i] = taskID
+	}
+
+	if len(errs) > 0 {
+		return taskIDs, fmt.Errorf("some downloads could not be queued: %w", errors.Join(errs...))
 	}
 
-	return results, nil
+	return taskIDs, nil
+}
+
+// Subscribe returns a channel that receives the result of the task once it
+// finishes. The channel is buffered and closed after the single result is
+// delivered. Subscribing to an already finished task yields its stored
+// outcome immediately.
+func (m *Manager) Subscribe(taskID string) (<-chan *DownloadResult, error) {
+	ch := make(chan *DownloadResult, 1)
+
+	m.tasksMutex.Lock()
+	if state, ok := m.tasks[taskID]; ok {
+		state.subscribers = append(state.subscribers, ch)
+		m.tasksMutex.Unlock()
+		return ch, nil
+	}
+	m.tasksMutex.Unlock()
+
+	// Not in flight, fall back to the persisted task
+	task, err := m.storage.GetDownloadTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading task: %w", err)
+	}
+	if task == nil {
+		return nil, fmt.Errorf("download task not found: %s", taskID)
+	}
+
+	ch <- m.resultFromTask(task)
+	close(ch)
+	return ch, nil
+}
+
+// Wait blocks until the task finishes or ctx is done
+func (m *Manager) Wait(ctx context.Context, taskID string) (*DownloadResult, error) {
+	ch, err := m.Subscribe(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-ch:
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetTask returns the current state of a download task
+func (m *Manager) GetTask(taskID string) (*models.DownloadTask, error) {
+	m.tasksMutex.Lock()
+	if state, ok := m.tasks[taskID]; ok {
+		task := *state.task
+		m.tasksMutex.Unlock()
+		return &task, nil
+	}
+	m.tasksMutex.Unlock()
+
+	return m.storage.GetDownloadTask(taskID)
 }
 
 // GetStatus returns the status of downloads
 func (m *Manager) GetStatus() map[string]interface{} {
 	jobs := m.downloader.GetActiveJobs()
 
+	m.tasksMutex.Lock()
+	tasks := make([]models.DownloadTask, 0, len(m.tasks))
+	for _, state := range m.tasks {
+		tasks = append(tasks, *state.task)
+	}
+	m.tasksMutex.Unlock()
+
+	queued, running := m.queue.stats()
+
 	status := map[string]interface{}{
 		"active_downloads": len(jobs),
 		"max_workers":      m.workers,
-		"queue_size":       len(m.queue),
+		"queue_size":       m.queue.len(),
+		"queued":           queued,
+		"running":          running,
 		"jobs":             jobs,
+		"tasks":            tasks,
 	}
 
 	return status
@@ -210,21 +422,283 @@ func (m *Manager) worker(id int) {
 	m.logger.Info().Str("worker_id", fmt.Sprintf("%d", id)).Msg("Download worker started")
 
 	for {
-		select {
-		case <-m.ctx.Done():
+		req := m.queue.next(m.ctx)
+		if req == nil {
 			return
-		case req := <-m.queue:
-			m.processDownload(req)
 		}
+		m.runTask(req)
+		m.queue.done(req)
 	}
 }
 
-// processDownload processes a download request
-func (m *Manager) processDownload(req *DownloadRequest) chan *DownloadResult {
+// enqueue registers the task, persists it and pushes it onto the queue
+func (m *Manager) enqueue(ctx context.Context, task *models.DownloadTask, options *DownloadOptions) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error queueing download: %w", err)
+	}
+	if m.ctx.Err() != nil {
+		return fmt.Errorf("download manager stopped")
+	}
+
+	taskCtx, cancel := context.WithCancel(m.ctx)
+	state := &taskState{
+		task:   task,
+		ctx:    taskCtx,
+		cancel: cancel,
+	}
+
+	if data, err := json.Marshal(options); err == nil {
+		task.Options = string(data)
+	}
+
+	m.tasksMutex.Lock()
+	m.tasks[task.ID] = state
+	m.tasksMutex.Unlock()
+
+	if err := m.storage.SaveDownloadTask(task); err != nil {
+		m.logger.Error().Err(err).Str("task_id", task.ID).Msg("Error saving download task")
+	}
+	m.publishStatus(task)
+
+	req := &DownloadRequest{
+		TaskID:   task.ID,
+		URL:      task.URL,
+		Platform: task.Platform,
+		Options:  options,
+		resume:   task.DownloadURL != "" && task.FilePath != "",
+	}
+
+	m.queue.push(req)
+	return nil
+}
+
+// recoverTasks queues tasks persisted by a previous run
+func (m *Manager) recoverTasks(tasks []*models.DownloadTask) {
+	for _, task := range tasks {
+		options := &DownloadOptions{}
+		if task.Options != "" {
+			if err := json.Unmarshal([]byte(task.Options), options); err != nil {
+				m.logger.Warn().Err(err).Str("task_id", task.ID).Msg("Error decoding task options")
+			}
+		}
+
+		m.tasksMutex.Lock()
+		_, active := m.tasks[task.ID]
+		m.tasksMutex.Unlock()
+		if active {
+			continue
+		}
+
+		task.Status = TaskStatusPending
+		if err := m.enqueue(m.ctx, task, options); err != nil {
+			m.logger.Error().Err(err).Str("task_id", task.ID).Msg("Error re-queueing unfinished download")
+			continue
+		}
+
+		m.logger.Info().Str("task_id", task.ID).Str("url", task.URL).Msg("Re-queued unfinished download")
+	}
+}
+
+// resumeTransfer continues the transfer recorded on the task by an earlier
+// attempt, skipping extraction. It returns false when the caller should
+// start over with a fresh extraction instead.
+func (m *Manager) resumeTransfer(ctx context.Context, req *DownloadRequest) (*DownloadResult, bool) {
+	task, err := m.GetTask(req.TaskID)
+	if err != nil || task == nil || task.VideoID == "" {
+		return nil, false
+	}
+
+	videoInfo, err := m.storage.GetVideoInfo(task.VideoID)
+	if err != nil || videoInfo == nil {
+		return nil, false
+	}
+
+	m.logger.Info().Str("task_id", task.ID).Str("file", task.FilePath).Msg("Resuming interrupted download")
+
+	if musicMode(req) == MusicOnly && videoInfo.MediaType == models.MediaTypeAudio {
+		req = musicRequest(req, videoInfo)
+	}
+
+	result := m.transferVideo(ctx, req, videoInfo, task.DownloadURL, task.FilePath)
+	if result.Error != nil && ctx.Err() == nil {
+		// Signed media URLs expire; extract again and retry
+		m.logger.Warn().Err(result.Error).Str("task_id", task.ID).Msg("Resume failed, starting over")
+		return nil, false
+	}
+
+	m.addMusic(ctx, req, result, task.FilePath)
+	return result, true
+}
+
+// runTask executes a queued request and publishes its result
+func (m *Manager) runTask(req *DownloadRequest) {
+	// Claim the task; it may have been cancelled while waiting in the queue
+	m.tasksMutex.Lock()
+	state, ok := m.tasks[req.TaskID]
+	if ok && state.task.Status != TaskStatusPending {
+		ok = false
+	}
+	if ok {
+		state.task.Status = TaskStatusDownloading
+	}
+	m.tasksMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	if m.ctx.Err() != nil {
+		m.dropTask(state)
+		return
+	}
+
+	if state.ctx.Err() != nil {
+		m.finishTask(state, &DownloadResult{Error: state.ctx.Err()}, TaskStatusCancelled)
+		return
+	}
+
+	now := time.Now()
+	task := m.updateTask(state, func(t *models.DownloadTask) {
+		t.StartedAt = &now
+	})
+	m.publishStatus(task)
+
+	result := <-m.processDownload(state.ctx, req)
+
+	if result.Error != nil && m.ctx.Err() != nil {
+		// Interrupted by Stop(); keep it for the next run
+		m.dropTask(state)
+		return
+	}
+
+	status := TaskStatusCompleted
+	if result.Error != nil {
+		status = TaskStatusFailed
+		if state.ctx.Err() != nil {
+			status = TaskStatusCancelled
+		}
+	}
+
+	m.finishTask(state, result, status)
+}
+
+// updateTask applies fn to the task under lock, persists it and returns
+// a snapshot
+func (m *Manager) updateTask(state *taskState, fn func(t *models.DownloadTask)) *models.DownloadTask {
+	m.tasksMutex.Lock()
+	fn(state.task)
+	state.task.UpdatedAt = time.Now()
+	task := *state.task
+	m.tasksMutex.Unlock()
+
+	if err := m.storage.SaveDownloadTask(&task); err != nil {
+		m.logger.Error().Err(err).Str("task_id", task.ID).Msg("Error saving download task")
+	}
+
+	return &task
+}
+
+// finishTask records the final state of a task, notifies subscribers and
+// drops it from the in-flight set
+func (m *Manager) finishTask(state *taskState, result *DownloadResult, status string) {
+	now := time.Now()
+	task := m.updateTask(state, func(t *models.DownloadTask) {
+		t.Status = status
+		t.CompletedAt = &now
+		if result.Error != nil {
+			t.Error = result.Error.Error()
+		} else {
+			t.Progress = 100
+		}
+		if result.Video != nil {
+			t.VideoID = result.Video.ID
+			t.FilePath = result.Video.FilePath
+		}
+	})
+	m.publishStatus(task)
+
+	result.TaskID = state.task.ID
+
+	m.tasksMutex.Lock()
+	delete(m.tasks, state.task.ID)
+	subscribers := state.subscribers
+	state.subscribers = nil
+	m.tasksMutex.Unlock()
+
+	state.cancel()
+
+	for _, ch := range subscribers {
+		ch <- result
+		close(ch)
+	}
+}
+
+// dropTask forgets an in-flight task without touching its persisted state.
+// Subscribers are told the manager stopped.
+func (m *Manager) dropTask(state *taskState) {
+	m.tasksMutex.Lock()
+	delete(m.tasks, state.task.ID)
+	subscribers := state.subscribers
+	state.subscribers = nil
+	m.tasksMutex.Unlock()
+
+	state.cancel()
+
+	for _, ch := range subscribers {
+		ch <- &DownloadResult{
+			TaskID: state.task.ID,
+			Error:  fmt.Errorf("download manager stopped"),
+		}
+		close(ch)
+	}
+}
+
+// resultFromTask rebuilds a result from a persisted task
+func (m *Manager) resultFromTask(task *models.DownloadTask) *DownloadResult {
+	result := &DownloadResult{
+		TaskID:  task.ID,
+		Success: task.Status == TaskStatusCompleted,
+		Message: task.Status,
+	}
+
+	if task.Error != "" {
+		result.Error = errors.New(task.Error)
+	}
+
+	if task.VideoID != "" {
+		if video, err := m.storage.GetVideoInfo(task.VideoID); err == nil {
+			result.Video = video
+		}
+	}
+
+	return result
+}
+
+// newTaskID generates a unique task ID
+func (m *Manager) newTaskID() string {
+	return fmt.Sprintf("dl_%d_%d", time.Now().UnixNano(), m.taskSeq.Add(1))
+}
+
+// processDownload processes a download request. The work is bound to both
+// ctx and the manager's own context, so either the caller or Stop() can
+// abort it.
+func (m *Manager) processDownload(ctx context.Context, req *DownloadRequest) chan *DownloadResult {
 	resultChan := make(chan *DownloadResult, 1)
 
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(m.ctx, cancel)
+
 	go func() {
 		defer close(resultChan)
+		defer cancel()
+		defer stop()
+
+		// A task interrupted by an earlier run picks up where it stopped
+		if req.resume {
+			if result, ok := m.resumeTransfer(ctx, req); ok {
+				resultChan <- result
+				return
+			}
+		}
 
 		result := &DownloadResult{
 			Success: false,
@@ -239,19 +713,40 @@ func (m *Manager) processDownload(req *DownloadRequest) chan *DownloadResult {
 		}
 
 		// Extract video info
-		videoInfo, err := extractor.ExtractVideoInfo(req.URL)
+		videoInfo, err := extractor.ExtractVideoInfo(ctx, req.URL)
 		if err != nil {
 			result.Error = fmt.Errorf("error extracting video info: %w", err)
 			resultChan <- result
 			return
 		}
 
+		// The soundtrack is a record of its own
+		m.saveMusic(videoInfo)
+		if musicMode(req) == MusicOnly {
+			resultChan <- m.downloadMusicOnly(ctx, req, videoInfo)
+			return
+		}
+
 		// Check if already downloaded
 		existing, err := m.storage.GetVideoInfo(videoInfo.ID)
 		if err == nil && existing != nil && existing.Status == "completed" {
+			existing.MusicID = videoInfo.MusicID
+			existing.Music = videoInfo.Music
 			result.Success = true
 			result.Message = "Already downloaded"
 			result.Video = existing
+			m.addMusic(ctx, req, result, existing.FilePath)
+			resultChan <- result
+			return
+		}
+
+		// Pick the rendition asked for
+		options := req.Options
+		if options == nil {
+			options = &DownloadOptions{}
+		}
+		if err := selectRendition(videoInfo, options); err != nil {
+			result.Error = err
 			resultChan <- result
 			return
 		}
@@ -262,58 +757,362 @@ func (m *Manager) processDownload(req *DownloadRequest) chan *DownloadResult {
 		}
 
 		// Generate output path
-		outputPath := m.generateOutputPath(videoInfo, req.Options)
-
-		// Download file
-		progressChan := make(chan float64)
-		go func() {
-			for progress := range progressChan {
-				// Update progress in storage
-				if err := m.storage.UpdateDownloadProgress(videoInfo.ID, progress); err != nil {
-					m.logger.Error().Err(err).Msg("Error updating download progress")
-				}
+		outputPath, err := m.generateOutputPath(videoInfo, req.Options)
+		if err != nil {
+			result.Error = err
+			resultChan <- result
+			return
+		}
+		defer m.releasePath(outputPath)
+
+		// Record the transfer target so it can be resumed after a restart
+		m.setTaskTarget(req.TaskID, videoInfo.ID, videoInfo.DownloadURL, outputPath)
+
+		result = m.transferVideo(ctx, req, videoInfo, videoInfo.DownloadURL, outputPath)
+		m.addMusic(ctx, req, result, outputPath)
+		resultChan <- result
+	}()
+
+	return resultChan
+}
+
+// transferVideo downloads the media for videoInfo and records the outcome
+// on the video
+func (m *Manager) transferVideo(ctx context.Context, req *DownloadRequest, videoInfo *models.VideoInfo, downloadURL, outputPath string) *DownloadResult {
+	taskID := req.TaskID
+	result := &DownloadResult{
+		Success: false,
+	}
+
+	// Download file. Updates arrive per chunk, so storage writes and
+	// events are throttled.
+	progressChan := make(chan transferProgress)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var last time.Time
+		for update := range progressChan {
+			if time.Since(last) < progressInterval && update.Progress < 100 {
+				continue
+			}
+			last = time.Now()
+
+			m.setTaskProgress(taskID, update)
+			// Update progress in storage
+			if err := m.storage.UpdateDownloadProgress(taskID, update.Progress); err != nil {
+				m.logger.Error().Err(err).Msg("Error updating download progress")
 			}
-		}()
+		}
+	}()
 
-		err = m.downloader.Download(videoInfo.DownloadURL, outputPath, progressChan)
-		close(progressChan)
+	// Multi-file posts such as image notes fetch every item instead
+	var err error
+	sourcePath := m.sourcePath(videoInfo, downloadURL, outputPath)
+	if len(videoInfo.MediaItems) > 0 {
+		err = m.transferItems(ctx, req, videoInfo, outputPath, progressChan)
+	} else {
+		err = m.transfer(ctx, req, downloadURL, sourcePath, progressChan)
+	}
+	close(progressChan)
+	<-done
 
-		if err != nil {
-			// Update status to failed
+	filePath := outputPath
+	if err != nil {
+		err = fmt.Errorf("error downloading video: %w", err)
+	} else if len(videoInfo.MediaItems) > 0 {
+		filePath = videoInfo.MediaItems[0].FilePath
+	} else {
+		filePath, err = m.postProcess(ctx, req, videoInfo, sourcePath, outputPath)
+	}
+
+	if err != nil {
+		// Update status to failed, or cancelled if the caller gave up
+		if ctx.Err() != nil {
+			videoInfo.Status = "cancelled"
+		} else {
 			videoInfo.Status = "failed"
-			videoInfo.ErrorMessage = err.Error()
 			videoInfo.RetryCount++
+		}
+		videoInfo.ErrorMessage = err.Error()
+
+		if err := m.storage.SaveVideoInfo(videoInfo); err != nil {
+			m.logger.Error().Err(err).Msg("Error updating video status")
+		}
+
+		result.Error = err
+		return result
+	}
 
-			if err := m.storage.SaveVideoInfo(videoInfo); err != nil {
-				m.logger.Error().Err(err).Msg("Error updating video status")
+	// Update video info with file details; multi-file posts point at their
+	// first item and count the size of all of them
+	if stat, err := os.Stat(filePath); err == nil {
+		videoInfo.FileSize = stat.Size()
+		if len(videoInfo.MediaItems) > 0 {
+			videoInfo.FileSize = 0
+			for _, item := range videoInfo.MediaItems {
+				videoInfo.FileSize += item.FileSize
 			}
+		}
+		videoInfo.FilePath = filePath
+		videoInfo.Status = "completed"
+		videoInfo.ErrorMessage = ""
+		now := time.Now()
+		videoInfo.DownloadedAt = &now
+	}
 
-			result.Error = fmt.Errorf("error downloading video: %w", err)
-			resultChan <- result
-			return
+	if req.Options != nil && req.Options.Sidecars {
+		if err := m.writeSidecars(ctx, videoInfo, outputPath); err != nil {
+			m.logger.Warn().Err(err).Str("file", outputPath).Msg("Error writing sidecar files")
 		}
+	}
 
-		// Update video info with file details
-		if stat, err := os.Stat(outputPath); err == nil {
-			videoInfo.FileSize = stat.Size()
-			videoInfo.FilePath = outputPath
-			videoInfo.Status = "completed"
-			now := time.Now()
-			videoInfo.DownloadedAt = &now
+	// Save updated video info
+	if err := m.storage.SaveVideoInfo(videoInfo); err != nil {
+		m.logger.Error().Err(err).Msg("Error saving updated video info")
+	}
+
+	result.Success = true
+	result.Message = "Download completed"
+	result.Video = videoInfo
+	return result
+}
+
+// transfer fetches downloadURL into outputPath. HLS playlists are fetched
+// segment by segment, other HTTP(S) downloads go through the resumable
+// downloader so partial files survive a restart, and anything else is left
+// to the generic download manager.
+func (m *Manager) transfer(ctx context.Context, req *DownloadRequest, downloadURL, outputPath string, progressChan chan<- transferProgress) error {
+	if !strings.HasPrefix(downloadURL, "http://") && !strings.HasPrefix(downloadURL, "https://") {
+		return percentProgress(progressChan, func(updates chan<- float64) error {
+			return m.downloader.Download(ctx, downloadURL, outputPath, updates)
+		})
+	}
+
+	headers := map[string]string{
+		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+	}
+
+	if utils.IsM3U8URL(downloadURL) {
+		// Key servers check the same cookies as the platform pages
+		options := utils.HLSOptions{Headers: m.platformHeaders(req.Platform)}
+		if req.Options != nil {
+			if quality, err := ParseQuality(req.Options.Quality); err == nil {
+				options.Quality = quality.variantQuality()
+			}
 		}
 
-		// Save updated video info
-		if err := m.storage.SaveVideoInfo(videoInfo); err != nil {
-			m.logger.Error().Err(err).Msg("Error saving updated video info")
+		return percentProgress(progressChan, func(updates chan<- float64) error {
+			return m.hls.DownloadM3U8(ctx, downloadURL, outputPath, options, updates)
+		})
+	}
+
+	updates := make(chan resume.ProgressUpdate)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for update := range updates {
+			if update.Progress > 0 {
+				progressChan <- transferProgress{
+					Progress:   update.Progress,
+					Downloaded: update.Downloaded,
+					FileSize:   update.FileSize,
+					Speed:      update.Speed,
+					ETA:        update.ETA,
+				}
+			}
 		}
+	}()
 
-		result.Success = true
-		result.Message = "Download completed"
-		result.Video = videoInfo
-		resultChan <- result
+	err := m.resumer.Download(ctx, downloadURL, outputPath, headers, updates)
+	close(updates)
+	<-done
+
+	return err
+}
+
+// sourcePath returns where the download for outputPath is written before
+// post-processing. It only differs from outputPath when the platform serves
+// another format than the one requested; HLS streams are always saved as
+// MPEG-TS first.
+func (m *Manager) sourcePath(videoInfo *models.VideoInfo, downloadURL, outputPath string) string {
+	if videoInfo.MediaType == models.MediaTypeImage {
+		return outputPath
+	}
+
+	format := videoInfo.Format
+	if utils.IsM3U8URL(downloadURL) {
+		format = "ts"
+	}
+	if format == "" {
+		format = "mp4"
+	}
+
+	ext := filepath.Ext(outputPath)
+	if strings.EqualFold("."+format, ext) || !postprocess.IsSupportedFormat(format) {
+		return outputPath
+	}
+
+	return strings.TrimSuffix(outputPath, ext) + "." + format
+}
+
+// postProcess converts the download at sourcePath to the format of
+// outputPath, extracts an audio copy when asked to and records the final
+// format on the video. Tags and cover art are embedded when
+// options.Metadata is set. It returns the path of the finished file. Without
+// ffmpeg, a conversion nobody asked for (such as HLS to MP4) is skipped and
+// the source file kept, while an explicitly requested format fails.
+func (m *Manager) postProcess(ctx context.Context, req *DownloadRequest, videoInfo *models.VideoInfo, sourcePath, outputPath string) (string, error) {
+	options := req.Options
+	if options == nil {
+		options = &DownloadOptions{}
+	}
+
+	if sourcePath != outputPath {
+		op, err := m.post.Convert(ctx, sourcePath, outputPath)
+		if errors.Is(err, postprocess.ErrFFmpegNotFound) && options.Format == "" {
+			m.logger.Warn().Err(err).Str("file", sourcePath).Msg("Keeping download in its original format")
+			outputPath = sourcePath
+		} else if err != nil {
+			return "", fmt.Errorf("error converting video: %w", err)
+		} else if op != postprocess.OperationNone {
+			os.Remove(sourcePath)
+		}
+	}
+
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(outputPath), "."))
+
+	if options.Metadata && videoInfo.MediaType != models.MediaTypeImage {
+		// The media itself is fine without tags
+		if err := m.embedMetadata(ctx, videoInfo, outputPath); err != nil {
+			m.logger.Warn().Err(err).Str("file", outputPath).Msg("Error embedding metadata")
+		}
+	}
+
+	if options.DownloadAudio && videoInfo.MediaType != models.MediaTypeImage && !postprocess.IsAudioFormat(format) {
+		audioPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".m4a"
+		if _, err := m.post.Convert(ctx, outputPath, audioPath); err != nil {
+			m.logger.Warn().Err(err).Str("file", outputPath).Msg("Error extracting audio")
+		}
+	}
+
+	videoInfo.Format = format
+	return outputPath, nil
+}
+
+// platformHeaders returns the headers the platform's own pages are
+// fetched with
+func (m *Manager) platformHeaders(platform models.Platform) map[string]string {
+	headers := map[string]string{
+		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+	}
+
+	var userAgent, cookie, referer string
+	switch platform {
+	case models.PlatformTikTok:
+		userAgent, cookie = m.config.Platforms.TikTok.UserAgent, m.config.Platforms.TikTok.Cookie
+		referer = "https://www.tiktok.com/"
+	case models.PlatformXHS:
+		userAgent, cookie = m.config.Platforms.XHS.UserAgent, m.config.Platforms.XHS.Cookie
+		referer = "https://www.xiaohongshu.com/"
+	case models.PlatformKuaishou:
+		userAgent, cookie = m.config.Platforms.Kuaishou.UserAgent, m.config.Platforms.Kuaishou.Cookie
+		referer = "https://www.kuaishou.com/"
+	}
+
+	if userAgent != "" {
+		headers["User-Agent"] = userAgent
+	}
+	if cookie != "" {
+		headers["Cookie"] = cookie
+	}
+	if referer != "" {
+		headers["Referer"] = referer
+	}
+
+	return headers
+}
+
+// percentProgress runs a download that only reports a percentage,
+// forwarding its updates to progressChan
+func percentProgress(progressChan chan<- transferProgress, download func(chan<- float64) error) error {
+	updates := make(chan float64)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for progress := range updates {
+			progressChan <- transferProgress{Progress: progress}
+		}
 	}()
 
-	return resultChan
+	err := download(updates)
+	close(updates)
+	<-done
+
+	return err
+}
+
+// setTaskTarget records the video and transfer target of a task
+func (m *Manager) setTaskTarget(taskID, videoID, downloadURL, filePath string) {
+	m.tasksMutex.Lock()
+	state, ok := m.tasks[taskID]
+	m.tasksMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	m.updateTask(state, func(t *models.DownloadTask) {
+		t.VideoID = videoID
+		t.DownloadURL = downloadURL
+		t.FilePath = filePath
+	})
+}
+
+// transferProgress is a progress update from either downloader
+type transferProgress struct {
+	Progress   float64
+	Downloaded int64
+	FileSize   int64
+	Speed      float64
+	ETA        time.Duration
+}
+
+// progressInterval is the minimum time between progress events of a task
+const progressInterval = 500 * time.Millisecond
+
+// setTaskProgress updates the in-memory progress of a running task and
+// publishes it
+func (m *Manager) setTaskProgress(taskID string, update transferProgress) {
+	m.tasksMutex.Lock()
+	state, ok := m.tasks[taskID]
+	if !ok {
+		m.tasksMutex.Unlock()
+		return
+	}
+
+	task := state.task
+	task.Progress = update.Progress
+	if update.Speed > 0 {
+		task.Speed = utils.FormatBytes(int64(update.Speed)) + "/s"
+		task.ETA = utils.FormatDuration(update.ETA)
+	}
+	task.UpdatedAt = time.Now()
+
+	event := ProgressEvent{
+		Type:       EventProgress,
+		TaskID:     task.ID,
+		BatchID:    task.BatchID,
+		VideoID:    task.VideoID,
+		Status:     task.Status,
+		Progress:   update.Progress,
+		Downloaded: update.Downloaded,
+		FileSize:   update.FileSize,
+		Speed:      update.Speed,
+		ETA:        int64(update.ETA / time.Second),
+		Timestamp:  task.UpdatedAt,
+	}
+	m.tasksMutex.Unlock()
+
+	m.events.publish(event)
 }
 
 // detectPlatform detects the platform from URL
@@ -326,90 +1125,117 @@ func (m *Manager) detectPlatform(url string) models.Platform {
 	return ""
 }
 
-// generateOutputPath generates the output file path
-func (m *Manager) generateOutputPath(videoInfo *models.VideoInfo, options *DownloadOptions) string {
-	// Use provided output path or generate one
+// OutputPath returns where videoInfo is saved with options, before any
+// suffix added to avoid overwriting another file. The path comes from
+// options.FileNaming or the configured download.file_naming template.
+func (m *Manager) OutputPath(videoInfo *models.VideoInfo, options *DownloadOptions) (string, error) {
+	if options == nil {
+		options = &DownloadOptions{}
+	}
+
+	template, err := m.pathTemplate(options)
+	if err != nil {
+		return "", err
+	}
+
+	// Use provided output path or the configured one
 	outputPath := options.OutputPath
 	if outputPath == "" {
 		outputPath = m.config.Download.SavePath
 	}
 
-	// Create folder if needed
-	if m.config.Download.CreateFolder {
-		authorFolder := fmt.Sprintf("%s_%s", videoInfo.AuthorID, videoInfo.AuthorName)
-		authorFolder = utils.SanitizeFilename(authorFolder)
-		outputPath = filepath.Join(outputPath, authorFolder)
+	return filepath.Join(outputPath, template.Render(videoInfo, outputFormat(videoInfo, options))), nil
+}
+
+// pathTemplate parses the naming template that applies to options. With
+// download.create_folder set, a template without directories is placed in
+// a folder per author.
+func (m *Manager) pathTemplate(options *DownloadOptions) (*utils.PathTemplate, error) {
+	naming := options.FileNaming
+	if naming == "" {
+		naming = m.config.Download.FileNaming
+	}
+	if naming == "" {
+		naming = utils.DefaultFileNaming
 	}
 
-	// Generate filename
-	filename := m.generateFilename(videoInfo, options)
+	if m.config.Download.CreateFolder && !strings.Contains(filepath.ToSlash(naming), "/") {
+		naming = "{author_id}_{author}/" + naming
+	}
 
-	return filepath.Join(outputPath, filename)
+	return utils.ParsePathTemplate(naming)
 }
 
-// generateFilename generates filename based on configuration
-func (m *Manager) generateFilename(videoInfo *models.VideoInfo, options *DownloadOptions) string {
-	template := m.config.Download.FileNaming
-	if template == "" {
-		template = "{platform}_{author}_{title}_{id}"
+// generateOutputPath returns a path for videoInfo that no other file or
+// running download uses, and reserves it until releasePath is called
+func (m *Manager) generateOutputPath(videoInfo *models.VideoInfo, options *DownloadOptions) (string, error) {
+	outputPath, err := m.OutputPath(videoInfo, options)
+	if err != nil {
+		return "", err
 	}
 
-	// Replace placeholders
-	filename := template
-	filename = strings.ReplaceAll(filename, "{platform}", string(videoInfo.Platform))
-	filename = strings.ReplaceAll(filename, "{author}", videoInfo.AuthorName)
-	filename = strings.ReplaceAll(filename, "{title}", videoInfo.Title)
-	filename = strings.ReplaceAll(filename, "{id}", videoInfo.ID)
-	filename = strings.ReplaceAll(filename, "{date}", videoInfo.PublishedAt.Format("2006-01-02"))
-
-	// Sanitize filename
-	filename = utils.SanitizeFilename(filename)
+	m.pathsMutex.Lock()
+	defer m.pathsMutex.Unlock()
 
-	// Add extension
-	ext := options.Format
-	if ext == "" {
-		switch videoInfo.MediaType {
-		case models.MediaTypeVideo:
-			ext = "mp4"
-		case models.MediaTypeImage:
-			ext = "jpg"
-		case models.MediaTypeAudio:
-			ext = "mp3"
-		default:
-			ext = "mp4"
-		}
-	}
+	outputPath = utils.UniquePath(outputPath, func(path string) bool {
+		id, ok := m.paths[path]
+		return ok && id != videoInfo.ID
+	})
+	m.paths[outputPath] = videoInfo.ID
 
-	filename += "." + ext
+	return outputPath, nil
+}
 
-	return filename
+// releasePath drops the reservation made by generateOutputPath
+func (m *Manager) releasePath(path string) {
+	m.pathsMutex.Lock()
+	delete(m.paths, path)
+	m.pathsMutex.Unlock()
 }
 
-// getProxyURL returns proxy URL if enabled
-func getProxyURL(cfg *models.Config) string {
-	if !cfg.Proxy.Enabled {
-		return ""
+// outputFormat returns the file extension for videoInfo: the requested
+// format, or a default for the media type
+func outputFormat(videoInfo *models.VideoInfo, options *DownloadOptions) string {
+	if options.Format != "" {
+		return options.Format
+	}
+
+	switch videoInfo.MediaType {
+	case models.MediaTypeImage:
+		return "jpg"
+	case models.MediaTypeAudio:
+		if postprocess.IsAudioFormat(videoInfo.Format) {
+			return videoInfo.Format
+		}
+		return "mp3"
+	default:
+		return "mp4"
 	}
+}
 
-	if cfg.Proxy.Username != "" && cfg.Proxy.Password != "" {
-		return fmt.Sprintf("%s://%s:%s@%s:%d",
-			cfg.Proxy.Type,
-			cfg.Proxy.Username,
-			cfg.Proxy.Password,
-			cfg.Proxy.Host,
-			cfg.Proxy.Port,
-		)
+// imageFormats are output formats that are saved as downloaded
+var imageFormats = map[string]bool{"jpg": true, "jpeg": true, "png": true, "webp": true, "gif": true}
+
+// validateFormat checks that a requested output format can be produced
+func validateFormat(format string) error {
+	format = strings.ToLower(format)
+	if format == "" || imageFormats[format] || postprocess.IsSupportedFormat(format) {
+		return nil
 	}
+	return fmt.Errorf("unsupported format: %s", format)
+}
 
-	return fmt.Sprintf("%s://%s:%d",
-		cfg.Proxy.Type,
-		cfg.Proxy.Host,
-		cfg.Proxy.Port,
-	)
+// platformLimits returns the configured per-platform concurrency caps
+func platformLimits(cfg *models.Config) map[models.Platform]int {
+	return map[models.Platform]int{
+		models.PlatformTikTok:   cfg.Platforms.TikTok.MaxConcurrent,
+		models.PlatformXHS:      cfg.Platforms.XHS.MaxConcurrent,
+		models.PlatformKuaishou: cfg.Platforms.Kuaishou.MaxConcurrent,
+	}
 }
 
 // GetVideoInfo retrieves video information without downloading
-func (m *Manager) GetVideoInfo(url string) (*models.VideoInfo, error) {
+func (m *Manager) GetVideoInfo(ctx context.Context, url string) (*models.VideoInfo, error) {
 	platform := m.detectPlatform(url)
 	if platform == "" {
 		return nil, fmt.Errorf("unsupported platform")
@@ -420,17 +1246,17 @@ func (m *Manager) GetVideoInfo(url string) (*models.VideoInfo, error) {
 		return nil, fmt.Errorf("extractor not available for platform: %s", platform)
 	}
 
-	return extractor.ExtractVideoInfo(url)
+	return extractor.ExtractVideoInfo(ctx, url)
 }
 
 // GetAuthorInfo retrieves author information
-func (m *Manager) GetAuthorInfo(platform models.Platform, authorID string) (*models.AuthorInfo, error) {
+func (m *Manager) GetAuthorInfo(ctx context.Context, platform models.Platform, authorID string) (*models.AuthorInfo, error) {
 	extractor, ok := m.extractors[platform]
 	if !ok {
 		return nil, fmt.Errorf("extractor not available for platform: %s", platform)
 	}
 
-	return extractor.ExtractAuthorInfo(authorID)
+	return extractor.ExtractAuthorInfo(ctx, authorID)
 }
 
 // ListVideos lists videos with filters
@@ -438,51 +1264,85 @@ func (m *Manager) ListVideos(filter models.VideoFilter) ([]*models.VideoInfo, er
 	return m.storage.ListVideos(filter)
 }
 
-// CancelDownload cancels a download
-func (m *Manager) CancelDownload(videoID string) error {
-	// This would need to track active downloads
-	// For now, just update status
-	video, err := m.storage.GetVideoInfo(videoID)
-	if err != nil {
-		return fmt.Errorf("video not found: %w", err)
+// CancelDownload cancels a queued or running download task
+func (m *Manager) CancelDownload(taskID string) error {
+	m.tasksMutex.Lock()
+	state, ok := m.tasks[taskID]
+	pending := ok && state.task.Status == TaskStatusPending
+	if pending {
+		// Keep workers from claiming it before finishTask runs
+		state.task.Status = TaskStatusCancelled
+	}
+	m.tasksMutex.Unlock()
+
+	if !ok {
+		task, err := m.storage.GetDownloadTask(taskID)
+		if err != nil {
+			return fmt.Errorf("error loading task: %w", err)
+		}
+		if task == nil {
+			return fmt.Errorf("download task not found: %s", taskID)
+		}
+		return fmt.Errorf("download task is already %s", task.Status)
+	}
+
+	if pending {
+		m.finishTask(state, &DownloadResult{Error: context.Canceled}, TaskStatusCancelled)
+		return nil
 	}
 
-	video.Status = "cancelled"
-	return m.storage.SaveVideoInfo(video)
+	// Running; the worker records the cancellation when the transfer stops
+	state.cancel()
+	return nil
 }
 
-// RetryDownload retries a failed download
-func (m *Manager) RetryDownload(videoID string) error {
-	video, err := m.storage.GetVideoInfo(videoID)
-	if err != nil {
-		return fmt.Errorf("video not found: %w", err)
+// RetryDownload re-queues a failed or cancelled download task under the
+// same task ID
+func (m *Manager) RetryDownload(taskID string) error {
+	m.tasksMutex.Lock()
+	_, active := m.tasks[taskID]
+	m.tasksMutex.Unlock()
+	if active {
+		return fmt.Errorf("download task is still active")
 	}
 
-	if video.Status != "failed" {
-		return fmt.Errorf("video is not in failed state")
+	task, err := m.storage.GetDownloadTask(taskID)
+	if err != nil {
+		return fmt.Errorf("error loading task: %w", err)
+	}
+	if task == nil {
+		return fmt.Errorf("download task not found: %s", taskID)
 	}
 
-	// Reset status
-	video.Status = "pending"
-	video.RetryCount = 0
-	video.ErrorMessage = ""
+	if task.Status != TaskStatusFailed && task.Status != TaskStatusCancelled {
+		return fmt.Errorf("download task is not in failed state")
+	}
 
-	if err := m.storage.SaveVideoInfo(video); err != nil {
-		return fmt.Errorf("error updating video: %w", err)
+	options := &DownloadOptions{}
+	if task.Options != "" {
+		if err := json.Unmarshal([]byte(task.Options), options); err != nil {
+			m.logger.Warn().Err(err).Str("task_id", task.ID).Msg("Error decoding task options")
+		}
 	}
 
-	// Create download request
-	req := &DownloadRequest{
-		URL:      video.URL,
-		Platform: video.Platform,
-		Options: &DownloadOptions{
-			OutputPath: filepath.Dir(video.FilePath),
-			Format:     video.Format,
-		},
+	if task.VideoID != "" {
+		if video, err := m.storage.GetVideoInfo(task.VideoID); err == nil && video != nil {
+			// Reset video status so it is not treated as downloaded
+			video.Status = "pending"
+			video.RetryCount = 0
+			video.ErrorMessage = ""
+			if err := m.storage.SaveVideoInfo(video); err != nil {
+				return fmt.Errorf("error updating video: %w", err)
+			}
+		}
 	}
 
-	// Add to queue
-	m.queue <- req
+	// Reset task
+	task.Status = TaskStatusPending
+	task.Progress = 0
+	task.Error = ""
+	task.StartedAt = nil
+	task.CompletedAt = nil
 
-	return nil
+	return m.enqueue(m.ctx, task, options)
 }
@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"video-downloader/pkg/models"
+)
+
+// imageExtensions maps sniffed image content types to file extensions
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+	"image/gif":  "gif",
+}
+
+// transferItems downloads every item of a multi-file post next to
+// outputPath as <name>_01.<ext>, <name>_02.<ext> and so on, in order, and
+// records each file on its item. Items finished by an earlier attempt are
+// kept. Progress is reported across all items.
+func (m *Manager) transferItems(ctx context.Context, req *DownloadRequest, videoInfo *models.VideoInfo, outputPath string, progressChan chan<- transferProgress) error {
+	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	total := len(videoInfo.MediaItems)
+	width := max(2, len(strconv.Itoa(total)))
+
+	for i := range videoInfo.MediaItems {
+		item := &videoInfo.MediaItems[i]
+
+		if item.FilePath != "" {
+			if _, err := os.Stat(item.FilePath); err == nil {
+				continue
+			}
+		}
+
+		format := item.Format
+		if format == "" {
+			format = itemFormat(item.MediaType)
+		}
+		path := fmt.Sprintf("%s_%0*d.%s", base, width, i+1, format)
+
+		// Scale the item's progress into the progress of the whole post
+		updates := make(chan transferProgress)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for update := range updates {
+				update.Progress = (float64(i) + update.Progress/100) / float64(total) * 100
+				progressChan <- update
+			}
+		}()
+
+		err := m.transfer(ctx, req, item.URL, path, updates)
+		if err != nil && item.FallbackURL != "" && ctx.Err() == nil {
+			m.logger.Warn().Err(err).Str("video_id", videoInfo.ID).Int("item", i).Msg("Falling back to alternate media URL")
+			err = m.transfer(ctx, req, item.FallbackURL, path, updates)
+		}
+		close(updates)
+		<-done
+
+		if err != nil {
+			return fmt.Errorf("error downloading item %d of %d: %w", i+1, total, err)
+		}
+
+		if item.MediaType == models.MediaTypeImage {
+			path = fixImageExtension(path)
+		}
+
+		stat, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("error checking item %d of %d: %w", i+1, total, err)
+		}
+		item.FilePath = path
+		item.FileSize = stat.Size()
+
+		// Persist finished items so a retry skips them
+		if err := m.storage.SaveVideoInfo(videoInfo); err != nil {
+			m.logger.Error().Err(err).Msg("Error saving media item progress")
+		}
+	}
+
+	return nil
+}
+
+// itemFormat returns the default extension for a media item
+func itemFormat(mediaType models.MediaType) string {
+	switch mediaType {
+	case models.MediaTypeImage:
+		return "jpg"
+	case models.MediaTypeAudio:
+		return "mp3"
+	default:
+		return "mp4"
+	}
+}
+
+// fixImageExtension renames an image whose content does not match its
+// extension, since platforms serve WebP or PNG from the same URLs, and
+// returns the resulting path
+func fixImageExtension(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return path
+	}
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(file, header)
+	file.Close()
+
+	ext, ok := imageExtensions[http.DetectContentType(header[:n])]
+	if !ok || strings.EqualFold(filepath.Ext(path), "."+ext) {
+		return path
+	}
+
+	renamed := strings.TrimSuffix(path, filepath.Ext(path)) + "." + ext
+	if err := os.Rename(path, renamed); err != nil {
+		return path
+	}
+	return renamed
+}
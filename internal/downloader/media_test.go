@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"video-downloader/internal/resume"
+	"video-downloader/internal/storage"
+	"video-downloader/pkg/models"
+)
+
+// Image contents the platforms serve under .jpg URLs
+var (
+	testJPEG = append([]byte{0xff, 0xd8, 0xff, 0xe0}, bytes.Repeat([]byte{0}, 60)...)
+	testPNG  = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 60)...)
+	testWebP = append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), bytes.Repeat([]byte{0}, 60)...)
+)
+
+func TestTransferItems(t *testing.T) {
+	images := map[string][]byte{
+		"/finished.jpg": testJPEG,
+		"/webp.jpg":     testWebP,
+		"/png.jpg":      testPNG,
+		"/fallback.jpg": testJPEG,
+	}
+
+	var mutex sync.Mutex
+	// requests counts the GETs for each image
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mutex.Lock()
+			requests[r.URL.Path]++
+			mutex.Unlock()
+		}
+
+		content, ok := images[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	db, err := storage.NewSQLite(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m := &Manager{
+		logger:  zerolog.Nop(),
+		storage: db,
+		resumer: resume.NewResumableDownloader(resume.ResumableConfig{
+			MetaDir:    filepath.Join(dir, "meta"),
+			TempDir:    filepath.Join(dir, "temp"),
+			MaxRetries: 1,
+		}),
+	}
+
+	// The first image was saved by an earlier attempt
+	output := filepath.Join(dir, "post.jpg")
+	finished := filepath.Join(dir, "post_01.jpg")
+	if err := os.WriteFile(finished, testJPEG, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	videoInfo := &models.VideoInfo{
+		ID:        "post",
+		Platform:  models.PlatformXHS,
+		MediaType: models.MediaTypeImage,
+		MediaItems: []models.MediaItem{
+			{Index: 0, MediaType: models.MediaTypeImage, URL: server.URL + "/finished.jpg", FilePath: finished, FileSize: int64(len(testJPEG))},
+			{Index: 1, MediaType: models.MediaTypeImage, URL: server.URL + "/webp.jpg"},
+			{Index: 2, MediaType: models.MediaTypeImage, URL: server.URL + "/png.jpg"},
+			{Index: 3, MediaType: models.MediaTypeImage, URL: server.URL + "/missing.jpg", FallbackURL: server.URL + "/fallback.jpg"},
+		},
+	}
+
+	progressChan := make(chan transferProgress)
+	go func() {
+		for range progressChan {
+		}
+	}()
+	err = m.transferItems(context.Background(), &DownloadRequest{Platform: models.PlatformXHS}, videoInfo, output, progressChan)
+	close(progressChan)
+	if err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+
+	want := []struct {
+		file    string
+		content []byte
+	}{
+		{"post_01.jpg", testJPEG},
+		{"post_02.webp", testWebP},
+		{"post_03.png", testPNG},
+		{"post_04.jpg", testJPEG},
+	}
+	for i, w := range want {
+		item := videoInfo.MediaItems[i]
+		path := filepath.Join(dir, w.file)
+		if item.FilePath != path {
+			t.Errorf("Expected item %d at %s, got %s", i, path, item.FilePath)
+		}
+		if item.FileSize != int64(len(w.content)) {
+			t.Errorf("Expected item %d to have %d bytes, got %d", i, len(w.content), item.FileSize)
+		}
+		if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, w.content) {
+			t.Errorf("Expected %s to hold the served image, got %v", w.file, err)
+		}
+	}
+
+	for path, want := range map[string]int{"/finished.jpg": 0, "/fallback.jpg": 1} {
+		mutex.Lock()
+		got := requests[path]
+		mutex.Unlock()
+		if got != want {
+			t.Errorf("Expected %d requests for %s, got %d", want, path, got)
+		}
+	}
+
+	// Finished items are saved so a retry skips them
+	stored, err := db.GetVideoInfo("post")
+	if err != nil || stored == nil {
+		t.Fatalf("Expected the post to be stored, got %v", err)
+	}
+	if len(stored.MediaItems) != 4 || stored.MediaItems[3].FilePath != filepath.Join(dir, "post_04.jpg") {
+		t.Errorf("Expected the stored items to record their files, got %+v", stored.MediaItems)
+	}
+}
@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"video-downloader/internal/postprocess"
+	"video-downloader/pkg/models"
+)
+
+// maxThumbnailSize caps how much of a thumbnail response is read
+const maxThumbnailSize = 20 << 20
+
+// embedMetadata tags the file at path with the video's details and its
+// thumbnail as cover art
+func (m *Manager) embedMetadata(ctx context.Context, videoInfo *models.VideoInfo, path string) error {
+	metadata := postprocess.Metadata{
+		Title:       videoInfo.Title,
+		Artist:      videoInfo.AuthorName,
+		Description: videoInfo.Description,
+		Comment:     videoInfo.URL,
+		EpisodeID:   fmt.Sprintf("%s:%s", videoInfo.Platform, videoInfo.ID),
+	}
+	if !videoInfo.PublishedAt.IsZero() {
+		metadata.Date = videoInfo.PublishedAt.Format("2006-01-02")
+	}
+
+	if videoInfo.Thumbnail != "" && postprocess.SupportsCover(filepath.Ext(path)) {
+		cover := strings.TrimSuffix(path, filepath.Ext(path)) + ".cover"
+		if err := m.fetchThumbnail(ctx, videoInfo, cover); err != nil {
+			// Tags alone still identify the file
+			m.logger.Warn().Err(err).Str("video_id", videoInfo.ID).Msg("Error fetching cover art")
+		} else {
+			defer os.Remove(cover)
+			metadata.Cover = cover
+		}
+	}
+
+	return m.post.EmbedMetadata(ctx, path, metadata)
+}
+
+// fetchThumbnail downloads the video's thumbnail to path
+func (m *Manager) fetchThumbnail(ctx context.Context, videoInfo *models.VideoInfo, path string) error {
+	resp, err := m.client.Get(ctx, videoInfo.Thumbnail, m.platformHeaders(videoInfo.Platform))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("thumbnail request returned status %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating thumbnail file: %w", err)
+	}
+
+	_, err = io.Copy(file, io.LimitReader(resp.Body, maxThumbnailSize))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("error writing thumbnail: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"video-downloader/internal/postprocess"
+	"video-downloader/pkg/models"
+)
+
+// MusicMode selects whether a post's soundtrack is downloaded
+type MusicMode string
+
+const (
+	// MusicNone downloads the post only
+	MusicNone MusicMode = ""
+	// MusicWith downloads the soundtrack next to the post
+	MusicWith MusicMode = "with"
+	// MusicOnly downloads the soundtrack instead of the post
+	MusicOnly MusicMode = "only"
+)
+
+// ParseMusicMode parses a music mode name. An empty string means none.
+func ParseMusicMode(s string) (MusicMode, error) {
+	switch mode := MusicMode(s); mode {
+	case MusicNone, MusicWith, MusicOnly:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown music mode: %s (use %q or %q)", s, MusicWith, MusicOnly)
+}
+
+// saveMusic stores the soundtrack extracted with videoInfo as its own
+// record. A soundtrack already downloaded for another post is kept and
+// reused.
+func (m *Manager) saveMusic(videoInfo *models.VideoInfo) {
+	music := videoInfo.Music
+	if music == nil {
+		return
+	}
+
+	if existing, err := m.storage.GetVideoInfo(music.ID); err == nil && existing != nil && existing.Status == "completed" {
+		videoInfo.Music = existing
+		return
+	}
+
+	if err := m.storage.SaveVideoInfo(music); err != nil {
+		m.logger.Error().Err(err).Str("music_id", music.ID).Msg("Error saving music info")
+	}
+}
+
+// musicRecord returns the soundtrack of videoInfo, from the extraction or
+// from storage, or nil if the post has none
+func (m *Manager) musicRecord(videoInfo *models.VideoInfo) *models.VideoInfo {
+	if videoInfo.Music != nil {
+		return videoInfo.Music
+	}
+	if videoInfo.MusicID == "" {
+		return nil
+	}
+
+	music, err := m.storage.GetVideoInfo(videoInfo.MusicID)
+	if err != nil {
+		return nil
+	}
+	return music
+}
+
+// musicRequest returns req with options suited to a soundtrack download:
+// the requested format is kept only if it is an audio format, and audio
+// extraction is dropped
+func musicRequest(req *DownloadRequest, music *models.VideoInfo) *DownloadRequest {
+	options := DownloadOptions{}
+	if req.Options != nil {
+		options = *req.Options
+	}
+	if !postprocess.IsAudioFormat(options.Format) {
+		options.Format = ""
+	}
+	options.Format = outputFormat(music, &options)
+	options.DownloadAudio = false
+	options.Music = MusicNone
+
+	musicReq := *req
+	musicReq.Options = &options
+	return &musicReq
+}
+
+// downloadMusic downloads the soundtrack of videoInfo next to the post at
+// postPath, as <name>_music.<ext>. A soundtrack whose file is already on
+// disk is not fetched again.
+func (m *Manager) downloadMusic(ctx context.Context, req *DownloadRequest, videoInfo *models.VideoInfo, postPath string) error {
+	music := m.musicRecord(videoInfo)
+	if music == nil {
+		return fmt.Errorf("no soundtrack found for %s", videoInfo.ID)
+	}
+	if music.Status == "completed" && music.FilePath != "" {
+		if _, err := os.Stat(music.FilePath); err == nil {
+			return nil
+		}
+	}
+
+	musicReq := musicRequest(req, music)
+	path := strings.TrimSuffix(postPath, filepath.Ext(postPath)) + "_music." + musicReq.Options.Format
+
+	result := m.transferVideo(ctx, musicReq, music, music.DownloadURL, path)
+	return result.Error
+}
+
+// musicMode returns the music mode requested for req
+func musicMode(req *DownloadRequest) MusicMode {
+	if req.Options == nil {
+		return MusicNone
+	}
+	return req.Options.Music
+}
+
+// downloadMusicOnly downloads the soundtrack of videoInfo instead of the
+// post. The file is named like the post would be, with an audio extension.
+func (m *Manager) downloadMusicOnly(ctx context.Context, req *DownloadRequest, videoInfo *models.VideoInfo) *DownloadResult {
+	music := videoInfo.Music
+	if music == nil {
+		return &DownloadResult{Error: fmt.Errorf("no soundtrack found for %s", videoInfo.ID)}
+	}
+	if music.Status == "completed" {
+		return &DownloadResult{Success: true, Message: "Already downloaded", Video: music}
+	}
+
+	musicReq := musicRequest(req, music)
+	outputPath, err := m.generateOutputPath(videoInfo, musicReq.Options)
+	if err != nil {
+		return &DownloadResult{Error: err}
+	}
+	defer m.releasePath(outputPath)
+
+	m.setTaskTarget(req.TaskID, music.ID, music.DownloadURL, outputPath)
+
+	return m.transferVideo(ctx, musicReq, music, music.DownloadURL, outputPath)
+}
+
+// addMusic downloads the soundtrack next to a finished post saved at
+// postPath when req asks for it. The post stays downloaded if its
+// soundtrack fails.
+func (m *Manager) addMusic(ctx context.Context, req *DownloadRequest, result *DownloadResult, postPath string) {
+	if musicMode(req) != MusicWith || !result.Success || result.Video == nil {
+		return
+	}
+
+	if err := m.downloadMusic(ctx, req, result.Video, postPath); err != nil {
+		m.logger.Warn().Err(err).Str("video_id", result.Video.ID).Msg("Error downloading soundtrack")
+		result.Message += fmt.Sprintf(" (soundtrack failed: %v)", err)
+	}
+}
@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"video-downloader/pkg/models"
+)
+
+// Quality is a parsed quality selector. Selectors are comma-separated
+// terms: "best" (the default) or "worst", a resolution cap such as "720p"
+// ("hd" means best, "sd" 480p), a codec such as "h264" or "h265", and
+// "no-watermark", e.g. "1080p,h265,no-watermark".
+type Quality struct {
+	Worst       bool
+	MaxHeight   int
+	Codec       string
+	NoWatermark bool
+
+	spec string
+}
+
+// ParseQuality parses a quality selector. An empty string means best.
+func ParseQuality(s string) (Quality, error) {
+	q := Quality{spec: s}
+
+	terms := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ',' || r == '+' || r == ' '
+	})
+	for _, term := range terms {
+		switch term {
+		case "best", "highest", "high", "hd", "source":
+			q.Worst = false
+		case "worst", "lowest", "low":
+			q.Worst = true
+		case "sd":
+			q.MaxHeight = 480
+		case "no-watermark", "nowatermark", "nwm":
+			q.NoWatermark = true
+		default:
+			if codec := normalizeCodec(term); codec != "" {
+				q.Codec = codec
+				continue
+			}
+			height, err := strconv.Atoi(strings.TrimSuffix(term, "p"))
+			if err != nil || height <= 0 {
+				return Quality{}, fmt.Errorf("unknown quality: %s", term)
+			}
+			q.MaxHeight = height
+		}
+	}
+
+	return q, nil
+}
+
+// String returns the selector as given
+func (q Quality) String() string {
+	if q.spec == "" {
+		return "best"
+	}
+	return q.spec
+}
+
+// variantQuality returns the part of the selector HLS master playlists
+// understand, see utils.SelectVariant
+func (q Quality) variantQuality() string {
+	switch {
+	case q.Worst:
+		return "worst"
+	case q.MaxHeight > 0:
+		return fmt.Sprintf("%dp", q.MaxHeight)
+	default:
+		return "best"
+	}
+}
+
+// codecAliases maps codec names and RFC 6381 prefixes to one name per codec
+var codecAliases = map[string]string{
+	"h264":    "h264",
+	"avc":     "h264",
+	"avc1":    "h264",
+	"x264":    "h264",
+	"h265":    "h265",
+	"hevc":    "h265",
+	"hvc1":    "h265",
+	"hev1":    "h265",
+	"x265":    "h265",
+	"bytevc1": "h265",
+	"av1":     "av1",
+	"av01":    "av1",
+	"vp9":     "vp9",
+	"vp09":    "vp9",
+}
+
+// normalizeCodec returns the common name of a codec, or "" if unknown
+func normalizeCodec(codec string) string {
+	codec, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(codec)), ".")
+	return codecAliases[codec]
+}
+
+// shortSide returns the resolution a rendition is known by: the shorter
+// side, so that a portrait 1080x1920 video counts as 1080p
+func shortSide(r models.Rendition) int {
+	if r.Width > 0 && r.Height > 0 {
+		return min(r.Width, r.Height)
+	}
+	return r.Height
+}
+
+// renditionLess orders renditions by resolution, then bitrate, then size
+func renditionLess(a, b models.Rendition) bool {
+	if shortSide(a) != shortSide(b) {
+		return shortSide(a) < shortSide(b)
+	}
+	if a.Bitrate != b.Bitrate {
+		return a.Bitrate < b.Bitrate
+	}
+	return a.Size < b.Size
+}
+
+// RankRenditions returns the renditions best first. Of equal renditions,
+// those without a watermark come first.
+func RankRenditions(renditions []models.Rendition) []models.Rendition {
+	ranked := append([]models.Rendition(nil), renditions...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if renditionLess(ranked[j], ranked[i]) {
+			return true
+		}
+		if renditionLess(ranked[i], ranked[j]) {
+			return false
+		}
+		return !ranked[i].Watermark && ranked[j].Watermark
+	})
+	return ranked
+}
+
+// SelectRendition picks the rendition q asks for. Codec and watermark
+// terms must be met; a resolution cap picks the best rendition no larger
+// than the cap, or the smallest one if all are larger.
+func SelectRendition(renditions []models.Rendition, q Quality) (*models.Rendition, error) {
+	var candidates []models.Rendition
+	for _, r := range RankRenditions(renditions) {
+		if r.URL == "" {
+			continue
+		}
+		if q.NoWatermark && r.Watermark {
+			continue
+		}
+		if q.Codec != "" && normalizeCodec(r.Codec) != q.Codec {
+			continue
+		}
+		candidates = append(candidates, r)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no rendition matches quality %q", q)
+	}
+
+	worst := q.Worst
+	if q.MaxHeight > 0 {
+		var capped []models.Rendition
+		for _, r := range candidates {
+			if side := shortSide(r); side > 0 && side <= q.MaxHeight {
+				capped = append(capped, r)
+			}
+		}
+		if len(capped) > 0 {
+			candidates = capped
+		} else {
+			// Everything is larger than asked for
+			worst = true
+		}
+	}
+
+	if !worst {
+		return &candidates[0], nil
+	}
+
+	// The lowest quality, preferring no watermark among equals
+	i := len(candidates) - 1
+	for i > 0 && candidates[i].Watermark && !renditionLess(candidates[i], candidates[i-1]) {
+		i--
+	}
+	return &candidates[i], nil
+}
+
+// selectRendition points videoInfo at the rendition options ask for.
+// Videos without a list of renditions keep their download URL.
+func selectRendition(videoInfo *models.VideoInfo, options *DownloadOptions) error {
+	if len(videoInfo.Renditions) == 0 {
+		return nil
+	}
+
+	quality, err := ParseQuality(options.Quality)
+	if err != nil {
+		return err
+	}
+
+	r, err := SelectRendition(videoInfo.Renditions, quality)
+	if err != nil {
+		return err
+	}
+
+	videoInfo.DownloadURL = r.URL
+	if side := shortSide(*r); side > 0 {
+		videoInfo.Quality = fmt.Sprintf("%dp", side)
+	} else if r.Label != "" {
+		videoInfo.Quality = r.Label
+	}
+	if r.Size > 0 {
+		videoInfo.Size = r.Size
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"testing"
+
+	"video-downloader/pkg/models"
+)
+
+var testRenditions = []models.Rendition{
+	{URL: "download", Width: 1080, Height: 1920, Codec: "h264", Watermark: true},
+	{URL: "play", Width: 1080, Height: 1920, Codec: "h264"},
+	{URL: "h265-1080", Width: 1080, Height: 1920, Bitrate: 2000000, Codec: "bytevc1"},
+	{URL: "h264-720", Width: 720, Height: 1280, Bitrate: 1200000, Codec: "avc1.64001f"},
+	{URL: "h264-540", Width: 540, Height: 960, Bitrate: 800000, Codec: "h264", Watermark: true},
+	{URL: "h264-540-clean", Width: 540, Height: 960, Bitrate: 800000, Codec: "h264"},
+}
+
+func TestSelectRendition(t *testing.T) {
+	tests := []struct {
+		quality, want string
+	}{
+		{"", "h265-1080"},
+		{"best", "h265-1080"},
+		{"h264", "play"},
+		{"720p", "h264-720"},
+		{"720p,h265", "h265-1080"},
+		{"worst", "h264-540-clean"},
+		{"sd", "h264-540-clean"},
+		{"360p", "h264-540-clean"},
+		{"no-watermark,worst,h264", "h264-540-clean"},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuality(tt.quality)
+		if err != nil {
+			t.Errorf("ParseQuality(%q): unexpected error: %v", tt.quality, err)
+			continue
+		}
+		r, err := SelectRendition(testRenditions, q)
+		if err != nil {
+			t.Errorf("SelectRendition(%q): unexpected error: %v", tt.quality, err)
+			continue
+		}
+		if r.URL != tt.want {
+			t.Errorf("SelectRendition(%q) = %s, want %s", tt.quality, r.URL, tt.want)
+		}
+	}
+}
+
+func TestSelectRenditionNoMatch(t *testing.T) {
+	q, err := ParseQuality("av1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := SelectRendition(testRenditions, q); err == nil {
+		t.Error("Expected an error when no rendition has the codec")
+	}
+
+	if _, err := ParseQuality("ultra"); err == nil {
+		t.Error("Expected an error for an unknown quality")
+	}
+}
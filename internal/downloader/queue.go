@@ -0,0 +1,165 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"video-downloader/pkg/models"
+)
+
+// Priority selects the lane a download is queued in. Workers always take
+// from the highest non-empty lane; within a lane requests run in order.
+type Priority string
+
+const (
+	// PriorityInteractive is for downloads a user is waiting on
+	PriorityInteractive Priority = "interactive"
+	// PriorityNormal is the default lane
+	PriorityNormal Priority = "normal"
+	// PriorityBulk is for profile scrapes and large batches
+	PriorityBulk Priority = "bulk"
+)
+
+// priorityLanes lists lanes from highest to lowest
+var priorityLanes = []Priority{PriorityInteractive, PriorityNormal, PriorityBulk}
+
+// ParsePriority parses a priority name. An empty string means normal.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+
+	for _, p := range priorityLanes {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown priority: %s", s)
+}
+
+// lane returns the lane index for the priority
+func (p Priority) lane() int {
+	for i, lane := range priorityLanes {
+		if lane == p {
+			return i
+		}
+	}
+	return 1 // normal
+}
+
+// requestQueue is a set of priority lanes with per-platform concurrency
+// limits. A request is only handed out when its platform has a free slot,
+// so a saturated platform never holds up requests for the others.
+type requestQueue struct {
+	mutex   sync.Mutex
+	lanes   [][]*DownloadRequest
+	limits  map[models.Platform]int
+	running map[models.Platform]int
+	wake    chan struct{}
+}
+
+// newRequestQueue creates a queue. A limit of zero means the platform is
+// only bounded by the number of workers.
+func newRequestQueue(limits map[models.Platform]int) *requestQueue {
+	return &requestQueue{
+		lanes:   make([][]*DownloadRequest, len(priorityLanes)),
+		limits:  limits,
+		running: make(map[models.Platform]int),
+		wake:    make(chan struct{}),
+	}
+}
+
+// push adds a request to the lane for its priority
+func (q *requestQueue) push(req *DownloadRequest) {
+	lane := PriorityNormal.lane()
+	if req.Options != nil {
+		lane = req.Options.Priority.lane()
+	}
+
+	q.mutex.Lock()
+	q.lanes[lane] = append(q.lanes[lane], req)
+	q.broadcast()
+	q.mutex.Unlock()
+}
+
+// next blocks until a runnable request is available or ctx is done. The
+// caller must call done with the request once it has finished.
+func (q *requestQueue) next(ctx context.Context) *DownloadRequest {
+	for {
+		q.mutex.Lock()
+		if req := q.pop(); req != nil {
+			q.running[req.Platform]++
+			q.mutex.Unlock()
+			return req
+		}
+		wake := q.wake
+		q.mutex.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// done releases the platform slot held by req
+func (q *requestQueue) done(req *DownloadRequest) {
+	q.mutex.Lock()
+	q.running[req.Platform]--
+	q.broadcast()
+	q.mutex.Unlock()
+}
+
+// pop removes the first request, in priority order, whose platform is
+// under its limit. Must be called with the mutex held.
+func (q *requestQueue) pop() *DownloadRequest {
+	for lane, reqs := range q.lanes {
+		for i, req := range reqs {
+			if limit := q.limits[req.Platform]; limit > 0 && q.running[req.Platform] >= limit {
+				continue
+			}
+			q.lanes[lane] = append(reqs[:i:i], reqs[i+1:]...)
+			return req
+		}
+	}
+	return nil
+}
+
+// broadcast wakes all waiting workers. Must be called with the mutex held.
+func (q *requestQueue) broadcast() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// len returns the number of queued requests
+func (q *requestQueue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	n := 0
+	for _, reqs := range q.lanes {
+		n += len(reqs)
+	}
+	return n
+}
+
+// stats returns queued requests per lane and running requests per platform
+func (q *requestQueue) stats() (map[Priority]int, map[models.Platform]int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	queued := make(map[Priority]int, len(priorityLanes))
+	for i, reqs := range q.lanes {
+		queued[priorityLanes[i]] = len(reqs)
+	}
+
+	running := make(map[models.Platform]int, len(q.running))
+	for platform, n := range q.running {
+		running[platform] = n
+	}
+
+	return queued, running
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"video-downloader/pkg/models"
+)
+
+func newTestRequest(id string, platform models.Platform, priority Priority) *DownloadRequest {
+	return &DownloadRequest{
+		TaskID:   id,
+		Platform: platform,
+		Options:  &DownloadOptions{Priority: priority},
+	}
+}
+
+func TestRequestQueuePriority(t *testing.T) {
+	q := newRequestQueue(nil)
+
+	q.push(newTestRequest("bulk", models.PlatformTikTok, PriorityBulk))
+	q.push(newTestRequest("normal", models.PlatformTikTok, ""))
+	q.push(newTestRequest("interactive", models.PlatformTikTok, PriorityInteractive))
+
+	ctx := context.Background()
+	for _, want := range []string{"interactive", "normal", "bulk"} {
+		req := q.next(ctx)
+		if req.TaskID != want {
+			t.Errorf("Expected %s, got %s", want, req.TaskID)
+		}
+	}
+
+	if q.len() != 0 {
+		t.Errorf("Expected empty queue, got %d", q.len())
+	}
+}
+
+func TestRequestQueuePlatformLimit(t *testing.T) {
+	q := newRequestQueue(map[models.Platform]int{
+		models.PlatformKuaishou: 1,
+	})
+
+	q.push(newTestRequest("ks1", models.PlatformKuaishou, PriorityInteractive))
+	q.push(newTestRequest("ks2", models.PlatformKuaishou, PriorityInteractive))
+	q.push(newTestRequest("tt1", models.PlatformTikTok, PriorityBulk))
+
+	ctx := context.Background()
+	first := q.next(ctx)
+	if first.TaskID != "ks1" {
+		t.Fatalf("Expected ks1, got %s", first.TaskID)
+	}
+
+	// ks2 is blocked by the cap, so the lower priority TikTok request runs
+	second := q.next(ctx)
+	if second.TaskID != "tt1" {
+		t.Fatalf("Expected tt1, got %s", second.TaskID)
+	}
+
+	// Nothing runnable until ks1 finishes
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if req := q.next(waitCtx); req != nil {
+		t.Fatalf("Expected no runnable request, got %s", req.TaskID)
+	}
+
+	q.done(first)
+	if req := q.next(ctx); req.TaskID != "ks2" {
+		t.Errorf("Expected ks2, got %s", req.TaskID)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	if p, err := ParsePriority(""); err != nil || p != PriorityNormal {
+		t.Errorf("Expected normal priority for empty string, got %q (%v)", p, err)
+	}
+
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("Expected error for unknown priority")
+	}
+}
@@ -0,0 +1,177 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"video-downloader/pkg/models"
+)
+
+// Sidecar file suffixes, appended to the media file name without its
+// extension
+const (
+	sidecarInfo        = ".info.json"
+	sidecarThumbnail   = ".jpg"
+	sidecarDescription = ".description"
+)
+
+// writeSidecars writes the info.json, thumbnail and description files for
+// the media file at path. Every file is attempted; the errors are joined.
+func (m *Manager) writeSidecars(ctx context.Context, videoInfo *models.VideoInfo, path string) error {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	var errs []error
+
+	data, err := json.MarshalIndent(videoInfo, "", "  ")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error encoding video info: %w", err))
+	} else if err := os.WriteFile(base+sidecarInfo, data, 0644); err != nil {
+		errs = append(errs, fmt.Errorf("error writing info file: %w", err))
+	}
+
+	if videoInfo.Description != "" {
+		if err := os.WriteFile(base+sidecarDescription, []byte(videoInfo.Description), 0644); err != nil {
+			errs = append(errs, fmt.Errorf("error writing description file: %w", err))
+		}
+	}
+
+	// Image posts are already saved as JPEG under the same name
+	if videoInfo.Thumbnail != "" && base+sidecarThumbnail != path {
+		if err := m.writeThumbnail(ctx, videoInfo, base+sidecarThumbnail); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeThumbnail saves the video's thumbnail as a JPEG file. Platforms
+// often serve WebP covers; those are converted when ffmpeg is available
+// and kept as served otherwise.
+func (m *Manager) writeThumbnail(ctx context.Context, videoInfo *models.VideoInfo, path string) error {
+	temp := path + ".download"
+	if err := m.fetchThumbnail(ctx, videoInfo, temp); err != nil {
+		return fmt.Errorf("error fetching thumbnail: %w", err)
+	}
+	defer os.Remove(temp)
+
+	if !isJPEG(temp) && m.post.Available() {
+		if err := m.post.ConvertImage(ctx, temp, path); err != nil {
+			return fmt.Errorf("error converting thumbnail: %w", err)
+		}
+		return nil
+	}
+
+	return os.Rename(temp, path)
+}
+
+// isJPEG sniffs whether the file at path is a JPEG image
+func isJPEG(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(file, header)
+	return http.DetectContentType(header[:n]) == "image/jpeg"
+}
+
+// ImportSidecars walks dir for info.json sidecars and saves the videos they
+// describe to storage. A video whose media file is found next to its
+// sidecar is recorded as completed at that path; otherwise it is stored as
+// pending. It returns the number of videos imported; sidecars that cannot
+// be read are skipped and reported in the error.
+func (m *Manager) ImportSidecars(dir string) (int, error) {
+	imported := 0
+	var errs []error
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), sidecarInfo) {
+			return nil
+		}
+
+		videoInfo, err := readSidecar(path)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+
+		if err := m.storage.SaveVideoInfo(videoInfo); err != nil {
+			errs = append(errs, fmt.Errorf("error saving %s: %w", videoInfo.ID, err))
+			return nil
+		}
+
+		imported++
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return imported, errors.Join(errs...)
+}
+
+// readSidecar loads the video described by an info.json sidecar and points
+// it at the media file next to it
+func readSidecar(path string) (*models.VideoInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	var videoInfo models.VideoInfo
+	if err := json.Unmarshal(data, &videoInfo); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if videoInfo.ID == "" || videoInfo.Platform == "" {
+		return nil, fmt.Errorf("%s: missing video ID or platform", path)
+	}
+
+	// The archive may have moved since the sidecar was written
+	base := strings.TrimSuffix(path, sidecarInfo)
+	candidates := []string{}
+	if videoInfo.FilePath != "" {
+		candidates = append(candidates, filepath.Join(filepath.Dir(path), filepath.Base(videoInfo.FilePath)))
+	}
+	if videoInfo.Format != "" {
+		candidates = append(candidates, base+"."+videoInfo.Format)
+	}
+
+	for i := range videoInfo.MediaItems {
+		item := &videoInfo.MediaItems[i]
+		if item.FilePath != "" {
+			item.FilePath = filepath.Join(filepath.Dir(path), filepath.Base(item.FilePath))
+		}
+	}
+
+	videoInfo.FilePath = ""
+	videoInfo.Status = "pending"
+	for _, candidate := range candidates {
+		if stat, err := os.Stat(candidate); err == nil && !stat.IsDir() {
+			videoInfo.FilePath = candidate
+			if len(videoInfo.MediaItems) == 0 {
+				videoInfo.FileSize = stat.Size()
+			}
+			videoInfo.Status = "completed"
+			if videoInfo.DownloadedAt == nil {
+				modTime := stat.ModTime()
+				videoInfo.DownloadedAt = &modTime
+			}
+			break
+		}
+	}
+
+	return &videoInfo, nil
+}
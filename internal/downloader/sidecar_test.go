@@ -0,0 +1,199 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"video-downloader/internal/storage"
+	"video-downloader/pkg/models"
+)
+
+func writeSidecar(t *testing.T, path string, videoInfo *models.VideoInfo) {
+	t.Helper()
+
+	data, err := json.Marshal(videoInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeMedia(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("media:"+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadSidecar(t *testing.T) {
+	tests := []struct {
+		name      string
+		videoInfo models.VideoInfo
+		// media are the files next to the sidecar, named video.info.json
+		media      []string
+		wantStatus string
+		// wantFile is the media file the video points at, relative to the
+		// sidecar's directory
+		wantFile  string
+		wantItems []string
+	}{
+		{
+			name:       "relocated archive",
+			videoInfo:  models.VideoInfo{ID: "v1", Platform: models.PlatformTikTok, Format: "mp4", FilePath: "/old/archive/Some Title_v1.mp4"},
+			media:      []string{"Some Title_v1.mp4"},
+			wantStatus: "completed",
+			wantFile:   "Some Title_v1.mp4",
+		},
+		{
+			name:       "media file next to sidecar",
+			videoInfo:  models.VideoInfo{ID: "v2", Platform: models.PlatformKuaishou, Format: "mp4"},
+			media:      []string{"video.mp4"},
+			wantStatus: "completed",
+			wantFile:   "video.mp4",
+		},
+		{
+			name: "image post",
+			videoInfo: models.VideoInfo{
+				ID:        "v3",
+				Platform:  models.PlatformXHS,
+				MediaType: models.MediaTypeImage,
+				FilePath:  "/old/archive/video_01.jpg",
+				MediaItems: []models.MediaItem{
+					{Index: 0, MediaType: models.MediaTypeImage, FilePath: "/old/archive/video_01.jpg"},
+					{Index: 1, MediaType: models.MediaTypeImage, FilePath: "/old/archive/video_02.webp"},
+					{Index: 2, MediaType: models.MediaTypeImage},
+				},
+			},
+			media:      []string{"video_01.jpg", "video_02.webp"},
+			wantStatus: "completed",
+			wantFile:   "video_01.jpg",
+			wantItems:  []string{"video_01.jpg", "video_02.webp", ""},
+		},
+		{
+			name:       "media file missing",
+			videoInfo:  models.VideoInfo{ID: "v4", Platform: models.PlatformTikTok, Format: "mp4", FilePath: "/old/archive/video.mp4", Status: "completed"},
+			wantStatus: "pending",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "video.info.json")
+			writeSidecar(t, path, &tt.videoInfo)
+			writeMedia(t, dir, tt.media...)
+
+			got, err := readSidecar(path)
+			if err != nil {
+				t.Fatalf("Failed to read sidecar: %v", err)
+			}
+
+			if got.Status != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, got.Status)
+			}
+
+			wantFile := ""
+			if tt.wantFile != "" {
+				wantFile = filepath.Join(dir, tt.wantFile)
+			}
+			if got.FilePath != wantFile {
+				t.Errorf("Expected file %q, got %q", wantFile, got.FilePath)
+			}
+
+			if tt.wantStatus == "completed" {
+				if got.DownloadedAt == nil {
+					t.Error("Expected the download time to be set")
+				}
+				if len(got.MediaItems) == 0 && got.FileSize != int64(len("media:"+tt.wantFile)) {
+					t.Errorf("Expected the size of %s, got %d", tt.wantFile, got.FileSize)
+				}
+			}
+
+			if len(got.MediaItems) != len(tt.wantItems) {
+				t.Fatalf("Expected %d media items, got %d", len(tt.wantItems), len(got.MediaItems))
+			}
+			for i, item := range got.MediaItems {
+				want := ""
+				if tt.wantItems[i] != "" {
+					want = filepath.Join(dir, tt.wantItems[i])
+				}
+				if item.FilePath != want {
+					t.Errorf("Expected item %d at %q, got %q", i, want, item.FilePath)
+				}
+			}
+		})
+	}
+}
+
+func TestReadSidecarInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"not json", "{", "error parsing"},
+		{"missing id", `{"platform": "tiktok"}`, "missing video ID or platform"},
+		{"missing platform", `{"id": "v1"}`, "missing video ID or platform"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "video.info.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := readSidecar(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestImportSidecars(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	archive := filepath.Join(dir, "archive")
+	writeSidecar(t, filepath.Join(archive, "tiktok", "a.info.json"), &models.VideoInfo{ID: "a", Platform: models.PlatformTikTok, Format: "mp4"})
+	writeMedia(t, filepath.Join(archive, "tiktok"), "a.mp4")
+	writeSidecar(t, filepath.Join(archive, "xhs", "b.info.json"), &models.VideoInfo{ID: "b", Platform: models.PlatformXHS, Format: "mp4"})
+	if err := os.WriteFile(filepath.Join(archive, "broken.info.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manager{storage: db}
+	imported, err := m.ImportSidecars(archive)
+	if imported != 2 {
+		t.Errorf("Expected 2 videos imported, got %d", imported)
+	}
+	if err == nil || !strings.Contains(err.Error(), "broken.info.json") {
+		t.Errorf("Expected the broken sidecar to be reported, got %v", err)
+	}
+
+	for id, want := range map[string]string{"a": "completed", "b": "pending"} {
+		video, err := db.GetVideoInfo(id)
+		if err != nil || video == nil {
+			t.Fatalf("Expected video %s to be stored, got %v", id, err)
+		}
+		if video.Status != want {
+			t.Errorf("Expected video %s to be %s, got %s", id, want, video.Status)
+		}
+	}
+}
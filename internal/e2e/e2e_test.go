@@ -0,0 +1,830 @@
+// Package e2e runs the downloader end to end against the fake platforms
+// of platformtest: extraction, download, resume, batch jobs, subscriptions,
+// comments and export all go through the public APIs and real storage, offline.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"video-downloader/internal/batch"
+	"video-downloader/internal/comment"
+	"video-downloader/internal/downloader"
+	"video-downloader/internal/export"
+	"video-downloader/internal/platform/platformtest"
+	"video-downloader/internal/registry"
+	"video-downloader/internal/storage"
+	"video-downloader/internal/subscription"
+	"video-downloader/pkg/models"
+)
+
+// env is one isolated installation: a working directory holding the
+// database, temp files and downloads, wired to the fake platforms
+type env struct {
+	dir       string
+	cfg       *models.Config
+	platforms *platformtest.Platforms
+	storage   *storage.SQLite
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+
+	dir := t.TempDir()
+	// The downloaders keep their temp files under the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg := &models.Config{}
+	cfg.Download.MaxWorkers = 2
+	cfg.Download.ChunkSize = 32 * 1024
+	cfg.Download.Connections = 1
+	cfg.Download.Timeout = 30
+	cfg.Download.RetryCount = 1
+	cfg.Download.SavePath = filepath.Join(dir, "downloads")
+	cfg.Download.FileNaming = "{platform}/{id}"
+	cfg.FFmpeg.Path = filepath.Join(dir, "no-ffmpeg")
+	cfg.Platforms.TikTok.Enabled = true
+	cfg.Platforms.XHS.Enabled = true
+	cfg.Platforms.Kuaishou.Enabled = true
+
+	e := &env{
+		dir:       dir,
+		cfg:       cfg,
+		platforms: platformtest.NewPlatforms(t),
+	}
+	e.openStorage(t)
+
+	return e
+}
+
+// openStorage opens the database, as a new process would
+func (e *env) openStorage(t *testing.T) {
+	t.Helper()
+
+	db, err := storage.NewSQLite(filepath.Join(e.dir, "video-downloader.db"))
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	e.storage = db
+}
+
+// startManager starts a download manager that is stopped when the test
+// finishes
+func (e *env) startManager(t *testing.T) *downloader.Manager {
+	t.Helper()
+
+	m := downloader.NewManager(e.cfg, e.storage, e.platforms.Factory())
+	if err := m.Start(); err != nil {
+		t.Fatalf("Failed to start download manager: %v", err)
+	}
+	t.Cleanup(func() { m.Stop() })
+
+	return m
+}
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		name    string
+		video   func(p *platformtest.Platforms) platformtest.Video
+		options *downloader.DownloadOptions
+		ext     string
+		// connections, when set, splits the download into that many
+		// ranged requests
+		connections int
+	}{
+		{
+			name:    "tiktok with music",
+			video:   func(p *platformtest.Platforms) platformtest.Video { return p.TikTok },
+			options: &downloader.DownloadOptions{Music: downloader.MusicWith},
+			ext:     ".mp4",
+		},
+		{
+			// Kept as MPEG-TS since there is no ffmpeg to remux it
+			name:  "xhs hls",
+			video: func(p *platformtest.Platforms) platformtest.Video { return p.XHS },
+			ext:   ".ts",
+		},
+		{
+			name:  "kuaishou graphql",
+			video: func(p *platformtest.Platforms) platformtest.Video { return p.Kuaishou },
+			ext:   ".mp4",
+		},
+		{
+			name:        "kuaishou ranged",
+			video:       func(p *platformtest.Platforms) platformtest.Video { return p.Kuaishou },
+			ext:         ".mp4",
+			connections: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			if tt.connections > 0 {
+				e.cfg.Download.Connections = tt.connections
+			}
+			m := e.startManager(t)
+			video := tt.video(e.platforms)
+
+			result := download(t, m, video.URL, tt.options)
+
+			if tt.connections > 0 {
+				ranges := 0
+				for _, req := range e.platforms.Requests() {
+					if req.URL == video.MediaURL && req.Method == http.MethodGet && req.Range != "" {
+						ranges++
+					}
+				}
+				if ranges != tt.connections {
+					t.Errorf("Expected %d ranged requests for the media, got %d", tt.connections, ranges)
+				}
+			}
+
+			if result.Video.ID != video.ID {
+				t.Errorf("Expected video ID %s, got %s", video.ID, result.Video.ID)
+			}
+			if ext := filepath.Ext(result.Video.FilePath); ext != tt.ext {
+				t.Errorf("Expected a %s file, got %s", tt.ext, result.Video.FilePath)
+			}
+			assertFile(t, result.Video.FilePath, video.Media)
+
+			stored, err := e.storage.GetVideoInfo(video.ID)
+			if err != nil {
+				t.Fatalf("Video not stored: %v", err)
+			}
+			if stored.Status != "completed" {
+				t.Errorf("Expected stored status completed, got %s", stored.Status)
+			}
+			if stored.FileSize != int64(len(video.Media)) {
+				t.Errorf("Expected stored size %d, got %d", len(video.Media), stored.FileSize)
+			}
+
+			if video.Music == nil || tt.options == nil || tt.options.Music == "" {
+				return
+			}
+			music, err := e.storage.GetVideoInfo(stored.MusicID)
+			if err != nil {
+				t.Fatalf("Music not stored: %v", err)
+			}
+			assertFile(t, music.FilePath, video.Music)
+		})
+	}
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	e := newEnv(t)
+	e.cfg.Download.ResumeQueue = true
+	video := e.platforms.TikTok
+
+	const cut = 64 * 1024
+	sent := e.platforms.Interrupt(video.MediaURL, cut)
+
+	first := e.startManager(t)
+	taskID, err := first.Download(context.Background(), video.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to queue download: %v", err)
+	}
+
+	select {
+	case <-sent:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the media request")
+	}
+	waitFor(t, "partial file", func() bool {
+		partial, _ := filepath.Glob(filepath.Join(e.dir, "temp", "downloads", "*.tmp"))
+		if len(partial) != 1 {
+			return false
+		}
+		stat, err := os.Stat(partial[0])
+		return err == nil && stat.Size() == cut
+	})
+
+	first.Stop()
+	e.storage.Close()
+
+	// A new process picks the task up from the database
+	e.openStorage(t)
+	second := e.startManager(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := second.Wait(ctx, taskID)
+	if err != nil {
+		t.Fatalf("Failed to wait for task: %v", err)
+	}
+	if result.Error != nil {
+		t.Fatalf("Resumed download failed: %v", result.Error)
+	}
+	assertFile(t, result.Video.FilePath, video.Media)
+
+	var ranges []string
+	extractions := 0
+	for _, req := range e.platforms.Requests() {
+		if req.URL == video.MediaURL && req.Method == "GET" {
+			ranges = append(ranges, req.Range)
+		}
+		if strings.Contains(req.URL, "/aweme/v1/feed/") {
+			extractions++
+		}
+	}
+
+	want := []string{"", fmt.Sprintf("bytes=%d-", cut)}
+	if strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected media requests with ranges %q, got %q", want, ranges)
+	}
+	if extractions != 1 {
+		t.Errorf("Expected the resume to skip extraction, got %d extractions", extractions)
+	}
+}
+
+func TestBatchDownloadAndExport(t *testing.T) {
+	e := newEnv(t)
+	m := e.startManager(t)
+
+	reg := registry.NewRegistry()
+	if err := reg.RegisterDefaultPlatforms(e.cfg, e.platforms.Factory()); err != nil {
+		t.Fatalf("Failed to register platforms: %v", err)
+	}
+
+	bm := batch.NewBatchManager(reg, m, 2)
+	bm.SetStorage(e.storage)
+	t.Cleanup(func() { bm.Close() })
+
+	videos := []platformtest.Video{e.platforms.TikTok, e.platforms.XHS, e.platforms.Kuaishou}
+	urls := make([]string, 0, len(videos)+1)
+	for _, video := range videos {
+		urls = append(urls, video.URL)
+	}
+	urls = append(urls, "https://www.tiktok.com/@harbourviews/video/7300000000000000000")
+
+	job, err := bm.StartBatchDownload(batch.BatchJobTypeURLList, urls, batch.BatchDownloadConfig{})
+	if err != nil {
+		t.Fatalf("Failed to start batch: %v", err)
+	}
+
+	info := waitForJob(t, job)
+
+	if info.Status != batch.JobStatusPartial {
+		t.Errorf("Expected status %s, got %s", batch.JobStatusPartial, info.Status)
+	}
+	if info.Progress.Completed != len(videos) || info.Progress.Failed != 1 {
+		t.Errorf("Expected %d completed and 1 failed, got %+v", len(videos), info.Progress)
+	}
+
+	for _, video := range videos {
+		stored, err := e.storage.GetVideoInfo(video.ID)
+		if err != nil {
+			t.Fatalf("Video %s not stored: %v", video.ID, err)
+		}
+		assertFile(t, stored.FilePath, video.Media)
+	}
+
+	completed := "completed"
+	stored, err := e.storage.ListVideos(models.VideoFilter{Status: &completed})
+	if err != nil {
+		t.Fatalf("Failed to list videos: %v", err)
+	}
+
+	csvPath := filepath.Join(e.dir, "export", "videos.csv")
+	if err := export.NewDataExporter(export.ExportConfig{Format: export.FormatCSV, FilePath: csvPath}).ExportVideos(stored); err != nil {
+		t.Fatalf("Failed to export CSV: %v", err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatalf("Failed to open CSV export: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV export: %v", err)
+	}
+	if len(rows) != len(videos)+1 {
+		t.Errorf("Expected a header and %d rows, got %d rows", len(videos), len(rows))
+	}
+
+	jsonPath := filepath.Join(e.dir, "export", "videos.json")
+	if err := export.NewDataExporter(export.ExportConfig{Format: export.FormatJSON, FilePath: jsonPath}).ExportVideos(stored); err != nil {
+		t.Fatalf("Failed to export JSON: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("Failed to read JSON export: %v", err)
+	}
+	for _, video := range videos {
+		if !bytes.Contains(data, []byte(video.ID)) {
+			t.Errorf("Expected JSON export to contain %s", video.ID)
+		}
+	}
+}
+
+// A job in which nothing was downloaded fails even when some items were
+// skipped, and can be retried
+func TestBatchSkippedAndFailed(t *testing.T) {
+	e := newEnv(t)
+	m := e.startManager(t)
+
+	reg := registry.NewRegistry()
+	if err := reg.RegisterDefaultPlatforms(e.cfg, e.platforms.Factory()); err != nil {
+		t.Fatalf("Failed to register platforms: %v", err)
+	}
+
+	bm := batch.NewBatchManager(reg, m, 2)
+	bm.SetStorage(e.storage)
+	t.Cleanup(func() { bm.Close() })
+
+	video := e.platforms.Kuaishou
+	config := batch.BatchDownloadConfig{SkipExisting: true}
+	runBatch := func(urls []string) *batch.BatchJobInfo {
+		t.Helper()
+
+		job, err := bm.StartBatchDownload(batch.BatchJobTypeURLList, urls, config)
+		if err != nil {
+			t.Fatalf("Failed to start batch: %v", err)
+		}
+		return waitForJob(t, job)
+	}
+
+	if info := runBatch([]string{video.URL}); info.Status != batch.JobStatusCompleted {
+		t.Fatalf("Expected status %s, got %s", batch.JobStatusCompleted, info.Status)
+	}
+
+	info := runBatch([]string{video.URL, "https://www.tiktok.com/@harbourviews/video/7300000000000000000"})
+	if info.Status != batch.JobStatusFailed {
+		t.Errorf("Expected status %s, got %s", batch.JobStatusFailed, info.Status)
+	}
+	if info.Progress.Completed != 0 || info.Progress.Skipped != 1 || info.Progress.Failed != 1 {
+		t.Errorf("Expected 1 skipped and 1 failed, got %+v", info.Progress)
+	}
+
+	retry, err := bm.RetryFailed(info.ID)
+	if err != nil {
+		t.Fatalf("Failed to retry job: %v", err)
+	}
+	if info := waitForJob(t, retry); info.Status != batch.JobStatusFailed {
+		t.Errorf("Expected status %s after retry, got %s", batch.JobStatusFailed, info.Status)
+	}
+}
+
+func TestBatchProfileRetry(t *testing.T) {
+	e := newEnv(t)
+	m := e.startManager(t)
+	video := e.platforms.Kuaishou
+
+	reg := registry.NewRegistry()
+	if err := reg.RegisterDefaultPlatforms(e.cfg, e.platforms.Factory()); err != nil {
+		t.Fatalf("Failed to register platforms: %v", err)
+	}
+	extractor, err := reg.GetExtractor(models.PlatformKuaishou)
+	if err != nil {
+		t.Fatalf("Failed to get extractor: %v", err)
+	}
+	available := "https://www.kuaishou.com/profile/3xq7wz8ab2cd4ef"
+	unavailable := "https://www.kuaishou.com/profile/3xk2pq9rs7tu5vw"
+	profiles := &profileExtractor{
+		PlatformExtractor: extractor,
+		urls:              []string{video.URL},
+		unavailable:       map[string]bool{unavailable: true},
+	}
+	reg.RegisterExtractor(models.PlatformKuaishou, profiles, nil)
+
+	bm := batch.NewBatchManager(reg, m, 2)
+	bm.SetStorage(e.storage)
+	t.Cleanup(func() { bm.Close() })
+
+	job, err := bm.StartBatchDownload(batch.BatchJobTypeUserProfile, []string{available, unavailable}, batch.BatchDownloadConfig{SkipExisting: true})
+	if err != nil {
+		t.Fatalf("Failed to start batch: %v", err)
+	}
+	info := waitForJob(t, job)
+	if info.Status != batch.JobStatusPartial {
+		t.Errorf("Expected status %s, got %s", batch.JobStatusPartial, info.Status)
+	}
+	if info.Progress.Completed != 1 || info.Progress.Failed != 1 || info.Progress.Total != 2 {
+		t.Errorf("Expected 1 completed and the profile failed, got %+v", info.Progress)
+	}
+	var failed []string
+	for _, result := range info.Results {
+		if result.Status == "failed" {
+			failed = append(failed, result.URL)
+		}
+	}
+	if len(failed) != 1 || failed[0] != unavailable {
+		t.Errorf("Expected a failed result for %s, got %v", unavailable, failed)
+	}
+
+	// The profile is listed again on retry rather than downloaded as a video
+	profiles.unavailable = nil
+	retry, err := bm.RetryFailed(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to retry job: %v", err)
+	}
+	info = waitForJob(t, retry)
+	if info.Status != batch.JobStatusCompleted {
+		t.Errorf("Expected status %s after retry, got %s", batch.JobStatusCompleted, info.Status)
+	}
+	if info.Progress.Completed != 1 || info.Progress.Skipped != 1 || info.Progress.Failed != 0 {
+		t.Errorf("Expected the profile's post to be skipped as downloaded, got %+v", info.Progress)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform models.Platform
+		video    func(p *platformtest.Platforms) platformtest.Video
+	}{
+		{"tiktok", models.PlatformTikTok, func(p *platformtest.Platforms) platformtest.Video { return p.TikTok }},
+		{"xhs", models.PlatformXHS, func(p *platformtest.Platforms) platformtest.Video { return p.XHS }},
+		{"kuaishou", models.PlatformKuaishou, func(p *platformtest.Platforms) platformtest.Video { return p.Kuaishou }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			video := tt.video(e.platforms)
+			ce := comment.NewCommentExtractor(e.platforms.Factory().Transport())
+
+			threads, err := ce.ExtractComments(context.Background(), comment.CommentExtractConfig{
+				VideoID:        video.ID,
+				Platform:       tt.platform,
+				Limit:          20,
+				IncludeReplies: true,
+			})
+			if err != nil {
+				t.Fatalf("Failed to extract comments: %v", err)
+			}
+
+			if len(threads) != video.Comments {
+				t.Fatalf("Expected %d threads, got %d", video.Comments, len(threads))
+			}
+			replies := 0
+			mentions := 0
+			for _, thread := range threads {
+				if thread.Comment.VideoID != video.ID || thread.Comment.Platform != tt.platform {
+					t.Errorf("Unexpected comment %+v", thread.Comment)
+				}
+				replies += len(thread.Replies)
+				mentions += len(thread.Comment.Mentions)
+			}
+			if replies != video.Replies {
+				t.Errorf("Expected %d replies, got %d", video.Replies, replies)
+			}
+			if mentions != 1 {
+				t.Errorf("Expected 1 mention, got %d", mentions)
+			}
+
+			for _, format := range []string{"json", "csv", "txt"} {
+				path := filepath.Join(e.dir, "comments."+format)
+				if err := ce.ExportComments(threads, format, path); err != nil {
+					t.Fatalf("Failed to export %s: %v", format, err)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read %s export: %v", format, err)
+				}
+				if !bytes.Contains(data, []byte(threads[0].Comment.Content)) {
+					t.Errorf("Expected %s export to contain %q", format, threads[0].Comment.Content)
+				}
+			}
+
+			var exported struct {
+				Total int `json:"total"`
+			}
+			data, _ := os.ReadFile(filepath.Join(e.dir, "comments.json"))
+			if err := json.Unmarshal(data, &exported); err != nil || exported.Total != len(threads) {
+				t.Errorf("Expected JSON export total %d, got %d (%v)", len(threads), exported.Total, err)
+			}
+
+			report := ce.GetCommentStats(threads)
+			if report.TotalComments != video.Comments || report.TotalReplies != video.Replies || len(report.Mentions) != 1 {
+				t.Errorf("Unexpected report %+v", report)
+			}
+			for _, format := range export.GetSupportedFormats() {
+				path := filepath.Join(e.dir, "report."+string(format))
+				exporter := export.NewDataExporter(export.ExportConfig{Format: format, FilePath: path})
+				if err := exporter.ExportCommentReport(report); err != nil {
+					t.Errorf("Failed to export %s report: %v", format, err)
+				}
+				if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+					t.Errorf("Expected a %s report at %s (%v)", format, path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreComments(t *testing.T) {
+	e := newEnv(t)
+	video := e.platforms.Kuaishou
+	ce := comment.NewCommentExtractor(e.platforms.Factory().Transport())
+	config := comment.CommentExtractConfig{
+		VideoID:        video.ID,
+		Platform:       models.PlatformKuaishou,
+		IncludeReplies: true,
+	}
+
+	first, err := ce.Store(context.Background(), config, e.storage)
+	if err != nil {
+		t.Fatalf("Failed to store comments: %v", err)
+	}
+	want := video.Comments + video.Replies
+	if first.Threads != video.Comments || first.Comments != want || first.Added != want {
+		t.Errorf("Expected %d threads and %d new comments, got %+v", video.Comments, want, first)
+	}
+
+	// Likes changed since the first crawl; only counts are updated
+	stored, err := e.storage.ListComments(models.CommentFilter{VideoID: video.ID, TopLevel: true})
+	if err != nil {
+		t.Fatalf("Failed to list comments: %v", err)
+	}
+	if len(stored) != video.Comments {
+		t.Fatalf("Expected %d top-level comments, got %d", video.Comments, len(stored))
+	}
+	stale := *stored[0]
+	stale.LikeCount = 0
+	stale.Content = "edited"
+	if _, err := e.storage.SaveComments([]*models.CommentRecord{&stale}); err != nil {
+		t.Fatalf("Failed to save comment: %v", err)
+	}
+
+	second, err := ce.Store(context.Background(), config, e.storage)
+	if err != nil {
+		t.Fatalf("Failed to store comments again: %v", err)
+	}
+	if second.Comments != want || second.Added != 0 {
+		t.Errorf("Expected %d comments and none new on the second run, got %+v", want, second)
+	}
+
+	total, err := e.storage.CountComments(models.CommentFilter{VideoID: video.ID})
+	if err != nil {
+		t.Fatalf("Failed to count comments: %v", err)
+	}
+	if total != int64(want) {
+		t.Errorf("Expected %d stored comments, got %d", want, total)
+	}
+
+	refreshed, err := e.storage.ListComments(models.CommentFilter{VideoID: video.ID, TopLevel: true})
+	if err != nil {
+		t.Fatalf("Failed to list comments: %v", err)
+	}
+	if refreshed[0].LikeCount != stored[0].LikeCount {
+		t.Errorf("Expected like count %d to be restored, got %d", stored[0].LikeCount, refreshed[0].LikeCount)
+	}
+	if refreshed[0].Content != stored[0].Content {
+		t.Errorf("Expected content %q to be left alone, got %q", stored[0].Content, refreshed[0].Content)
+	}
+
+	replies, err := e.storage.ListComments(models.CommentFilter{ParentID: stored[0].ID})
+	if err != nil {
+		t.Fatalf("Failed to list replies: %v", err)
+	}
+	if len(replies) != video.Replies {
+		t.Errorf("Expected %d replies to %s, got %d", video.Replies, stored[0].ID, len(replies))
+	}
+}
+
+func TestSubscriptionSync(t *testing.T) {
+	e := newEnv(t)
+	m := e.startManager(t)
+	video := e.platforms.Kuaishou
+
+	reg := registry.NewRegistry()
+	if err := reg.RegisterDefaultPlatforms(e.cfg, e.platforms.Factory()); err != nil {
+		t.Fatalf("Failed to register platforms: %v", err)
+	}
+	// The fake platforms serve no profile listings, so the profile lists
+	// the one Kuaishou post they do serve
+	extractor, err := reg.GetExtractor(models.PlatformKuaishou)
+	if err != nil {
+		t.Fatalf("Failed to get extractor: %v", err)
+	}
+	reg.RegisterExtractor(models.PlatformKuaishou, &profileExtractor{PlatformExtractor: extractor, urls: []string{video.URL}}, nil)
+
+	bm := batch.NewBatchManager(reg, m, 2)
+	bm.SetStorage(e.storage)
+	t.Cleanup(func() { bm.Close() })
+
+	scheduler := subscription.NewScheduler(reg, bm, e.storage)
+	sub := &models.Subscription{Platform: models.PlatformKuaishou, AuthorID: "3xq7wz8ab2cd4ef", Enabled: true}
+	if err := scheduler.Add(sub); err != nil {
+		t.Fatalf("Failed to add subscription: %v", err)
+	}
+	if err := scheduler.Add(&models.Subscription{Platform: sub.Platform, AuthorID: sub.AuthorID}); !errors.Is(err, subscription.ErrSubscribed) {
+		t.Errorf("Expected a second subscription to the author to fail, got %v", err)
+	}
+
+	// Saved without being downloaded, as the comments command does
+	info, err := reg.ExtractVideoInfo(context.Background(), video.URL)
+	if err != nil {
+		t.Fatalf("Failed to extract video: %v", err)
+	}
+	if err := e.storage.SaveVideoInfo(info); err != nil {
+		t.Fatalf("Failed to save video: %v", err)
+	}
+
+	first, err := scheduler.Sync(context.Background(), sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if first.Found != 1 || first.New != 1 || first.BatchID == "" {
+		t.Fatalf("Expected one new post and a batch job, got %+v", first)
+	}
+
+	job, err := bm.GetJobStatus(first.BatchID)
+	if err != nil {
+		t.Fatalf("Failed to get batch job: %v", err)
+	}
+	if info := waitForJob(t, job); info.Status != batch.JobStatusCompleted {
+		t.Fatalf("Expected status %s, got %s", batch.JobStatusCompleted, info.Status)
+	}
+
+	stored, err := e.storage.GetVideoInfo(video.ID)
+	if err != nil {
+		t.Fatalf("Video %s not stored: %v", video.ID, err)
+	}
+	assertFile(t, stored.FilePath, video.Media)
+
+	saved, err := e.storage.GetSubscription(sub.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	// The post was only queued when the sync ran, so it is not seen yet
+	if saved.LastSeenAt != nil {
+		t.Errorf("Expected nothing seen before the download, got %v", saved.LastSeenAt)
+	}
+	if saved.LastBatchID != first.BatchID || saved.LastError != "" || !saved.NextSyncAt.After(time.Now()) {
+		t.Errorf("Unexpected subscription after sync %+v", saved)
+	}
+
+	// The post is downloaded now, so the next sync finds nothing new
+	second, err := scheduler.Sync(context.Background(), sub.ID)
+	if err != nil {
+		t.Fatalf("Failed to sync again: %v", err)
+	}
+	if second.Found != 1 || second.New != 0 || second.BatchID != "" {
+		t.Errorf("Expected no new posts on the second sync, got %+v", second)
+	}
+	if second.LastSeenAt == nil || !second.LastSeenAt.Equal(stored.PublishedAt) {
+		t.Errorf("Expected last seen at %v, got %v", stored.PublishedAt, second.LastSeenAt)
+	}
+	// A new interval reschedules the next sync and keeps what the syncs
+	// recorded
+	updated, err := scheduler.Update(sub.ID, func(sub *models.Subscription) { sub.Interval = 5 })
+	if err != nil {
+		t.Fatalf("Failed to update subscription: %v", err)
+	}
+	if updated.LastSyncAt == nil || !updated.NextSyncAt.Equal(updated.LastSyncAt.Add(5*time.Minute)) {
+		t.Errorf("Expected next sync 5 minutes after %v, got %v", updated.LastSyncAt, updated.NextSyncAt)
+	}
+	if updated.LastSeenAt == nil || !updated.LastSeenAt.Equal(stored.PublishedAt) {
+		t.Errorf("Expected last seen at %v to be kept, got %v", stored.PublishedAt, updated.LastSeenAt)
+	}
+
+	// A sync interrupted by shutdown is not recorded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := scheduler.Sync(ctx, sub.ID); err == nil {
+		t.Fatal("Expected the cancelled sync to fail")
+	}
+	interrupted, err := e.storage.GetSubscription(sub.ID)
+	if err != nil || interrupted == nil {
+		t.Fatalf("Failed to get subscription: %v", err)
+	}
+	if interrupted.LastError != "" || !interrupted.NextSyncAt.Equal(updated.NextSyncAt) || !interrupted.LastSyncAt.Equal(*updated.LastSyncAt) {
+		t.Errorf("Expected the subscription to be left as it was, got %+v", interrupted)
+	}
+}
+
+func TestSubscriptionAddConcurrent(t *testing.T) {
+	e := newEnv(t)
+
+	reg := registry.NewRegistry()
+	if err := reg.RegisterDefaultPlatforms(e.cfg, e.platforms.Factory()); err != nil {
+		t.Fatalf("Failed to register platforms: %v", err)
+	}
+	scheduler := subscription.NewScheduler(reg, nil, e.storage)
+
+	const n = 4
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			errs <- scheduler.Add(&models.Subscription{Platform: models.PlatformTikTok, AuthorID: "harbourviews"})
+		}()
+	}
+
+	added := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			added++
+		case !errors.Is(err, subscription.ErrSubscribed):
+			t.Errorf("Expected %v, got %v", subscription.ErrSubscribed, err)
+		}
+	}
+	if added != 1 {
+		t.Errorf("Expected exactly one subscription to be added, got %d", added)
+	}
+}
+
+// profileExtractor lists a fixed set of posts as the profile of any author
+type profileExtractor struct {
+	models.PlatformExtractor
+	urls []string
+	// unavailable are the profiles that fail to load
+	unavailable map[string]bool
+}
+
+func (p *profileExtractor) ExtractBatch(ctx context.Context, url string, limit int) ([]*models.VideoInfo, error) {
+	if p.unavailable[url] {
+		return nil, fmt.Errorf("profile %s unavailable", url)
+	}
+
+	var videos []*models.VideoInfo
+	for _, u := range p.urls[:min(limit, len(p.urls))] {
+		video, err := p.ExtractVideoInfo(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, video)
+	}
+	return videos, nil
+}
+
+// download queues url and waits for the result, failing the test if the
+// download does not succeed
+func download(t *testing.T, m *downloader.Manager, url string, options *downloader.DownloadOptions) *downloader.DownloadResult {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	taskID, err := m.Download(ctx, url, options)
+	if err != nil {
+		t.Fatalf("Failed to queue %s: %v", url, err)
+	}
+
+	result, err := m.Wait(ctx, taskID)
+	if err != nil {
+		t.Fatalf("Failed to wait for %s: %v", url, err)
+	}
+	if result.Error != nil {
+		t.Fatalf("Download of %s failed: %v", url, result.Error)
+	}
+
+	return result
+}
+
+func assertFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: expected %d bytes of media, got %d different bytes", path, len(want), len(got))
+	}
+}
+
+// waitForJob waits for a batch job to finish and returns its final info
+func waitForJob(t *testing.T, job *batch.BatchJob) *batch.BatchJobInfo {
+	t.Helper()
+
+	var info *batch.BatchJobInfo
+	waitFor(t, "batch job "+job.ID, func() bool {
+		info = job.Info(true)
+		return info.Status != batch.JobStatusPending && info.Status != batch.JobStatusRunning
+	})
+	return info
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
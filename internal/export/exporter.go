@@ -478,6 +478,179 @@ func (de *DataExporter) exportAuthorsToJSON(authors []*models.AuthorInfo) error
 	return os.WriteFile(de.config.FilePath, data, 0644)
 }
 
+// ExportCommentReport exports a comment report. CSV and XLSX get one row
+// per figure, grouped by section; JSON and TXT keep the report's layout.
+func (de *DataExporter) ExportCommentReport(report *models.CommentReport) error {
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(de.config.FilePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	switch de.config.Format {
+	case FormatCSV:
+		return de.exportReportToCSV(report)
+	case FormatXLSX:
+		return de.exportReportToXLSX(report)
+	case FormatJSON:
+		return de.exportReportToJSON(report)
+	case FormatTXT:
+		return de.exportReportToTXT(report)
+	default:
+		return fmt.Errorf("unsupported export format for comment reports: %s", de.config.Format)
+	}
+}
+
+// reportRows flattens a comment report into section, term and value rows
+func (de *DataExporter) reportRows(report *models.CommentReport) [][]string {
+	rows := [][]string{
+		{"summary", "total_comments", fmt.Sprintf("%d", report.TotalComments)},
+		{"summary", "total_replies", fmt.Sprintf("%d", report.TotalReplies)},
+		{"summary", "total_likes", fmt.Sprintf("%d", report.TotalLikes)},
+		{"summary", "unique_authors", fmt.Sprintf("%d", report.UniqueAuthors)},
+		{"summary", "avg_likes", fmt.Sprintf("%.2f", report.AvgLikes)},
+		{"sentiment", "score", fmt.Sprintf("%.3f", report.Sentiment.Score)},
+		{"sentiment", "positive", fmt.Sprintf("%d", report.Sentiment.Positive)},
+		{"sentiment", "negative", fmt.Sprintf("%d", report.Sentiment.Negative)},
+		{"sentiment", "neutral", fmt.Sprintf("%d", report.Sentiment.Neutral)},
+	}
+
+	sections := []struct {
+		name  string
+		terms []models.TermCount
+	}{
+		{"keyword", report.Keywords},
+		{"mention", report.Mentions},
+		{"emoji", report.Emoji},
+		{"ip_location", report.IPLocations},
+	}
+	for _, section := range sections {
+		for _, term := range section.terms {
+			rows = append(rows, []string{section.name, term.Term, fmt.Sprintf("%d", term.Count)})
+		}
+	}
+
+	for _, bucket := range report.Volume {
+		rows = append(rows, []string{"volume_" + report.VolumeInterval, bucket.Start.Format(de.config.DateFormat), fmt.Sprintf("%d", bucket.Count)})
+	}
+
+	return rows
+}
+
+// exportReportToCSV exports a comment report to CSV
+func (de *DataExporter) exportReportToCSV(report *models.CommentReport) error {
+	file, err := os.Create(de.config.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Comma = de.config.Delimiter
+	defer writer.Flush()
+
+	// Write header
+	if err := writer.Write([]string{"Section", "Term", "Value"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	for _, row := range de.reportRows(report) {
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// exportReportToXLSX exports a comment report to Excel
+func (de *DataExporter) exportReportToXLSX(report *models.CommentReport) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheetName := "Comment Report"
+	f.SetSheetName("Sheet1", sheetName)
+
+	// Write headers
+	for i, header := range []string{"Section", "Term", "Value"} {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, header)
+	}
+	f.SetColWidth(sheetName, "A", "A", 15)
+	f.SetColWidth(sheetName, "B", "B", 30)
+
+	// Write data
+	for i, row := range de.reportRows(report) {
+		for j, value := range row {
+			cell := fmt.Sprintf("%c%d", 'A'+j, i+2)
+			f.SetCellValue(sheetName, cell, value)
+		}
+	}
+
+	return f.SaveAs(de.config.FilePath)
+}
+
+// exportReportToJSON exports a comment report to JSON
+func (de *DataExporter) exportReportToJSON(report *models.CommentReport) error {
+	exportData := struct {
+		ExportedAt time.Time             `json:"exported_at"`
+		Report     *models.CommentReport `json:"report"`
+	}{
+		ExportedAt: time.Now(),
+		Report:     report,
+	}
+
+	data, err := json.MarshalIndent(exportData, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return os.WriteFile(de.config.FilePath, data, 0644)
+}
+
+// exportReportToTXT exports a comment report to plain text
+func (de *DataExporter) exportReportToTXT(report *models.CommentReport) error {
+	file, err := os.Create(de.config.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create TXT file: %w", err)
+	}
+	defer file.Close()
+
+	// Write header
+	fmt.Fprintf(file, "Comment Report\n")
+	fmt.Fprintf(file, "Video: %s (%s)\n", report.VideoID, report.Platform)
+	fmt.Fprintf(file, "Generated: %s\n", report.GeneratedAt.Format(de.config.DateFormat))
+	fmt.Fprintf(file, "%s\n\n", strings.Repeat("=", 50))
+
+	fmt.Fprintf(file, "Comments: %d | Replies: %d | Likes: %d\n", report.TotalComments, report.TotalReplies, report.TotalLikes)
+	fmt.Fprintf(file, "Unique Authors: %d | Average Likes: %.2f\n", report.UniqueAuthors, report.AvgLikes)
+	fmt.Fprintf(file, "Sentiment: %.3f (%d positive, %d negative, %d neutral)\n",
+		report.Sentiment.Score, report.Sentiment.Positive, report.Sentiment.Negative, report.Sentiment.Neutral)
+
+	sections := []struct {
+		title string
+		terms []models.TermCount
+	}{
+		{"Top Keywords", report.Keywords},
+		{"Most Mentioned", report.Mentions},
+		{"Emoji", report.Emoji},
+		{"IP Locations", report.IPLocations},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(file, "\n%s:\n", section.title)
+		for _, term := range section.terms {
+			fmt.Fprintf(file, "  %s: %d\n", term.Term, term.Count)
+		}
+	}
+
+	fmt.Fprintf(file, "\nComments per %s:\n", report.VolumeInterval)
+	for _, bucket := range report.Volume {
+		fmt.Fprintf(file, "  %s: %d\n", bucket.Start.Format(de.config.DateFormat), bucket.Count)
+	}
+
+	return nil
+}
+
 // ExportTemplate creates a template file for bulk import
 func (de *DataExporter) ExportTemplate() error {
 	switch de.config.Format {
@@ -1,6 +1,7 @@
 package kuaishou
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,14 +28,25 @@ type kuaishouExtractor struct {
 
 // KSVideo represents Kuaishou video data
 type KSVideo struct {
-	PhotoID    string      `json:"photoId"`
-	Caption    string      `json:"caption"`
-	Duration   int         `json:"duration"`
-	CreateTime int64       `json:"timestamp"`
-	User       KSUser      `json:"user"`
-	Photo      KSPhoto     `json:"photo"`
-	SoundTrack KSSound     `json:"soundTrack"`
-	ExtParams  KSExtParams `json:"ext_params"`
+	PhotoID    string        `json:"photoId"`
+	Caption    string        `json:"caption"`
+	Duration   int           `json:"duration"`
+	CreateTime int64         `json:"timestamp"`
+	User       KSUser        `json:"user"`
+	Photo      KSPhoto       `json:"photo"`
+	SoundTrack KSSound       `json:"soundTrack"`
+	ExtParams  KSExtParams   `json:"ext_params"`
+	Renditions []KSRendition `json:"renditions,omitempty"`
+}
+
+// KSRendition is one encoding from the video manifest
+type KSRendition struct {
+	URL          string `json:"url"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Bandwidth    int    `json:"bandwidth"`
+	QualityType  int    `json:"qualityType"`
+	QualityLabel string `json:"qualityLabel"`
 }
 
 type KSUser struct {
@@ -107,6 +119,7 @@ func NewExtractor(config *models.ExtractorConfig) *kuaishouExtractor {
 			UserAgent:   userAgent,
 			Cookie:      config.Cookie,
 			TLSInsecure: true,
+			Transport:   config.Transport,
 		}),
 		config:    config,
 		logger:    zerolog.New(os.Stdout).With().Timestamp().Logger(),
@@ -116,15 +129,15 @@ func NewExtractor(config *models.ExtractorConfig) *kuaishouExtractor {
 }
 
 // ExtractVideoInfo extracts video information from a Kuaishou URL
-func (e *kuaishouExtractor) ExtractVideoInfo(ksURL string) (*models.VideoInfo, error) {
+func (e *kuaishouExtractor) ExtractVideoInfo(ctx context.Context, ksURL string) (*models.VideoInfo, error) {
 	// Extract video ID from URL
-	videoID, err := e.extractVideoID(ksURL)
+	videoID, err := e.extractVideoID(ctx, ksURL)
 	if err != nil {
 		return nil, fmt.Errorf("error extracting video ID: %w", err)
 	}
 
 	// Get video data
-	videoData, err := e.getVideoData(videoID)
+	videoData, err := e.getVideoData(ctx, videoID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting video data: %w", err)
 	}
@@ -136,9 +149,9 @@ func (e *kuaishouExtractor) ExtractVideoInfo(ksURL string) (*models.VideoInfo, e
 }
 
 // ExtractAuthorInfo extracts author information
-func (e *kuaishouExtractor) ExtractAuthorInfo(authorID string) (*models.AuthorInfo, error) {
+func (e *kuaishouExtractor) ExtractAuthorInfo(ctx context.Context, authorID string) (*models.AuthorInfo, error) {
 	// Get user data
-	userData, err := e.getUserData(authorID)
+	userData, err := e.getUserData(ctx, authorID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user data: %w", err)
 	}
@@ -150,7 +163,7 @@ func (e *kuaishouExtractor) ExtractAuthorInfo(authorID string) (*models.AuthorIn
 }
 
 // ExtractBatch extracts multiple videos from a Kuaishou user page
-func (e *kuaishouExtractor) ExtractBatch(url string, limit int) ([]*models.VideoInfo, error) {
+func (e *kuaishouExtractor) ExtractBatch(ctx context.Context, url string, limit int) ([]*models.VideoInfo, error) {
 	// Extract user ID from URL
 	userID, err := e.extractUserID(url)
 	if err != nil {
@@ -158,7 +171,7 @@ func (e *kuaishouExtractor) ExtractBatch(url string, limit int) ([]*models.Video
 	}
 
 	// Get user videos
-	videos, err := e.getUserVideos(userID, limit)
+	videos, err := e.getUserVideos(ctx, userID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user videos: %w", err)
 	}
@@ -200,11 +213,11 @@ func (e *kuaishouExtractor) GetSupportedURLPatterns() []string {
 }
 
 // extractVideoID extracts video ID from Kuaishou URL
-func (e *kuaishouExtractor) extractVideoID(url string) (string, error) {
+func (e *kuaishouExtractor) extractVideoID(ctx context.Context, url string) (string, error) {
 	// Handle different URL formats
 	if strings.Contains(url, "v.kuaishou.com") {
 		// Short URL - need to resolve
-		return e.resolveShortURL(url)
+		return e.resolveShortURL(ctx, url)
 	}
 
 	// Extract from regular URLs
@@ -227,8 +240,8 @@ func (e *kuaishouExtractor) extractUserID(url string) (string, error) {
 }
 
 // resolveShortURL resolves Kuaishou short URL
-func (e *kuaishouExtractor) resolveShortURL(shortURL string) (string, error) {
-	resp, err := e.client.Get(shortURL, map[string]string{
+func (e *kuaishouExtractor) resolveShortURL(ctx context.Context, shortURL string) (string, error) {
+	resp, err := e.client.Get(ctx, shortURL, map[string]string{
 		"User-Agent": e.userAgent,
 	})
 	if err != nil {
@@ -242,14 +255,14 @@ func (e *kuaishouExtractor) resolveShortURL(shortURL string) (string, error) {
 
 	// Get final URL after redirects
 	finalURL := resp.Request.URL.String()
-	return e.extractVideoID(finalURL)
+	return e.extractVideoID(ctx, finalURL)
 }
 
 // getVideoData fetches video data from Kuaishou using GraphQL API
-func (e *kuaishouExtractor) getVideoData(videoID string) (*KSVideo, error) {
+func (e *kuaishouExtractor) getVideoData(ctx context.Context, videoID string) (*KSVideo, error) {
 	// First try the GraphQL API approach (like KS-Downloader)
 	e.logger.Info().Str("video_id", videoID).Msg("Attempting to extract video using GraphQL API")
-	if video, err := e.getVideoFromAPI(videoID); err == nil && video != nil {
+	if video, err := e.getVideoFromAPI(ctx, videoID); err == nil && video != nil {
 		e.logger.Info().Str("video_id", videoID).Msg("Successfully extracted video using GraphQL API")
 		return video, nil
 	} else {
@@ -271,7 +284,7 @@ func (e *kuaishouExtractor) getVideoData(videoID string) (*KSVideo, error) {
 		headers["Cookie"] = e.cookie
 	}
 
-	resp, err := e.client.Get(videoURL, headers)
+	resp, err := e.client.Get(ctx, videoURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching video page: %w", err)
 	}
@@ -286,7 +299,7 @@ func (e *kuaishouExtractor) getVideoData(videoID string) (*KSVideo, error) {
 }
 
 // getUserData fetches user data from Kuaishou
-func (e *kuaishouExtractor) getUserData(userID string) (*KSUser, error) {
+func (e *kuaishouExtractor) getUserData(ctx context.Context, userID string) (*KSUser, error) {
 	userURL := fmt.Sprintf("https://www.kuaishou.com/profile/%s", userID)
 
 	headers := map[string]string{
@@ -299,7 +312,7 @@ func (e *kuaishouExtractor) getUserData(userID string) (*KSUser, error) {
 		headers["Cookie"] = e.cookie
 	}
 
-	resp, err := e.client.Get(userURL, headers)
+	resp, err := e.client.Get(ctx, userURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching user page: %w", err)
 	}
@@ -314,7 +327,7 @@ func (e *kuaishouExtractor) getUserData(userID string) (*KSUser, error) {
 }
 
 // getUserVideos fetches user videos from Kuaishou
-func (e *kuaishouExtractor) getUserVideos(userID string, limit int) ([]KSVideo, error) {
+func (e *kuaishouExtractor) getUserVideos(ctx context.Context, userID string, limit int) ([]KSVideo, error) {
 	// This would typically require pagination and handling of Kuaishou's dynamic loading
 	// For now, return empty slice
 	return []KSVideo{}, nil
@@ -521,11 +534,54 @@ func (e *kuaishouExtractor) parseDirectData(data map[string]interface{}) *KSVide
 	return nil
 }
 
-// extractUserFromHTML extracts user data from HTML
+// extractUserFromHTML extracts user data from the Apollo state of a
+// profile page
 func (e *kuaishouExtractor) extractUserFromHTML(body io.Reader) (*KSUser, error) {
-	// Similar to extractVideoFromHTML but for user data
-	// This is a simplified implementation
-	return &KSUser{}, nil
+	htmlContent, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HTML content: %w", err)
+	}
+
+	re := regexp.MustCompile(`window\.__APOLLO_STATE__\s*=\s*(\{.*?\});`)
+	matches := re.FindSubmatch(htmlContent)
+	if len(matches) < 2 {
+		return nil, fmt.Errorf("user data not found in HTML")
+	}
+
+	var apolloState map[string]interface{}
+	if err := json.Unmarshal(matches[1], &apolloState); err != nil {
+		return nil, fmt.Errorf("error parsing Apollo state: %w", err)
+	}
+
+	if user := e.parseApolloUser(apolloState); user != nil {
+		return user, nil
+	}
+
+	return nil, fmt.Errorf("user data not found in HTML")
+}
+
+// parseApolloUser searches Apollo state for a user object
+func (e *kuaishouExtractor) parseApolloUser(data map[string]interface{}) *KSUser {
+	for key, value := range data {
+		userData, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if strings.HasPrefix(key, "VisionProfileUser") {
+			if _, hasName := userData["name"]; hasName {
+				user := e.parseUserData(userData)
+				return &user
+			}
+		}
+
+		// Recursively search nested objects
+		if user := e.parseApolloUser(userData); user != nil {
+			return user
+		}
+	}
+
+	return nil
 }
 
 // parseApolloData parses Apollo state data to extract video information
@@ -758,6 +814,7 @@ func (e *kuaishouExtractor) parseAtlasData(data map[string]interface{}) KSAtlas
 func (e *kuaishouExtractor) convertToVideoInfo(video *KSVideo) *models.VideoInfo {
 	var mediaType models.MediaType
 	var downloadURL string
+	var items []models.MediaItem
 
 	// Check if we have a real video URL from API parsing
 	if video.ExtParams.Atlas.CDN != "" && strings.HasPrefix(video.ExtParams.Atlas.CDN, "http") {
@@ -781,14 +838,8 @@ func (e *kuaishouExtractor) convertToVideoInfo(video *KSVideo) *models.VideoInfo
 		// If no video URLs found in list, treat as images
 		if downloadURL == "" && len(video.ExtParams.Atlas.List) > 0 {
 			mediaType = models.MediaTypeImage
-			for _, url := range video.ExtParams.Atlas.List {
-				if strings.HasPrefix(url, "http") {
-					downloadURL = url
-				} else {
-					downloadURL = "https://" + url
-				}
-				break
-			}
+			items = atlasItems(video.ExtParams.Atlas)
+			downloadURL = items[0].URL
 		}
 	} else if video.Photo.PhotoType == "VIDEO" {
 		mediaType = models.MediaTypeVideo
@@ -808,6 +859,15 @@ func (e *kuaishouExtractor) convertToVideoInfo(video *KSVideo) *models.VideoInfo
 		e.logger.Warn().Msg("Unknown media type - download will fail")
 	}
 
+	// Keep the platform payload for sidecar files
+	raw, _ := json.Marshal(video)
+
+	music := convertSound(video)
+	musicID := ""
+	if music != nil {
+		musicID = music.ID
+	}
+
 	return &models.VideoInfo{
 		ID:          video.PhotoID,
 		Platform:    models.PlatformKuaishou,
@@ -818,6 +878,8 @@ func (e *kuaishouExtractor) convertToVideoInfo(video *KSVideo) *models.VideoInfo
 		Thumbnail:   video.Photo.CoverURL,
 		Duration:    video.Duration,
 		MediaType:   mediaType,
+		MediaItems:  items,
+		Renditions:  renditions(video, mediaType),
 		Size:        0, // Will be filled during download
 		Format:      "mp4",
 		Quality:     "hd",
@@ -841,13 +903,107 @@ func (e *kuaishouExtractor) convertToVideoInfo(video *KSVideo) *models.VideoInfo
 		Status:     "pending",
 		RetryCount: 0,
 
+		// Soundtrack
+		MusicID: musicID,
+		Music:   music,
+
 		// Additional metadata
 		Metadata: fmt.Sprintf(`{"sound_id":"%s","sound_name":"%s","sound_author":"%s","extract_method":"html_fallback","has_real_url":%t}`,
 			video.SoundTrack.ID, video.SoundTrack.Name, video.SoundTrack.Author, downloadURL != ""),
 		ExtractFrom: "web",
+		RawData:     raw,
+	}
+}
+
+// convertSound returns the video's soundtrack as an audio record, or nil
+// when the sound has no audio URL
+func convertSound(video *KSVideo) *models.VideoInfo {
+	sound := video.SoundTrack
+	var audioURL string
+	for _, u := range sound.AudioUrls {
+		if strings.HasPrefix(u.URL, "http") {
+			audioURL = u.URL
+			break
+		}
+	}
+	if audioURL == "" || sound.ID == "" {
+		return nil
+	}
+
+	format := "mp3"
+	if strings.Contains(audioURL, ".m4a") {
+		format = "m4a"
+	}
+
+	return &models.VideoInfo{
+		ID:          models.MusicRecordID(sound.ID),
+		Platform:    models.PlatformKuaishou,
+		Title:       sound.Name,
+		URL:         fmt.Sprintf("https://www.kuaishou.com/short-video/%s", video.PhotoID),
+		DownloadURL: audioURL,
+		Thumbnail:   video.Photo.CoverURL,
+		Duration:    sound.Duration,
+		MediaType:   models.MediaTypeAudio,
+		Format:      format,
+		AuthorName:  sound.Author,
+		PublishedAt: time.Unix(video.CreateTime/1000, 0),
+		CollectedAt: time.Now(),
+		Status:      "pending",
+		ExtractFrom: "web",
 	}
 }
 
+// renditions lists the encodings found in the video manifest. Videos
+// found another way keep their single URL.
+func renditions(video *KSVideo, mediaType models.MediaType) []models.Rendition {
+	if mediaType != models.MediaTypeVideo {
+		return nil
+	}
+
+	list := make([]models.Rendition, 0, len(video.Renditions))
+	for _, r := range video.Renditions {
+		label := r.QualityLabel
+		if label == "" && r.QualityType != 0 {
+			label = fmt.Sprintf("type %d", r.QualityType)
+		}
+		list = append(list, models.Rendition{
+			URL:     r.URL,
+			Width:   r.Width,
+			Height:  r.Height,
+			Bitrate: r.Bandwidth,
+			Format:  "mp4",
+			Label:   label,
+		})
+	}
+
+	return list
+}
+
+// atlasItems returns the images of an atlas in order. List entries are
+// paths on the atlas CDN host, or occasionally full URLs.
+func atlasItems(atlas KSAtlas) []models.MediaItem {
+	items := make([]models.MediaItem, 0, len(atlas.List))
+	for i, path := range atlas.List {
+		var imageURL string
+		switch {
+		case strings.HasPrefix(path, "http"):
+			imageURL = path
+		case atlas.CDN != "" && !strings.HasPrefix(atlas.CDN, "http"):
+			imageURL = "https://" + strings.TrimSuffix(atlas.CDN, "/") + "/" + strings.TrimPrefix(path, "/")
+		default:
+			imageURL = "https://" + strings.TrimPrefix(path, "/")
+		}
+
+		items = append(items, models.MediaItem{
+			Index:     i,
+			MediaType: models.MediaTypeImage,
+			URL:       imageURL,
+		})
+	}
+
+	return items
+}
+
 // convertToAuthorInfo converts KSUser to AuthorInfo
 func (e *kuaishouExtractor) convertToAuthorInfo(user *KSUser) *models.AuthorInfo {
 	return &models.AuthorInfo{
@@ -865,7 +1021,7 @@ func (e *kuaishouExtractor) convertToAuthorInfo(user *KSUser) *models.AuthorInfo
 }
 
 // getVideoFromAPI fetches video data using Kuaishou's internal GraphQL API
-func (e *kuaishouExtractor) getVideoFromAPI(videoID string) (*KSVideo, error) {
+func (e *kuaishouExtractor) getVideoFromAPI(ctx context.Context, videoID string) (*KSVideo, error) {
 	// Kuaishou GraphQL endpoint
 	apiURL := "https://www.kuaishou.com/graphql"
 
@@ -895,6 +1051,11 @@ func (e *kuaishouExtractor) getVideoFromAPI(videoID string) (*KSVideo, error) {
 						representation {
 							id
 							url
+							bandwidth
+							qualityType
+							qualityLabel
+							width
+							height
 						}
 					}
 				}
@@ -928,7 +1089,7 @@ func (e *kuaishouExtractor) getVideoFromAPI(videoID string) (*KSVideo, error) {
 	}
 
 	// Make API request
-	resp, err := e.client.PostJSON(apiURL, requestData, headers)
+	resp, err := e.client.PostJSON(ctx, apiURL, requestData, headers)
 	if err != nil {
 		e.logger.Warn().Err(err).Msg("GraphQL API request failed")
 		return nil, err
@@ -975,6 +1136,8 @@ func (e *kuaishouExtractor) getVideoFromAPI(videoID string) (*KSVideo, error) {
 								Bandwidth int   `json:"bandwidth"`
 								QualityType int `json:"qualityType"`
 								QualityLabel string `json:"qualityLabel"`
+								Width        int    `json:"width"`
+								Height       int    `json:"height"`
 							} `json:"representation"`
 						} `json:"adaptationSet"`
 					} `json:"manifest"`
@@ -1071,6 +1234,14 @@ func (e *kuaishouExtractor) getVideoFromAPI(videoID string) (*KSVideo, error) {
 		for j, representation := range adaptationSet.Representation {
 			if representation.URL != "" {
 				videoURLs = append(videoURLs, representation.URL)
+				video.Renditions = append(video.Renditions, KSRendition{
+					URL:          representation.URL,
+					Width:        representation.Width,
+					Height:       representation.Height,
+					Bandwidth:    representation.Bandwidth,
+					QualityType:  representation.QualityType,
+					QualityLabel: representation.QualityLabel,
+				})
 				e.logger.Info().Str("video_url", representation.URL).Int("quality", representation.QualityType).Str("quality_label", representation.QualityLabel).Int("set", i).Int("rep", j).Msg("Found video URL from manifest")
 			}
 		}
@@ -1082,6 +1253,7 @@ func (e *kuaishouExtractor) getVideoFromAPI(videoID string) (*KSVideo, error) {
 		for _, mvUrl := range apiResp.Data.VisionVideoDetail.Photo.MainMvUrls {
 			if mvUrl.URL != "" {
 				videoURLs = append(videoURLs, mvUrl.URL)
+				video.Renditions = append(video.Renditions, KSRendition{URL: mvUrl.URL, QualityType: mvUrl.QualityType})
 				e.logger.Info().Str("video_url", mvUrl.URL).Int("quality", mvUrl.QualityType).Msg("Found video URL from mainMvUrls")
 			}
 		}
@@ -0,0 +1,51 @@
+package kuaishou
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"video-downloader/internal/platform/platformtest"
+	"video-downloader/pkg/models"
+)
+
+func newTestExtractor(t *testing.T, fixture string) *kuaishouExtractor {
+	return NewExtractor(&models.ExtractorConfig{
+		Transport: platformtest.NewTransport(t, filepath.Join("testdata", fixture)),
+	})
+}
+
+func TestExtractVideoInfo(t *testing.T) {
+	tests := []struct {
+		fixture string
+		url     string
+		golden  string
+	}{
+		{"graphql", "https://www.kuaishou.com/short-video/3x5m8k2n4p6r8t1", "graphql"},
+		// The page's Apollo state is used when the API asks for a captcha
+		{"html_fallback", "https://www.kuaishou.com/short-video/3x5m8k2n4p6r8t1", "html_fallback"},
+		{"short_link", "https://v.kuaishou.com/Kx8aBc", "graphql"},
+		// Atlas images are paths on the CDN host or full URLs
+		{"atlas", "https://www.kuaishou.com/short-video/3xa7tl2mq9wz4kc", "atlas"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			info, err := newTestExtractor(t, tt.fixture).ExtractVideoInfo(context.Background(), tt.url)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			platformtest.AssertGolden(t, filepath.Join("testdata", tt.golden, "want.json"), platformtest.StableVideo(info))
+		})
+	}
+}
+
+func TestExtractAuthorInfo(t *testing.T) {
+	info, err := newTestExtractor(t, "author").ExtractAuthorInfo(context.Background(), "3xq7wz8ab2cd4ef")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	platformtest.AssertGolden(t, filepath.Join("testdata", "author", "want.json"), platformtest.StableAuthor(info))
+}
@@ -0,0 +1,65 @@
+package platformtest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"video-downloader/pkg/models"
+)
+
+// AssertGolden compares got, encoded as indented JSON, with the golden
+// file at path. With -update the file is rewritten instead.
+func AssertGolden(t testing.TB, path string, got any) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("Error encoding result: %v", err)
+	}
+	data = append(data, '\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading golden file (run with -update to create it): %v", err)
+	}
+	if string(want) != string(data) {
+		t.Errorf("Result does not match %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s", path, data, want)
+	}
+}
+
+// StableVideo clears the fields of an extracted video that change from
+// run to run, so it can be compared with a golden file: collection times
+// are zeroed and publish times moved to UTC
+func StableVideo(v *models.VideoInfo) *models.VideoInfo {
+	if v == nil {
+		return nil
+	}
+	v.CollectedAt = time.Time{}
+	v.PublishedAt = v.PublishedAt.UTC()
+	StableVideo(v.Music)
+	return v
+}
+
+// StableAuthor clears the fields of extracted author info that change
+// from run to run
+func StableAuthor(a *models.AuthorInfo) *models.AuthorInfo {
+	if a == nil {
+		return nil
+	}
+	a.CollectedAt = time.Time{}
+	a.UpdatedAt = time.Time{}
+	return a
+}
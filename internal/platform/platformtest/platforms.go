@@ -0,0 +1,477 @@
+package platformtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"video-downloader/internal/utils"
+)
+
+// Video is a post served by Platforms
+type Video struct {
+	ID string
+	// URL is the share URL of the post
+	URL string
+	// MediaURL is the file a best-quality download fetches and Media its
+	// content. For HLS streams they are the master playlist and the joined
+	// segments.
+	MediaURL string
+	Media    []byte
+	// MusicURL and Music are the soundtrack, if the post has one
+	MusicURL string
+	Music    []byte
+	// Comments and Replies count the top-level comments and the replies
+	// served for the post. They span several pages.
+	Comments int
+	Replies  int
+}
+
+// Request is a request received by Platforms
+type Request struct {
+	Method string
+	// URL is the URL the client asked for, before it was rerouted
+	URL   string
+	Range string
+}
+
+// Platforms is a local stand-in for TikTok, Xiaohongshu and Kuaishou. It
+// serves one post per platform through the same APIs and pages the
+// extractors use: the TikTok feed API, a Xiaohongshu note page, Kuaishou's
+// GraphQL endpoint and each platform's comment and reply APIs, which are
+// paginated. Media files support Range requests, and the Xiaohongshu note
+// is an HLS stream.
+//
+// Components reach it through Factory, whose transport sends requests for
+// any host to the local server.
+type Platforms struct {
+	TikTok   Video
+	XHS      Video
+	Kuaishou Video
+
+	server *httptest.Server
+
+	mu         sync.Mutex
+	routes     map[string]http.HandlerFunc
+	requests   []Request
+	interrupts map[string]*interrupt
+}
+
+// interrupt cuts one response to a media file short
+type interrupt struct {
+	after int64
+	sent  chan struct{}
+}
+
+// NewPlatforms starts the fake platforms; they are shut down when the test
+// finishes
+func NewPlatforms(t testing.TB) *Platforms {
+	t.Helper()
+
+	p := &Platforms{
+		routes:     make(map[string]http.HandlerFunc),
+		interrupts: make(map[string]*interrupt),
+	}
+
+	p.setupTikTok()
+	p.setupXHS()
+	p.setupKuaishou()
+
+	p.server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// Factory returns a transport factory that routes every request to the
+// fake platforms
+func (p *Platforms) Factory() *utils.TransportFactory {
+	target, _ := url.Parse(p.server.URL)
+	next := p.server.Client().Transport
+
+	return utils.TransportFactoryFor(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		routed := req.Clone(req.Context())
+		routed.URL.Scheme = target.Scheme
+		routed.URL.Host = target.Host
+		routed.Host = req.URL.Host
+		routed.Header.Set("X-Original-Scheme", req.URL.Scheme)
+
+		resp, err := next.RoundTrip(routed)
+		if err != nil {
+			return nil, err
+		}
+		// Relative URLs, such as HLS variants, resolve against the original
+		resp.Request = req
+		return resp, nil
+	}))
+}
+
+// Requests returns the requests received so far
+func (p *Platforms) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Request(nil), p.requests...)
+}
+
+// Interrupt makes the next GET of the media file at rawURL stop after the
+// given number of bytes. The connection is then held open until the client
+// gives up. The returned channel is closed once the bytes are sent.
+func (p *Platforms) Interrupt(rawURL string, after int64) <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := &interrupt{after: after, sent: make(chan struct{})}
+	p.interrupts[routeKey(rawURL)] = i
+	return i.sent
+}
+
+// serve records the request and dispatches it by original host and path
+func (p *Platforms) serve(w http.ResponseWriter, r *http.Request) {
+	scheme := r.Header.Get("X-Original-Scheme")
+	if scheme == "" {
+		scheme = "http"
+	}
+	original := scheme + "://" + r.Host + r.URL.RequestURI()
+
+	p.mu.Lock()
+	p.requests = append(p.requests, Request{
+		Method: r.Method,
+		URL:    original,
+		Range:  r.Header.Get("Range"),
+	})
+	handler, ok := p.routes[r.Host+r.URL.Path]
+	p.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	handler(w, r)
+}
+
+// handle registers handler for the host and path of rawURL
+func (p *Platforms) handle(rawURL string, handler http.HandlerFunc) {
+	p.routes[routeKey(rawURL)] = handler
+}
+
+// handleMedia serves data at rawURL with Range support
+func (p *Platforms) handleMedia(rawURL, contentType string, data []byte) {
+	key := routeKey(rawURL)
+	p.handle(rawURL, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+
+		p.mu.Lock()
+		i := p.interrupts[key]
+		if i != nil && r.Method == http.MethodGet {
+			delete(p.interrupts, key)
+		} else {
+			i = nil
+		}
+		p.mu.Unlock()
+
+		if i != nil {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(data[:i.after])
+			w.(http.Flusher).Flush()
+			close(i.sent)
+			<-r.Context().Done()
+			return
+		}
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	})
+}
+
+func (p *Platforms) setupTikTok() {
+	const id = "7301234567890123456"
+	v := Video{
+		ID:       id,
+		URL:      "https://www.tiktok.com/@harbourviews/video/" + id,
+		MediaURL: "https://v16-webapp.tiktok.com/video/tos/play/" + id + ".mp4",
+		Media:    mediaData("tiktok-play", 192*1024),
+		MusicURL: "https://sf16-ies-music.tiktokcdn.com/obj/ies-music/7301234567890000001.mp3",
+		Music:    mediaData("tiktok-music", 24*1024),
+		Comments: 3,
+		Replies:  3,
+	}
+	p.TikTok = v
+
+	downloadURL := "https://v16-webapp.tiktok.com/video/tos/download/" + id + ".mp4"
+	p.handleMedia(v.MediaURL, "video/mp4", v.Media)
+	p.handleMedia(downloadURL, "video/mp4", mediaData("tiktok-watermarked", 128*1024))
+	p.handleMedia(v.MusicURL, "audio/mpeg", v.Music)
+
+	feed := fmt.Sprintf(`{"status":"ok","data":{"videos":[{
+		"id":%q,"desc":"Sunset over the harbour #travel","create_time":1700000000,
+		"video":{
+			"play_addr":{"url_list":[%q],"width":1080,"height":1920,"data_size":%d},
+			"download_addr":{"url_list":[%q],"width":720,"height":1280},
+			"cover":{"url_list":["https://p16-sign.tiktokcdn.com/obj/cover.jpeg"]},
+			"duration":15,"format":"mp4","width":1080,"height":1920},
+		"author":{"id":"6812345678901234567","unique_id":"harbourviews","nickname":"Harbour Views"},
+		"stats":{"play_count":125000,"digg_count":8400,"comment_count":312,"share_count":95},
+		"music":{"id":"7301234567890000001","title":"original sound - harbourviews","author":"Harbour Views","duration":15,"play_url":%q}
+	}]}}`, id, v.MediaURL, len(v.Media), downloadURL, v.MusicURL)
+
+	p.handle("https://api2.musical.ly/aweme/v1/feed/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("aweme_id") != id {
+			writeJSON(w, `{"status":"ok","data":{"videos":[]}}`)
+			return
+		}
+		writeJSON(w, feed)
+	})
+
+	// The first comment embeds one of its three replies
+	replies := []string{
+		`{"cid":"7302000000000000011","text":"Agreed!","user":{"uid":"103","nickname":"Leo"},"digg_count":3,"create_time":1700000200}`,
+		`{"cid":"7302000000000000012","text":"Best one this week","user":{"uid":"104","nickname":"Ana"},"digg_count":1,"create_time":1700000300}`,
+		`{"cid":"7302000000000000013","text":"Same","user":{"uid":"105","nickname":"Kai"},"digg_count":0,"create_time":1700000400}`,
+	}
+	comments := []string{
+		`{"cid":"7302000000000000001","text":"Stunning colours @harbourviews","user":{"uid":"101","nickname":"Mia"},
+		 "digg_count":42,"reply_comment_total":3,"create_time":1700000100,"reply_comments":[` + replies[0] + `]}`,
+		`{"cid":"7302000000000000002","text":"Where is this?","user":{"uid":"102","nickname":"Sam"},
+		 "digg_count":5,"reply_comment_total":0,"create_time":1700000150}`,
+		`{"cid":"7302000000000000003","text":"Saving this for later","user":{"uid":"106","nickname":"Jo"},
+		 "digg_count":2,"reply_comment_total":0,"create_time":1700000180}`,
+	}
+
+	// TikTok's cursors are offsets and has_more is 0 or 1
+	tiktokList := func(w http.ResponseWriter, r *http.Request, items []string) {
+		query := r.URL.Query()
+		list, next, more := page(items, query.Get("cursor"), pageSize(query.Get("count"), 2))
+		fmt.Fprintf(w, `{"comments":%s,"cursor":%d,"has_more":%d,"total":%d}`, list, next, more, len(items))
+	}
+	p.handle("https://www.tiktok.com/api/comment/list/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		tiktokList(w, r, comments)
+	})
+	p.handle("https://www.tiktok.com/api/comment/list/reply/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("comment_id") != "7302000000000000001" {
+			tiktokList(w, r, nil)
+			return
+		}
+		tiktokList(w, r, replies)
+	})
+}
+
+func (p *Platforms) setupXHS() {
+	const id = "65f1a2b3000000001203abcd"
+	const base = "https://sns-video-hw.xhscdn.com/stream/110/" + id + "/"
+
+	segments := [][]byte{
+		mediaData("xhs-segment-0", 12*1024),
+		mediaData("xhs-segment-1", 12*1024),
+		mediaData("xhs-segment-2", 7*1024),
+	}
+
+	v := Video{
+		ID:       id,
+		URL:      "https://www.xiaohongshu.com/explore/" + id,
+		MediaURL: base + "master.m3u8",
+		Media:    bytes.Join(segments, nil),
+		Comments: 2,
+		Replies:  2,
+	}
+	p.XHS = v
+
+	p.handle(v.MediaURL, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, "#EXTM3U\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=600000,RESOLUTION=540x960\n540p.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=1800000,RESOLUTION=1080x1920\n1080p.m3u8\n")
+	})
+	p.handle(base+"1080p.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n"+
+			"#EXTINF:6.0,\nseg0.ts\n#EXTINF:6.0,\nseg1.ts\n#EXTINF:3.5,\nseg2.ts\n#EXT-X-ENDLIST\n")
+	})
+	for i, segment := range segments {
+		p.handleMedia(fmt.Sprintf("%sseg%d.ts", base, i), "video/mp2t", segment)
+	}
+
+	note := fmt.Sprintf(`{"props":{"pageProps":{"note":{"id":%q,"title":"周末去海边","desc":"海风和日落","type":"video",
+		"create_time":1710000000,
+		"user":{"id":"5f0e1d2c000000000101abcd","nickname":"小海"},
+		"video":{"play_addr":%q,"duration":16,"width":1080,"height":1920}}}}}`, id, v.MediaURL)
+
+	p.handle(v.URL, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<!DOCTYPE html><html><head><title>周末去海边 - 小红书</title></head><body>
+<script id="__NEXT_DATA__" type="application/json">%s</script></body></html>`, note)
+	})
+
+	subComments := []string{
+		`{"id":"6601a0000000000011","content":"谢谢","user":{"user_id":"5f0e1d2c000000000101abcd","nickname":"小海"},"liked_count":2,"create_time":1710000200000,"ip_location":"浙江"}`,
+		`{"id":"6601a0000000000012","content":"同款心情","user":{"user_id":"u3","nickname":"木木"},"liked_count":0,"create_time":1710000300000,"ip_location":"广东"}`,
+	}
+	comments := []string{
+		`{"id":"6601a0000000000001","content":"好美 @小海","user":{"user_id":"u1","nickname":"阿青"},
+		 "liked_count":18,"sub_comment_count":2,"create_time":1710000100000,"ip_location":"上海",
+		 "sub_comments":[` + subComments[0] + `],"sub_comment_cursor":"1","sub_comment_has_more":true}`,
+		`{"id":"6601a0000000000002","content":"求地址","user":{"user_id":"u2","nickname":"大鹏"},
+		 "liked_count":3,"sub_comment_count":0,"create_time":1710000150000,"ip_location":"北京"}`,
+	}
+
+	xhsList := func(w http.ResponseWriter, r *http.Request, items []string) {
+		query := r.URL.Query()
+		list, next, more := page(items, query.Get("cursor"), 1)
+		fmt.Fprintf(w, `{"success":true,"data":{"comments":%s,"cursor":"%d","has_more":%t}}`, list, next, more == 1)
+	}
+	p.handle("https://edith.xiaohongshu.com/api/sns/web/v2/comment/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		xhsList(w, r, comments)
+	})
+	p.handle("https://edith.xiaohongshu.com/api/sns/web/v2/comment/sub/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("root_comment_id") != "6601a0000000000001" {
+			xhsList(w, r, nil)
+			return
+		}
+		xhsList(w, r, subComments)
+	})
+}
+
+func (p *Platforms) setupKuaishou() {
+	const id = "3x5m8k2n4p6r8t1"
+	v := Video{
+		ID:       id,
+		URL:      "https://www.kuaishou.com/short-video/" + id,
+		MediaURL: "https://v2.kwaicdn.com/upic/2024/04/01/" + id + "_1080.mp4",
+		Media:    mediaData("kuaishou-1080", 160*1024),
+		Comments: 3,
+		Replies:  2,
+	}
+	p.Kuaishou = v
+
+	lowURL := "https://v2.kwaicdn.com/upic/2024/04/01/" + id + "_720.mp4"
+	p.handleMedia(v.MediaURL, "video/mp4", v.Media)
+	p.handleMedia(lowURL, "video/mp4", mediaData("kuaishou-720", 96*1024))
+
+	detail := fmt.Sprintf(`{"data":{"visionVideoDetail":{"status":1,
+		"author":{"id":"3xq7wz8ab2cd4ef","name":"山里的阿强"},
+		"photo":{"id":%q,"caption":"清晨赶集","duration":58000,"timestamp":1712000000000,"width":720,"height":1280,
+			"manifest":{"mediaType":"video","adaptationSet":[{"id":"1","representation":[
+				{"id":"1","url":%q,"bandwidth":2400000,"qualityType":2,"qualityLabel":"1080p","width":1080,"height":1920},
+				{"id":"2","url":%q,"bandwidth":1100000,"qualityType":1,"qualityLabel":"720p","width":720,"height":1280}
+			]}]}}}}}`, id, v.MediaURL, lowURL)
+
+	subComments := []string{
+		`{"commentId":"9011","content":"刚出锅的","user":{"id":"3xq7wz8ab2cd4ef","name":"山里的阿强"},"likeCount":6,"createTime":1712000150000}`,
+		`{"commentId":"9012","content":"馋了","user":{"id":"k4","name":"阿花"},"likeCount":1,"createTime":1712000160000}`,
+	}
+	comments := []string{
+		`{"commentId":"901","content":"看着好香","user":{"id":"k1","name":"小王"},"likeCount":30,"subCommentCount":2,
+		 "createTime":1712000100000,"subComments":[` + subComments[0] + `],"subCommentsPcursor":"1"}`,
+		`{"commentId":"902","content":"在哪个镇？@山里的阿强","user":{"id":"k2","name":"老李"},"likeCount":4,"subCommentCount":0,"createTime":1712000200000}`,
+		`{"commentId":"903","content":"下次带我","user":{"id":"k3","name":"小周"},"likeCount":2,"subCommentCount":0,"createTime":1712000300000}`,
+	}
+
+	// Kuaishou ends a list with the pcursor "no_more"
+	pcursor := func(next, more int) string {
+		if more == 0 {
+			return "no_more"
+		}
+		return strconv.Itoa(next)
+	}
+
+	p.handle("https://www.kuaishou.com/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var query struct {
+			OperationName string `json:"operationName"`
+			Variables     struct {
+				PhotoID       string  `json:"photoId"`
+				Pcursor       string  `json:"pcursor"`
+				Count         float64 `json:"count"`
+				RootCommentID string  `json:"rootCommentId"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query.Variables.PhotoID != id {
+			writeJSON(w, `{"data":{},"errors":[{"message":"photo not found"}]}`)
+			return
+		}
+
+		switch query.OperationName {
+		case "visionVideoDetail":
+			writeJSON(w, detail)
+		case "commentListQuery":
+			size := pageSize(strconv.Itoa(int(query.Variables.Count)), 2)
+			list, next, more := page(comments, query.Variables.Pcursor, size)
+			writeJSON(w, fmt.Sprintf(`{"data":{"visionCommentList":{"pcursor":%q,"comments":%s}}}`, pcursor(next, more), list))
+		case "visionSubCommentList":
+			items := subComments
+			if query.Variables.RootCommentID != "901" {
+				items = nil
+			}
+			list, next, more := page(items, query.Variables.Pcursor, 1)
+			writeJSON(w, fmt.Sprintf(`{"data":{"visionSubCommentList":{"pcursor":%q,"subComments":%s}}}`, pcursor(next, more), list))
+		default:
+			http.Error(w, "unknown operation", http.StatusBadRequest)
+		}
+	})
+}
+
+// routeKey returns the host and path of rawURL
+func routeKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host + u.Path
+}
+
+// page returns the JSON array of up to size items starting at the offset
+// in cursor, the offset of the next page and 1 if there is one, else 0
+func page(items []string, cursor string, size int) (string, int, int) {
+	start, _ := strconv.Atoi(cursor)
+	start = min(max(start, 0), len(items))
+	end := min(start+size, len(items))
+
+	more := 0
+	if end < len(items) {
+		more = 1
+	}
+	return "[" + strings.Join(items[start:end], ",") + "]", end, more
+}
+
+// pageSize returns the page size a client asked for in count, capped at
+// limit
+func pageSize(count string, limit int) int {
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return limit
+	}
+	return min(n, limit)
+}
+
+// mediaData returns size bytes of content unique to seed
+func mediaData(seed string, size int) []byte {
+	return bytes.Repeat([]byte(seed+"|"), size/(len(seed)+1)+1)[:size]
+}
+
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, body)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,236 @@
+// Package platformtest records HTTP exchanges with the platforms to
+// fixture files and replays them, so that extractors can be tested offline
+// against real page and API responses.
+//
+// A fixture is a directory holding interactions.json, which lists the
+// requests in the order they were made, and one file per response body.
+// Tests replay fixtures by default; run them with -record to fetch fresh
+// responses from the live sites and overwrite the fixtures, and with
+// -update to rewrite golden files from the current output.
+package platformtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	record = flag.Bool("record", false, "record HTTP fixtures from the live sites instead of replaying them")
+	update = flag.Bool("update", false, "rewrite golden files with the current output")
+)
+
+// interactionsFile lists the exchanges of a fixture
+const interactionsFile = "interactions.json"
+
+// Interaction is one recorded request and its response
+type Interaction struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body names the file in the fixture directory holding the response
+	// body
+	Body string `json:"body,omitempty"`
+}
+
+// Transport is an http.RoundTripper that answers requests from a fixture
+// directory, or forwards them to the network and records them when tests
+// run with -record
+type Transport struct {
+	dir    string
+	record bool
+	next   http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewTransport returns a transport for the fixture in dir. When recording,
+// the fixture is written when the test finishes.
+func NewTransport(t testing.TB, dir string) *Transport {
+	t.Helper()
+
+	tr := &Transport{dir: dir, record: *record, next: http.DefaultTransport}
+	if tr.record {
+		t.Cleanup(func() {
+			if err := tr.save(); err != nil {
+				t.Errorf("Error saving fixture %s: %v", dir, err)
+			}
+		})
+		return tr
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, interactionsFile))
+	if err != nil {
+		t.Fatalf("Error reading fixture: %v", err)
+	}
+	if err := json.Unmarshal(data, &tr.interactions); err != nil {
+		t.Fatalf("Error parsing %s: %v", filepath.Join(dir, interactionsFile), err)
+	}
+	tr.used = make([]bool, len(tr.interactions))
+
+	return tr
+}
+
+// RoundTrip implements http.RoundTripper
+func (tr *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if tr.record {
+		return tr.recordRoundTrip(req)
+	}
+	return tr.replay(req)
+}
+
+// replay answers req with the first unused interaction for the same method
+// and URL. Once all are used, the last one is repeated.
+func (tr *Transport) replay(req *http.Request) (*http.Response, error) {
+	key := canonicalURL(req.URL.String())
+
+	tr.mu.Lock()
+	match := -1
+	for i, interaction := range tr.interactions {
+		if interaction.Method != req.Method || canonicalURL(interaction.URL) != key {
+			continue
+		}
+		match = i
+		if !tr.used[i] {
+			break
+		}
+	}
+	if match >= 0 {
+		tr.used[match] = true
+	}
+	tr.mu.Unlock()
+
+	if match < 0 {
+		return nil, fmt.Errorf("platformtest: no recorded response for %s %s", req.Method, req.URL)
+	}
+	interaction := tr.interactions[match]
+
+	var body []byte
+	if interaction.Body != "" {
+		data, err := os.ReadFile(filepath.Join(tr.dir, interaction.Body))
+		if err != nil {
+			return nil, fmt.Errorf("platformtest: %w", err)
+		}
+		body = data
+	}
+
+	header := make(http.Header)
+	for key, value := range interaction.Headers {
+		header.Set(key, value)
+	}
+
+	status := interaction.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// recordRoundTrip performs req and keeps the exchange
+func (tr *Transport) recordRoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := tr.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Status:  resp.StatusCode,
+		Headers: map[string]string{},
+	}
+	for _, key := range []string{"Content-Type", "Location", "Accept-Ranges"} {
+		if value := resp.Header.Get(key); value != "" {
+			interaction.Headers[key] = value
+		}
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if len(body) > 0 {
+		interaction.Body = fmt.Sprintf("%02d%s", len(tr.interactions)+1, bodyExtension(resp.Header.Get("Content-Type")))
+		if err := os.MkdirAll(tr.dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(tr.dir, interaction.Body), body, 0644); err != nil {
+			return nil, err
+		}
+	}
+	tr.interactions = append(tr.interactions, interaction)
+
+	return resp, nil
+}
+
+// save writes interactions.json for a recorded fixture
+func (tr *Transport) save() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	data, err := json.MarshalIndent(tr.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tr.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(tr.dir, interactionsFile), append(data, '\n'), 0644)
+}
+
+// canonicalURL returns u with its query parameters sorted, so that
+// recordings match regardless of parameter order
+func canonicalURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	parsed.RawQuery = parsed.Query().Encode()
+	return parsed.String()
+}
+
+// bodyExtension picks a file extension for a response body by its
+// content type
+func bodyExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.Contains(mediaType, "json"):
+		return ".json"
+	case strings.Contains(mediaType, "html"):
+		return ".html"
+	case strings.HasPrefix(mediaType, "text/"):
+		return ".txt"
+	default:
+		return ".bin"
+	}
+}
@@ -1,6 +1,7 @@
 package tiktok
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -35,20 +36,32 @@ type TikTokVideo struct {
 }
 
 type Video struct {
-	PlayAddr     VideoURL `json:"play_addr"`
-	DownloadAddr VideoURL `json:"download_addr"`
-	Cover        VideoURL `json:"cover"`
-	Duration     int      `json:"duration"`
-	Format       string   `json:"format"`
-	Height       int      `json:"height"`
-	Width        int      `json:"width"`
+	PlayAddr     VideoURL  `json:"play_addr"`
+	DownloadAddr VideoURL  `json:"download_addr"`
+	Cover        VideoURL  `json:"cover"`
+	Duration     int       `json:"duration"`
+	Format       string    `json:"format"`
+	Height       int       `json:"height"`
+	Width        int       `json:"width"`
+	BitRate      []BitRate `json:"bit_rate"`
 }
 
 type VideoURL struct {
-	URI     string   `json:"uri"`
-	URLList []string `json:"url_list"`
-	Width   int      `json:"width"`
-	Height  int      `json:"height"`
+	URI      string   `json:"uri"`
+	URLList  []string `json:"url_list"`
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	DataSize int64    `json:"data_size"`
+}
+
+// BitRate is one of the encodings listed for a video
+type BitRate struct {
+	GearName    string   `json:"gear_name"`
+	QualityType int      `json:"quality_type"`
+	BitRate     int      `json:"bit_rate"`
+	IsH265      int      `json:"is_h265"`
+	IsBytevc1   int      `json:"is_bytevc1"`
+	PlayAddr    VideoURL `json:"play_addr"`
 }
 
 type Author struct {
@@ -103,6 +116,7 @@ func NewExtractor(config *models.ExtractorConfig) *tiktokExtractor {
 			UserAgent:   userAgent,
 			Cookie:      config.Cookie,
 			TLSInsecure: true,
+			Transport:   config.Transport,
 		}),
 		config:    config,
 		logger:    zerolog.New(os.Stdout).With().Timestamp().Logger(),
@@ -112,7 +126,7 @@ func NewExtractor(config *models.ExtractorConfig) *tiktokExtractor {
 }
 
 // ExtractVideoInfo extracts video information from a TikTok URL
-func (e *tiktokExtractor) ExtractVideoInfo(tiktokURL string) (*models.VideoInfo, error) {
+func (e *tiktokExtractor) ExtractVideoInfo(ctx context.Context, tiktokURL string) (*models.VideoInfo, error) {
 	// Extract video ID from URL
 	videoID, err := e.extractVideoID(tiktokURL)
 	if err != nil {
@@ -120,7 +134,7 @@ func (e *tiktokExtractor) ExtractVideoInfo(tiktokURL string) (*models.VideoInfo,
 	}
 
 	// Get video data from API
-	videoData, err := e.getVideoData(videoID)
+	videoData, err := e.getVideoData(ctx, videoID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting video data: %w", err)
 	}
@@ -132,14 +146,14 @@ func (e *tiktokExtractor) ExtractVideoInfo(tiktokURL string) (*models.VideoInfo,
 }
 
 // ExtractAuthorInfo extracts author information
-func (e *tiktokExtractor) ExtractAuthorInfo(authorID string) (*models.AuthorInfo, error) {
+func (e *tiktokExtractor) ExtractAuthorInfo(ctx context.Context, authorID string) (*models.AuthorInfo, error) {
 	// TikTok API doesn't have a direct author info endpoint
 	// We'll extract it from a video
 	return nil, fmt.Errorf("not implemented")
 }
 
 // ExtractBatch extracts multiple videos from a TikTok page
-func (e *tiktokExtractor) ExtractBatch(url string, limit int) ([]*models.VideoInfo, error) {
+func (e *tiktokExtractor) ExtractBatch(ctx context.Context, url string, limit int) ([]*models.VideoInfo, error) {
 	// Extract username from URL
 	username, err := e.extractUsername(url)
 	if err != nil {
@@ -147,7 +161,7 @@ func (e *tiktokExtractor) ExtractBatch(url string, limit int) ([]*models.VideoIn
 	}
 
 	// Get user videos
-	videos, err := e.getUserVideos(username, limit)
+	videos, err := e.getUserVideos(ctx, username, limit)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user videos: %w", err)
 	}
@@ -210,7 +224,7 @@ func (e *tiktokExtractor) extractUsername(url string) (string, error) {
 }
 
 // getVideoData fetches video data from TikTok API
-func (e *tiktokExtractor) getVideoData(videoID string) (*TikTokVideo, error) {
+func (e *tiktokExtractor) getVideoData(ctx context.Context, videoID string) (*TikTokVideo, error) {
 	// TikTok mobile API endpoint
 	apiURL := fmt.Sprintf("https://api2.musical.ly/aweme/v1/feed/?aweme_id=%s", videoID)
 
@@ -224,7 +238,7 @@ func (e *tiktokExtractor) getVideoData(videoID string) (*TikTokVideo, error) {
 		headers["Cookie"] = e.cookie
 	}
 
-	resp, err := e.client.Get(apiURL, headers)
+	resp, err := e.client.Get(ctx, apiURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching video data: %w", err)
 	}
@@ -248,7 +262,7 @@ func (e *tiktokExtractor) getVideoData(videoID string) (*TikTokVideo, error) {
 }
 
 // getUserVideos fetches user videos from TikTok
-func (e *tiktokExtractor) getUserVideos(username string, limit int) ([]TikTokVideo, error) {
+func (e *tiktokExtractor) getUserVideos(ctx context.Context, username string, limit int) ([]TikTokVideo, error) {
 	// This is a simplified implementation
 	// In reality, you would need to handle pagination and API rate limits
 
@@ -264,7 +278,7 @@ func (e *tiktokExtractor) getUserVideos(username string, limit int) ([]TikTokVid
 		headers["Cookie"] = e.cookie
 	}
 
-	resp, err := e.client.Get(apiURL, headers)
+	resp, err := e.client.Get(ctx, apiURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching user page: %w", err)
 	}
@@ -301,6 +315,15 @@ func (e *tiktokExtractor) convertToVideoInfo(video *TikTokVideo) *models.VideoIn
 		duration = 30 // Default duration
 	}
 
+	// Keep the platform payload for sidecar files
+	raw, _ := json.Marshal(video)
+
+	music := convertMusic(video)
+	musicID := ""
+	if music != nil {
+		musicID = music.ID
+	}
+
 	return &models.VideoInfo{
 		ID:          video.ID,
 		Platform:    models.PlatformTikTok,
@@ -311,6 +334,7 @@ func (e *tiktokExtractor) convertToVideoInfo(video *TikTokVideo) *models.VideoIn
 		Thumbnail:   thumbnail,
 		Duration:    duration,
 		MediaType:   models.MediaTypeVideo,
+		Renditions:  renditions(video),
 		Size:        0, // Will be filled during download
 		Format:      "mp4",
 		Quality:     "hd",
@@ -334,10 +358,96 @@ func (e *tiktokExtractor) convertToVideoInfo(video *TikTokVideo) *models.VideoIn
 		Status:     "pending",
 		RetryCount: 0,
 
+		// Soundtrack
+		MusicID: musicID,
+		Music:   music,
+
 		// Additional metadata
 		Metadata: fmt.Sprintf(`{"music_id":"%s","music_title":"%s","music_author":"%s"}`,
 			video.Music.ID, video.Music.Title, video.Music.Author),
 		ExtractFrom: "api",
+		RawData:     raw,
+	}
+}
+
+// renditions lists every encoding of the video. The bit rate ladder and
+// play address are clean; the download address carries the watermark.
+func renditions(video *TikTokVideo) []models.Rendition {
+	var list []models.Rendition
+
+	for _, rate := range video.Video.BitRate {
+		if len(rate.PlayAddr.URLList) == 0 {
+			continue
+		}
+		codec := "h264"
+		if rate.IsH265 == 1 || rate.IsBytevc1 == 1 {
+			codec = "h265"
+		}
+		list = append(list, models.Rendition{
+			URL:     rate.PlayAddr.URLList[0],
+			Width:   rate.PlayAddr.Width,
+			Height:  rate.PlayAddr.Height,
+			Bitrate: rate.BitRate,
+			Codec:   codec,
+			Format:  "mp4",
+			Size:    rate.PlayAddr.DataSize,
+			Label:   rate.GearName,
+		})
+	}
+
+	addr := func(url VideoURL, watermark bool, label string) {
+		if len(url.URLList) == 0 {
+			return
+		}
+		width, height := url.Width, url.Height
+		if width == 0 || height == 0 {
+			width, height = video.Video.Width, video.Video.Height
+		}
+		list = append(list, models.Rendition{
+			URL:       url.URLList[0],
+			Width:     width,
+			Height:    height,
+			Codec:     "h264",
+			Format:    "mp4",
+			Size:      url.DataSize,
+			Watermark: watermark,
+			Label:     label,
+		})
+	}
+	addr(video.Video.PlayAddr, false, "play")
+	addr(video.Video.DownloadAddr, true, "download")
+
+	return list
+}
+
+// convertMusic returns the video's soundtrack as an audio record, or nil
+// when the video has no playable sound
+func convertMusic(video *TikTokVideo) *models.VideoInfo {
+	if video.Music.PlayURL == "" {
+		return nil
+	}
+
+	soundID := video.Music.ID
+	if soundID == "" {
+		// Original sounds are sometimes served without an ID
+		soundID = "original_" + video.ID
+	}
+
+	return &models.VideoInfo{
+		ID:          models.MusicRecordID(soundID),
+		Platform:    models.PlatformTikTok,
+		Title:       video.Music.Title,
+		URL:         fmt.Sprintf("https://www.tiktok.com/music/-%s", soundID),
+		DownloadURL: video.Music.PlayURL,
+		Thumbnail:   video.Music.CoverURL,
+		Duration:    video.Music.Duration,
+		MediaType:   models.MediaTypeAudio,
+		Format:      "mp3",
+		AuthorName:  video.Music.Author,
+		PublishedAt: time.Unix(video.CreateTime, 0),
+		CollectedAt: time.Now(),
+		Status:      "pending",
+		ExtractFrom: "api",
 	}
 }
 
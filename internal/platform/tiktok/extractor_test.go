@@ -0,0 +1,51 @@
+package tiktok
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"video-downloader/internal/platform/platformtest"
+	"video-downloader/pkg/models"
+)
+
+func newTestExtractor(t *testing.T, fixture string) *tiktokExtractor {
+	return NewExtractor(&models.ExtractorConfig{
+		Transport: platformtest.NewTransport(t, filepath.Join("testdata", fixture)),
+	})
+}
+
+func TestExtractVideoInfo(t *testing.T) {
+	tests := []struct {
+		fixture string
+		url     string
+		wantErr bool
+	}{
+		{"video", "https://www.tiktok.com/@harbourviews/video/7301234567890123456", false},
+		{"not_found", "https://www.tiktok.com/@harbourviews/video/7300000000000000000", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			info, err := newTestExtractor(t, tt.fixture).ExtractVideoInfo(context.Background(), tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			platformtest.AssertGolden(t, filepath.Join("testdata", tt.fixture, "want.json"), platformtest.StableVideo(info))
+		})
+	}
+}
+
+func TestExtractAuthorInfoNotImplemented(t *testing.T) {
+	e := NewExtractor(&models.ExtractorConfig{})
+	if _, err := e.ExtractAuthorInfo(context.Background(), "harbourviews"); err == nil {
+		t.Error("Expected an error")
+	}
+}
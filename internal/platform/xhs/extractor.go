@@ -1,10 +1,12 @@
 package xhs
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -62,11 +64,24 @@ type XHSImage struct {
 }
 
 type XHSVideo struct {
-	PlayAddr string `json:"play_addr"`
-	Duration int    `json:"duration"`
-	Width    int    `json:"width"`
-	Height   int    `json:"height"`
-	Cover    string `json:"cover"`
+	PlayAddr string      `json:"play_addr"`
+	Duration int         `json:"duration"`
+	Width    int         `json:"width"`
+	Height   int         `json:"height"`
+	Cover    string      `json:"cover"`
+	Streams  []XHSStream `json:"streams"`
+}
+
+// XHSStream is one encoding from a note's media.stream lists
+type XHSStream struct {
+	Codec       string `json:"codec"`
+	MasterURL   string `json:"master_url"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	AvgBitrate  int    `json:"avg_bitrate"`
+	Size        int64  `json:"size"`
+	Format      string `json:"format"`
+	QualityType string `json:"quality_type"`
 }
 
 type InteractInfo struct {
@@ -98,6 +113,7 @@ func NewExtractor(config *models.ExtractorConfig) *xhsExtractor {
 			UserAgent:   userAgent,
 			Cookie:      config.Cookie,
 			TLSInsecure: true,
+			Transport:   config.Transport,
 		}),
 		config:    config,
 		logger:    zerolog.New(os.Stdout).With().Timestamp().Logger(),
@@ -107,15 +123,15 @@ func NewExtractor(config *models.ExtractorConfig) *xhsExtractor {
 }
 
 // ExtractVideoInfo extracts video information from an XHS URL
-func (e *xhsExtractor) ExtractVideoInfo(xhsURL string) (*models.VideoInfo, error) {
+func (e *xhsExtractor) ExtractVideoInfo(ctx context.Context, xhsURL string) (*models.VideoInfo, error) {
 	// Extract note ID from URL
-	noteID, err := e.extractNoteID(xhsURL)
+	noteID, err := e.extractNoteID(ctx, xhsURL)
 	if err != nil {
 		return nil, fmt.Errorf("error extracting note ID: %w", err)
 	}
 
 	// Get note data
-	noteData, err := e.getNoteData(noteID)
+	noteData, err := e.getNoteData(ctx, noteID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting note data: %w", err)
 	}
@@ -127,9 +143,9 @@ func (e *xhsExtractor) ExtractVideoInfo(xhsURL string) (*models.VideoInfo, error
 }
 
 // ExtractAuthorInfo extracts author information
-func (e *xhsExtractor) ExtractAuthorInfo(authorID string) (*models.AuthorInfo, error) {
+func (e *xhsExtractor) ExtractAuthorInfo(ctx context.Context, authorID string) (*models.AuthorInfo, error) {
 	// Get user data
-	userData, err := e.getUserData(authorID)
+	userData, err := e.getUserData(ctx, authorID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user data: %w", err)
 	}
@@ -141,7 +157,7 @@ func (e *xhsExtractor) ExtractAuthorInfo(authorID string) (*models.AuthorInfo, e
 }
 
 // ExtractBatch extracts multiple notes from an XHS user page
-func (e *xhsExtractor) ExtractBatch(url string, limit int) ([]*models.VideoInfo, error) {
+func (e *xhsExtractor) ExtractBatch(ctx context.Context, url string, limit int) ([]*models.VideoInfo, error) {
 	// Extract user ID from URL
 	userID, err := e.extractUserID(url)
 	if err != nil {
@@ -149,7 +165,7 @@ func (e *xhsExtractor) ExtractBatch(url string, limit int) ([]*models.VideoInfo,
 	}
 
 	// Get user notes
-	notes, err := e.getUserNotes(userID, limit)
+	notes, err := e.getUserNotes(ctx, userID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user notes: %w", err)
 	}
@@ -191,10 +207,10 @@ func (e *xhsExtractor) GetSupportedURLPatterns() []string {
 }
 
 // extractNoteID extracts note ID from XHS URL
-func (e *xhsExtractor) extractNoteID(url string) (string, error) {
+func (e *xhsExtractor) extractNoteID(ctx context.Context, url string) (string, error) {
 	// Handle short URLs first
 	if strings.Contains(url, "xhslink.com") {
-		return e.resolveShortURL(url)
+		return e.resolveShortURL(ctx, url)
 	}
 
 	// Extract from explore/discovery URLs
@@ -217,8 +233,8 @@ func (e *xhsExtractor) extractUserID(url string) (string, error) {
 }
 
 // resolveShortURL resolves XHS short URL
-func (e *xhsExtractor) resolveShortURL(shortURL string) (string, error) {
-	resp, err := e.client.Get(shortURL, map[string]string{
+func (e *xhsExtractor) resolveShortURL(ctx context.Context, shortURL string) (string, error) {
+	resp, err := e.client.Get(ctx, shortURL, map[string]string{
 		"User-Agent": e.userAgent,
 	})
 	if err != nil {
@@ -232,11 +248,11 @@ func (e *xhsExtractor) resolveShortURL(shortURL string) (string, error) {
 
 	// Get final URL after redirects
 	finalURL := resp.Request.URL.String()
-	return e.extractNoteID(finalURL)
+	return e.extractNoteID(ctx, finalURL)
 }
 
 // getNoteData fetches note data from XHS
-func (e *xhsExtractor) getNoteData(noteID string) (*XHSNote, error) {
+func (e *xhsExtractor) getNoteData(ctx context.Context, noteID string) (*XHSNote, error) {
 	// XHS doesn't have a public API, so we need to scrape the web page
 	noteURL := fmt.Sprintf("https://www.xiaohongshu.com/explore/%s", noteID)
 
@@ -251,7 +267,7 @@ func (e *xhsExtractor) getNoteData(noteID string) (*XHSNote, error) {
 		headers["Cookie"] = e.cookie
 	}
 
-	resp, err := e.client.Get(noteURL, headers)
+	resp, err := e.client.Get(ctx, noteURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching note page: %w", err)
 	}
@@ -266,7 +282,7 @@ func (e *xhsExtractor) getNoteData(noteID string) (*XHSNote, error) {
 }
 
 // getUserData fetches user data from XHS
-func (e *xhsExtractor) getUserData(userID string) (*XHSUser, error) {
+func (e *xhsExtractor) getUserData(ctx context.Context, userID string) (*XHSUser, error) {
 	userURL := fmt.Sprintf("https://www.xiaohongshu.com/user/profile/%s", userID)
 
 	headers := map[string]string{
@@ -279,7 +295,7 @@ func (e *xhsExtractor) getUserData(userID string) (*XHSUser, error) {
 		headers["Cookie"] = e.cookie
 	}
 
-	resp, err := e.client.Get(userURL, headers)
+	resp, err := e.client.Get(ctx, userURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching user page: %w", err)
 	}
@@ -294,7 +310,7 @@ func (e *xhsExtractor) getUserData(userID string) (*XHSUser, error) {
 }
 
 // getUserNotes fetches user notes from XHS
-func (e *xhsExtractor) getUserNotes(userID string, limit int) ([]XHSNote, error) {
+func (e *xhsExtractor) getUserNotes(ctx context.Context, userID string, limit int) ([]XHSNote, error) {
 	// This would typically require pagination and handling of XHS's dynamic loading
 	// For now, return empty slice
 	return []XHSNote{}, nil
@@ -302,6 +318,42 @@ func (e *xhsExtractor) getUserNotes(userID string, limit int) ([]XHSNote, error)
 
 // extractNoteFromHTML extracts note data from HTML
 func (e *xhsExtractor) extractNoteFromHTML(body io.Reader) (*XHSNote, error) {
+	nextData, err := findNextData(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract note data from __NEXT_DATA__
+	noteData := e.parseNextData(nextData)
+	if noteData == nil {
+		return nil, fmt.Errorf("note data not found in HTML")
+	}
+
+	return noteData, nil
+}
+
+// extractUserFromHTML extracts user data from a profile page's
+// __NEXT_DATA__
+func (e *xhsExtractor) extractUserFromHTML(body io.Reader) (*XHSUser, error) {
+	nextData, err := findNextData(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if props, ok := nextData["props"].(map[string]interface{}); ok {
+		if pageProps, ok := props["pageProps"].(map[string]interface{}); ok {
+			if user, ok := pageProps["user"].(map[string]interface{}); ok {
+				userData := e.parseUserData(user)
+				return &userData, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("user data not found in HTML")
+}
+
+// findNextData returns the decoded __NEXT_DATA__ script of a page
+func findNextData(body io.Reader) (map[string]interface{}, error) {
 	// Parse HTML
 	doc, err := html.Parse(body)
 	if err != nil {
@@ -309,41 +361,29 @@ func (e *xhsExtractor) extractNoteFromHTML(body io.Reader) (*XHSNote, error) {
 	}
 
 	// Look for script tags containing data
-	var noteData *XHSNote
+	var nextData map[string]interface{}
 	var f func(*html.Node)
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "script" {
 			for _, attr := range n.Attr {
-				if attr.Key == "id" && attr.Val == "__NEXT_DATA__" {
-					if n.FirstChild != nil {
-						var nextData map[string]interface{}
-						if err := json.Unmarshal([]byte(n.FirstChild.Data), &nextData); err == nil {
-							// Extract note data from __NEXT_DATA__
-							noteData = e.parseNextData(nextData)
-							return
-						}
+				if attr.Key == "id" && attr.Val == "__NEXT_DATA__" && n.FirstChild != nil {
+					if err := json.Unmarshal([]byte(n.FirstChild.Data), &nextData); err == nil {
+						return
 					}
 				}
 			}
 		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
+		for c := n.FirstChild; c != nil && nextData == nil; c = c.NextSibling {
 			f(c)
 		}
 	}
 	f(doc)
 
-	if noteData == nil {
-		return nil, fmt.Errorf("note data not found in HTML")
+	if nextData == nil {
+		return nil, fmt.Errorf("__NEXT_DATA__ not found in HTML")
 	}
 
-	return noteData, nil
-}
-
-// extractUserFromHTML extracts user data from HTML
-func (e *xhsExtractor) extractUserFromHTML(body io.Reader) (*XHSUser, error) {
-	// Similar to extractNoteFromHTML but for user data
-	// This is a simplified implementation
-	return &XHSUser{}, nil
+	return nextData, nil
 }
 
 // parseNextData parses __NEXT_DATA__ to extract note information
@@ -403,6 +443,17 @@ func (e *xhsExtractor) parseNoteData(data map[string]interface{}) *XHSNote {
 		note.Video = e.parseVideoData(video)
 	}
 
+	// Parse tags
+	if tagList, ok := data["tag_list"].([]interface{}); ok {
+		for _, tag := range tagList {
+			if tagMap, ok := tag.(map[string]interface{}); ok {
+				if name, ok := tagMap["name"].(string); ok && name != "" {
+					note.Tags = append(note.Tags, name)
+				}
+			}
+		}
+	}
+
 	return note
 }
 
@@ -430,6 +481,22 @@ func (e *xhsExtractor) parseUserData(data map[string]interface{}) XHSUser {
 		user.Followers = int(followers)
 	}
 
+	if following, ok := data["follows"].(float64); ok {
+		user.Following = int(following)
+	}
+
+	if notesCount, ok := data["notes_count"].(float64); ok {
+		user.NotesCount = int(notesCount)
+	}
+
+	if ipLocation, ok := data["ip_location"].(string); ok {
+		user.IPLocation = ipLocation
+	}
+
+	if level, ok := data["level"].(float64); ok {
+		user.Level = int(level)
+	}
+
 	return user
 }
 
@@ -480,26 +547,94 @@ func (e *xhsExtractor) parseVideoData(data map[string]interface{}) XHSVideo {
 		video.Cover = cover
 	}
 
+	// Encodings are grouped by codec under media.stream
+	if media, ok := data["media"].(map[string]interface{}); ok {
+		if streams, ok := media["stream"].(map[string]interface{}); ok {
+			for _, codec := range []string{"h264", "h265", "av1"} {
+				list, _ := streams[codec].([]interface{})
+				for _, item := range list {
+					if stream, ok := item.(map[string]interface{}); ok {
+						video.Streams = append(video.Streams, e.parseStreamData(codec, stream))
+					}
+				}
+			}
+		}
+	}
+
 	return video
 }
 
+// parseStreamData parses one entry of a media.stream codec list
+func (e *xhsExtractor) parseStreamData(codec string, data map[string]interface{}) XHSStream {
+	stream := XHSStream{Codec: codec}
+
+	if masterURL, ok := data["masterUrl"].(string); ok {
+		stream.MasterURL = masterURL
+	}
+
+	if width, ok := data["width"].(float64); ok {
+		stream.Width = int(width)
+	}
+
+	if height, ok := data["height"].(float64); ok {
+		stream.Height = int(height)
+	}
+
+	if bitrate, ok := data["avgBitrate"].(float64); ok {
+		stream.AvgBitrate = int(bitrate)
+	}
+
+	if size, ok := data["size"].(float64); ok {
+		stream.Size = int64(size)
+	}
+
+	if format, ok := data["format"].(string); ok {
+		stream.Format = format
+	}
+
+	if qualityType, ok := data["qualityType"].(string); ok {
+		stream.QualityType = qualityType
+	}
+
+	return stream
+}
+
 // convertToVideoInfo converts XHSNote to VideoInfo
 func (e *xhsExtractor) convertToVideoInfo(note *XHSNote) *models.VideoInfo {
 	var mediaType models.MediaType
 	var downloadURL string
 	var duration int
+	var items []models.MediaItem
 
-	if note.Type == "video" && note.Video.PlayAddr != "" {
+	thumbnail := note.Video.Cover
+
+	if note.Type == "video" && (note.Video.PlayAddr != "" || len(note.Video.Streams) > 0) {
 		mediaType = models.MediaTypeVideo
 		downloadURL = note.Video.PlayAddr
+		if downloadURL == "" {
+			downloadURL = note.Video.Streams[0].MasterURL
+		}
 		duration = note.Video.Duration
 	} else if len(note.Images) > 0 {
 		mediaType = models.MediaTypeImage
-		// For images, we'll use the first image URL
-		downloadURL = note.Images[0].URL
+		// Image notes download every image; DownloadURL is the first
+		items = imageItems(note.Images)
+		downloadURL = items[0].URL
 		duration = 0
+		if thumbnail == "" {
+			thumbnail = displayImageURL(note.Images[0])
+		}
 	}
 
+	// Keep the platform payload for sidecar files
+	raw, _ := json.Marshal(note)
+
+	tags := note.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	metadata, _ := json.Marshal(map[string][]string{"tags": tags})
+
 	return &models.VideoInfo{
 		ID:          note.ID,
 		Platform:    models.PlatformXHS,
@@ -507,9 +642,11 @@ func (e *xhsExtractor) convertToVideoInfo(note *XHSNote) *models.VideoInfo {
 		Description: note.Desc,
 		URL:         fmt.Sprintf("https://www.xiaohongshu.com/explore/%s", note.ID),
 		DownloadURL: downloadURL,
-		Thumbnail:   note.Video.Cover,
+		Thumbnail:   thumbnail,
 		Duration:    duration,
 		MediaType:   mediaType,
+		MediaItems:  items,
+		Renditions:  renditions(note),
 		Size:        0, // Will be filled during download
 		Format:      "mp4",
 		Quality:     "hd",
@@ -533,9 +670,120 @@ func (e *xhsExtractor) convertToVideoInfo(note *XHSNote) *models.VideoInfo {
 		RetryCount: 0,
 
 		// Additional metadata
-		Metadata:    fmt.Sprintf(`{"tags":%s}`, strings.Join(note.Tags, ",")),
+		Metadata:    string(metadata),
 		ExtractFrom: "web",
+		RawData:     raw,
+	}
+}
+
+// renditions lists the encodings of a video note: each media stream and
+// the plain play address
+func renditions(note *XHSNote) []models.Rendition {
+	if note.Type != "video" {
+		return nil
+	}
+
+	var list []models.Rendition
+	for _, stream := range note.Video.Streams {
+		if stream.MasterURL == "" {
+			continue
+		}
+		list = append(list, models.Rendition{
+			URL:     stream.MasterURL,
+			Width:   stream.Width,
+			Height:  stream.Height,
+			Bitrate: stream.AvgBitrate,
+			Codec:   stream.Codec,
+			Format:  stream.Format,
+			Size:    stream.Size,
+			Label:   stream.QualityType,
+		})
+	}
+
+	if note.Video.PlayAddr != "" {
+		list = append(list, models.Rendition{
+			URL:    note.Video.PlayAddr,
+			Width:  note.Video.Width,
+			Height: note.Video.Height,
+			Format: "mp4",
+			Label:  "play",
+		})
+	}
+
+	return list
+}
+
+// imageItems returns the images of a note in order. Each item points at
+// the original upload, with the watermarked display copy as fallback.
+func imageItems(images []XHSImage) []models.MediaItem {
+	items := make([]models.MediaItem, 0, len(images))
+	for i, img := range images {
+		display := displayImageURL(img)
+		item := models.MediaItem{
+			Index:     i,
+			MediaType: models.MediaTypeImage,
+			URL:       display,
+			Width:     img.Width,
+			Height:    img.Height,
+		}
+
+		if original := originalImageURL(display); original != "" && original != display {
+			item.URL = original
+			item.FallbackURL = display
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// displayImageURL returns the full-size display URL of an image; URL is
+// often only the preview
+func displayImageURL(img XHSImage) string {
+	if img.URLDefault != "" {
+		return img.URLDefault
+	}
+	return img.URL
+}
+
+// originalImageURL derives the original, watermark-free upload from a CDN
+// display URL such as
+// https://sns-webpic-qc.xhscdn.com/202403211626/c4fcec.../1040g008...!nd_dft_wlteh_webp_3
+// by taking the image token and requesting it from the image origin. It
+// returns "" when the URL does not carry a token.
+func originalImageURL(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+
+	// Drop the timestamp and signature segments of signed URLs
+	if parts := strings.SplitN(path, "/", 3); len(parts) == 3 && isDigits(parts[0]) {
+		path = parts[2]
+	}
+
+	token, _, _ := strings.Cut(path, "!")
+	if token == "" {
+		return ""
+	}
+
+	return "https://ci.xiaohongshu.com/" + token
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
 	}
+	return true
 }
 
 // convertToAuthorInfo converts XHSUser to AuthorInfo
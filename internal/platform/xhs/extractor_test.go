@@ -0,0 +1,49 @@
+package xhs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"video-downloader/internal/platform/platformtest"
+	"video-downloader/pkg/models"
+)
+
+func newTestExtractor(t *testing.T, fixture string) *xhsExtractor {
+	return NewExtractor(&models.ExtractorConfig{
+		Transport: platformtest.NewTransport(t, filepath.Join("testdata", fixture)),
+	})
+}
+
+func TestExtractVideoInfo(t *testing.T) {
+	tests := []struct {
+		fixture string
+		url     string
+		golden  string
+	}{
+		{"video_note", "https://www.xiaohongshu.com/explore/65f1a2b3000000001203abcd", "video_note"},
+		{"image_note", "https://www.xiaohongshu.com/explore/65f1a2b3000000001203ef01", "image_note"},
+		// Short links resolve to the same note as the video fixture
+		{"short_link", "https://xhslink.com/a/Xy12Ab", "video_note"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			info, err := newTestExtractor(t, tt.fixture).ExtractVideoInfo(context.Background(), tt.url)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			platformtest.AssertGolden(t, filepath.Join("testdata", tt.golden, "want.json"), platformtest.StableVideo(info))
+		})
+	}
+}
+
+func TestExtractAuthorInfo(t *testing.T) {
+	info, err := newTestExtractor(t, "author").ExtractAuthorInfo(context.Background(), "5f0e1d2c000000000101abcd")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	platformtest.AssertGolden(t, filepath.Join("testdata", "author", "want.json"), platformtest.StableAuthor(info))
+}
@@ -0,0 +1,263 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Operation is the kind of work needed to turn one format into another
+type Operation string
+
+const (
+	OperationNone         Operation = "none"
+	OperationRemux        Operation = "remux"
+	OperationExtractAudio Operation = "extract_audio"
+	OperationTranscode    Operation = "transcode"
+)
+
+// ErrFFmpegNotFound is returned when the configured ffmpeg binary cannot be
+// found
+var ErrFFmpegNotFound = errors.New("ffmpeg not found")
+
+// videoFormats maps video containers to the codec arguments used when the
+// streams have to be re-encoded. Containers with remux set can take the
+// H.264/AAC streams the platforms serve without re-encoding.
+var videoFormats = map[string]struct {
+	remux     bool
+	transcode []string
+}{
+	"mp4":  {true, []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-c:a", "aac", "-b:a", "160k"}},
+	"mov":  {true, []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-c:a", "aac", "-b:a", "160k"}},
+	"mkv":  {true, []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-c:a", "aac", "-b:a", "160k"}},
+	"ts":   {true, []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-c:a", "aac", "-b:a", "160k"}},
+	"flv":  {true, []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-c:a", "aac", "-b:a", "160k"}},
+	"webm": {false, []string{"-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "32", "-c:a", "libopus", "-b:a", "128k"}},
+	"avi":  {false, []string{"-c:v", "mpeg4", "-q:v", "3", "-c:a", "libmp3lame", "-q:a", "2"}},
+}
+
+// audioFormats maps audio formats to their encoder arguments. Formats with
+// copy set try to keep the source AAC stream first.
+var audioFormats = map[string]struct {
+	copy   bool
+	encode []string
+}{
+	"mp3":  {false, []string{"-c:a", "libmp3lame", "-q:a", "2"}},
+	"m4a":  {true, []string{"-c:a", "aac", "-b:a", "192k"}},
+	"aac":  {true, []string{"-c:a", "aac", "-b:a", "192k"}},
+	"opus": {false, []string{"-c:a", "libopus", "-b:a", "128k"}},
+	"ogg":  {false, []string{"-c:a", "libvorbis", "-q:a", "5"}},
+	"flac": {false, []string{"-c:a", "flac"}},
+	"wav":  {false, []string{"-c:a", "pcm_s16le"}},
+}
+
+// Config holds post-processing configuration
+type Config struct {
+	// FFmpegPath is the ffmpeg binary, looked up in PATH when it has no
+	// directory
+	FFmpegPath string
+}
+
+// Processor converts downloaded media with ffmpeg
+type Processor struct {
+	binary string
+	logger zerolog.Logger
+}
+
+// NewProcessor creates a new processor
+func NewProcessor(config Config) *Processor {
+	if config.FFmpegPath == "" {
+		config.FFmpegPath = "ffmpeg"
+	}
+
+	return &Processor{
+		binary: config.FFmpegPath,
+		logger: zerolog.New(os.Stdout).With().Timestamp().Logger(),
+	}
+}
+
+// Available reports whether the ffmpeg binary can be run
+func (p *Processor) Available() bool {
+	_, err := exec.LookPath(p.binary)
+	return err == nil
+}
+
+// IsAudioFormat reports whether format is an audio-only format
+func IsAudioFormat(format string) bool {
+	_, ok := audioFormats[normalize(format)]
+	return ok
+}
+
+// IsSupportedFormat reports whether Convert can produce format
+func IsSupportedFormat(format string) bool {
+	format = normalize(format)
+	_, video := videoFormats[format]
+	_, audio := audioFormats[format]
+	return video || audio
+}
+
+// Plan returns the operation that converts from one format to another
+func Plan(from, to string) (Operation, error) {
+	from, to = normalize(from), normalize(to)
+
+	if from == to {
+		return OperationNone, nil
+	}
+	if _, ok := audioFormats[to]; ok {
+		return OperationExtractAudio, nil
+	}
+
+	video, ok := videoFormats[to]
+	if !ok {
+		return "", fmt.Errorf("unsupported output format: %s", to)
+	}
+	if _, ok := audioFormats[from]; ok {
+		return "", fmt.Errorf("cannot convert audio %s to video %s", from, to)
+	}
+	if video.remux {
+		return OperationRemux, nil
+	}
+	return OperationTranscode, nil
+}
+
+// Convert writes input to output in the format given by the output
+// extension and returns the operation it performed. Remuxing and AAC copies
+// fall back to re-encoding when ffmpeg rejects the source streams. The
+// output is written under a temporary name and renamed once complete, so a
+// failed conversion never leaves a truncated file behind.
+func (p *Processor) Convert(ctx context.Context, input, output string) (Operation, error) {
+	from, to := extension(input), extension(output)
+
+	op, err := Plan(from, to)
+	if err != nil {
+		return "", err
+	}
+	if op == OperationNone {
+		if input != output {
+			return op, os.Rename(input, output)
+		}
+		return op, nil
+	}
+
+	if !p.Available() {
+		return "", fmt.Errorf("%w: %s", ErrFFmpegNotFound, p.binary)
+	}
+
+	var attempts [][]string
+	switch op {
+	case OperationRemux:
+		attempts = [][]string{{"-c", "copy"}, videoFormats[to].transcode}
+	case OperationTranscode:
+		attempts = [][]string{videoFormats[to].transcode}
+	case OperationExtractAudio:
+		audio := audioFormats[to]
+		if audio.copy {
+			attempts = append(attempts, []string{"-vn", "-c:a", "copy"})
+		}
+		attempts = append(attempts, append([]string{"-vn"}, audio.encode...))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	temp := strings.TrimSuffix(output, filepath.Ext(output)) + ".part" + filepath.Ext(output)
+	defer os.Remove(temp)
+
+	for i, codecArgs := range attempts {
+		err = p.run(ctx, input, temp, to, codecArgs)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if i < len(attempts)-1 {
+			p.logger.Warn().Err(err).Str("input", input).Str("format", to).Msg("Stream copy failed, re-encoding")
+			op = OperationTranscode
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(temp, output); err != nil {
+		return "", fmt.Errorf("failed to move converted file: %w", err)
+	}
+
+	p.logger.Info().Str("input", input).Str("output", output).Str("operation", string(op)).Msg("Converted media")
+	return op, nil
+}
+
+// ConvertImage re-encodes an image to the format given by the output
+// extension, such as a WebP thumbnail to JPEG
+func (p *Processor) ConvertImage(ctx context.Context, input, output string) error {
+	if !p.Available() {
+		return fmt.Errorf("%w: %s", ErrFFmpegNotFound, p.binary)
+	}
+
+	temp := strings.TrimSuffix(output, filepath.Ext(output)) + ".part" + filepath.Ext(output)
+	defer os.Remove(temp)
+
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", input, "-frames:v", "1", "-update", "1", temp}
+	if err := p.exec(ctx, args); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, output); err != nil {
+		return fmt.Errorf("failed to move converted image: %w", err)
+	}
+
+	return nil
+}
+
+// run invokes ffmpeg once
+func (p *Processor) run(ctx context.Context, input, output, format string, codecArgs []string) error {
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", input}
+	args = append(args, codecArgs...)
+	if format == "mp4" || format == "mov" || format == "m4a" {
+		args = append(args, "-movflags", "+faststart")
+	}
+	args = append(args, output)
+
+	return p.exec(ctx, args)
+}
+
+// exec runs ffmpeg with args, reporting its last error line on failure
+func (p *Processor) exec(ctx context.Context, args []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.binary, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return nil
+}
+
+// extension returns the lower-case extension of path without the dot
+func extension(path string) string {
+	return normalize(filepath.Ext(path))
+}
+
+// normalize lower-cases a format and strips a leading dot
+func normalize(format string) string {
+	return strings.ToLower(strings.TrimPrefix(format, "."))
+}
+
+// lastLine returns the last non-empty line of ffmpeg's error output
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
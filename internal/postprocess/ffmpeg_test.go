@@ -0,0 +1,35 @@
+package postprocess
+
+import "testing"
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     Operation
+	}{
+		{"mp4", "MP4", OperationNone},
+		{"ts", "mp4", OperationRemux},
+		{"mp4", "mkv", OperationRemux},
+		{"mp4", "mp3", OperationExtractAudio},
+		{"ts", ".m4a", OperationExtractAudio},
+		{"mp4", "webm", OperationTranscode},
+	}
+
+	for _, tt := range tests {
+		got, err := Plan(tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Plan(%q, %q): unexpected error: %v", tt.from, tt.to, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Plan(%q, %q) = %s, want %s", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	if _, err := Plan("mp4", "exe"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+	if _, err := Plan("mp3", "mp4"); err == nil {
+		t.Error("Expected error converting audio to video")
+	}
+}
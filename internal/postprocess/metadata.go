@@ -0,0 +1,118 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// coverFormats are the containers ffmpeg can store cover art in as an
+// attached picture
+var coverFormats = map[string]bool{
+	"mp4":  true,
+	"mov":  true,
+	"m4a":  true,
+	"mp3":  true,
+	"flac": true,
+}
+
+// Metadata holds the tags written into a media file. Empty fields are left
+// out.
+type Metadata struct {
+	Title       string
+	Artist      string
+	Description string
+	Date        string // YYYY-MM-DD
+	Comment     string
+	// EpisodeID identifies the item on its platform, such as "tiktok:123"
+	EpisodeID string
+	// Cover is an image file embedded as cover art when the container
+	// supports it
+	Cover string
+}
+
+// args returns the ffmpeg -metadata arguments for m
+func (m Metadata) args() []string {
+	tags := []struct{ key, value string }{
+		{"title", m.Title},
+		{"artist", m.Artist},
+		{"description", m.Description},
+		{"date", m.Date},
+		{"comment", m.Comment},
+		{"episode_id", m.EpisodeID},
+	}
+
+	var args []string
+	for _, tag := range tags {
+		if value := strings.TrimSpace(tag.value); value != "" {
+			args = append(args, "-metadata", tag.key+"="+value)
+		}
+	}
+	return args
+}
+
+// SupportsCover reports whether cover art can be embedded in format
+func SupportsCover(format string) bool {
+	return coverFormats[normalize(format)]
+}
+
+// EmbedMetadata writes tags and cover art into the file at path in place.
+// MP4 files get iTunes-style atoms and MP3 files ID3v2 frames; streams are
+// copied, only the cover is encoded to JPEG.
+func (p *Processor) EmbedMetadata(ctx context.Context, path string, metadata Metadata) error {
+	if !p.Available() {
+		return fmt.Errorf("%w: %s", ErrFFmpegNotFound, p.binary)
+	}
+
+	format := extension(path)
+	audio := IsAudioFormat(format)
+	cover := metadata.Cover != "" && SupportsCover(format)
+
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", path}
+	if cover {
+		args = append(args, "-i", metadata.Cover)
+	}
+
+	// Audio files keep only their audio so an existing picture is replaced
+	if audio {
+		args = append(args, "-map", "0:a")
+	} else {
+		args = append(args, "-map", "0")
+	}
+	args = append(args, "-c", "copy")
+
+	if cover {
+		// The cover follows the source video streams; the platforms serve a
+		// single video stream
+		coverStream := "v:1"
+		if audio {
+			coverStream = "v:0"
+		}
+		args = append(args, "-map", "1:v:0", "-c:"+coverStream, "mjpeg", "-disposition:"+coverStream, "attached_pic")
+	}
+
+	args = append(args, "-map_metadata", "0")
+	args = append(args, metadata.args()...)
+
+	switch format {
+	case "mp3":
+		args = append(args, "-id3v2_version", "3")
+	case "mp4", "mov", "m4a":
+		args = append(args, "-movflags", "+faststart")
+	}
+
+	temp := strings.TrimSuffix(path, filepath.Ext(path)) + ".part" + filepath.Ext(path)
+	defer os.Remove(temp)
+
+	if err := p.exec(ctx, append(args, temp)); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
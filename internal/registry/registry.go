@@ -1,11 +1,13 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
 
 	"video-downloader/internal/platform"
+	"video-downloader/internal/utils"
 	"video-downloader/pkg/models"
 )
 
@@ -41,8 +43,10 @@ func (r *Registry) RegisterExtractor(platform models.Platform, extractor models.
 	return nil
 }
 
-// RegisterDefaultPlatforms registers all supported platforms with default configurations
-func (r *Registry) RegisterDefaultPlatforms(config *models.Config) error {
+// RegisterDefaultPlatforms registers all supported platforms with default
+// configurations. The extractors send their requests through the shared
+// transport of transports; nil gives each extractor a transport of its own.
+func (r *Registry) RegisterDefaultPlatforms(config *models.Config, transports *utils.TransportFactory) error {
 	// Register TikTok
 	if config.Platforms.TikTok.Enabled {
 		tiktokExtractor := platform.NewTikTokExtractor(&models.ExtractorConfig{
@@ -51,6 +55,7 @@ func (r *Registry) RegisterDefaultPlatforms(config *models.Config) error {
 			UserAgent:  config.Platforms.TikTok.UserAgent,
 			Cookie:     config.Platforms.TikTok.Cookie,
 			MaxRetries: 3,
+			Transport:  transports.Transport(),
 		})
 
 		tiktokPatterns := []string{
@@ -73,6 +78,7 @@ func (r *Registry) RegisterDefaultPlatforms(config *models.Config) error {
 			UserAgent:  config.Platforms.XHS.UserAgent,
 			Cookie:     config.Platforms.XHS.Cookie,
 			MaxRetries: 3,
+			Transport:  transports.Transport(),
 		})
 
 		xhsPatterns := []string{
@@ -95,6 +101,7 @@ func (r *Registry) RegisterDefaultPlatforms(config *models.Config) error {
 			UserAgent:  config.Platforms.Kuaishou.UserAgent,
 			Cookie:     config.Platforms.Kuaishou.Cookie,
 			MaxRetries: 3,
+			Transport:  transports.Transport(),
 		})
 
 		kuaishouPatterns := []string{
@@ -136,6 +143,36 @@ func (r *Registry) GetExtractorForURL(url string) (models.PlatformExtractor, mod
 	return extractor, platform, nil
 }
 
+// ExtractVideoInfo resolves the extractor for url and extracts video information
+func (r *Registry) ExtractVideoInfo(ctx context.Context, url string) (*models.VideoInfo, error) {
+	extractor, _, err := r.GetExtractorForURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractor.ExtractVideoInfo(ctx, url)
+}
+
+// ExtractBatch resolves the extractor for url and extracts up to limit videos
+func (r *Registry) ExtractBatch(ctx context.Context, url string, limit int) ([]*models.VideoInfo, error) {
+	extractor, _, err := r.GetExtractorForURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractor.ExtractBatch(ctx, url, limit)
+}
+
+// ExtractAuthorInfo extracts author information using the platform's extractor
+func (r *Registry) ExtractAuthorInfo(ctx context.Context, platform models.Platform, authorID string) (*models.AuthorInfo, error) {
+	extractor, err := r.GetExtractor(platform)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractor.ExtractAuthorInfo(ctx, authorID)
+}
+
 // DetectPlatform detects the platform from URL
 func (r *Registry) DetectPlatform(url string) (models.Platform, error) {
 	// Try to match against registered patterns
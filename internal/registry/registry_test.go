@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"testing"
 
 	"video-downloader/pkg/models"
@@ -11,7 +12,7 @@ type MockExtractor struct {
 	platform models.Platform
 }
 
-func (m *MockExtractor) ExtractVideoInfo(url string) (*models.VideoInfo, error) {
+func (m *MockExtractor) ExtractVideoInfo(ctx context.Context, url string) (*models.VideoInfo, error) {
 	return &models.VideoInfo{
 		ID:        "test_id",
 		Platform:  m.platform,
@@ -22,7 +23,7 @@ func (m *MockExtractor) ExtractVideoInfo(url string) (*models.VideoInfo, error)
 	}, nil
 }
 
-func (m *MockExtractor) ExtractAuthorInfo(authorID string) (*models.AuthorInfo, error) {
+func (m *MockExtractor) ExtractAuthorInfo(ctx context.Context, authorID string) (*models.AuthorInfo, error) {
 	return &models.AuthorInfo{
 		ID:       authorID,
 		Platform: m.platform,
@@ -30,7 +31,7 @@ func (m *MockExtractor) ExtractAuthorInfo(authorID string) (*models.AuthorInfo,
 	}, nil
 }
 
-func (m *MockExtractor) ExtractBatch(url string, limit int) ([]*models.VideoInfo, error) {
+func (m *MockExtractor) ExtractBatch(ctx context.Context, url string, limit int) ([]*models.VideoInfo, error) {
 	return []*models.VideoInfo{
 		{
 			ID:        "batch_id_1",
@@ -5,6 +5,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,43 +19,68 @@ import (
 
 // ResumableDownloader handles resumable downloads with metadata tracking
 type ResumableDownloader struct {
-	client     *http.Client
-	logger     zerolog.Logger
-	metaDir    string
-	tempDir    string
-	chunkSize  int64
-	maxRetries int
-	timeout    time.Duration
-	activeJobs map[string]*ResumableJob
-	jobsMutex  sync.RWMutex
+	client      *http.Client
+	logger      zerolog.Logger
+	metaDir     string
+	tempDir     string
+	chunkSize   int64
+	connections int
+	maxRetries  int
+	timeout     time.Duration
+	activeJobs  map[string]*ResumableJob
+	jobsMutex   sync.RWMutex
 }
 
 // ResumableJob represents a resumable download job
 type ResumableJob struct {
-	ID           string                `json:"id"`
-	URL          string                `json:"url"`
-	FilePath     string                `json:"file_path"`
-	TempPath     string                `json:"temp_path"`
-	MetaPath     string                `json:"meta_path"`
-	FileSize     int64                 `json:"file_size"`
-	Downloaded   int64                 `json:"downloaded"`
-	Status       string                `json:"status"`
-	CreatedAt    time.Time             `json:"created_at"`
-	UpdatedAt    time.Time             `json:"updated_at"`
-	CompletedAt  *time.Time            `json:"completed_at"`
-	Headers      map[string]string     `json:"headers"`
-	Checksum     string                `json:"checksum"`
-	RetryCount   int                   `json:"retry_count"`
-	LastError    string                `json:"last_error"`
-	Progress     float64               `json:"progress"`
-	Speed        float64               `json:"speed"`
-	ETA          time.Duration         `json:"eta"`
+	ID           string            `json:"id"`
+	URL          string            `json:"url"`
+	FilePath     string            `json:"file_path"`
+	TempPath     string            `json:"temp_path"`
+	MetaPath     string            `json:"meta_path"`
+	FileSize     int64             `json:"file_size"`
+	Downloaded   int64             `json:"downloaded"`
+	Status       string            `json:"status"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	CompletedAt  *time.Time        `json:"completed_at"`
+	Headers      map[string]string `json:"headers"`
+	Checksum     string            `json:"checksum"`
+	RetryCount   int               `json:"retry_count"`
+	LastError    string            `json:"last_error"`
+	Progress     float64           `json:"progress"`
+	Speed        float64           `json:"speed"`
+	ETA          time.Duration     `json:"eta"`
+	AcceptRanges bool              `json:"accept_ranges"`
+	Ranges       []*ByteRange      `json:"ranges,omitempty"`
+	// ETag and LastModified identify the version of the file the bytes on
+	// disk came from
+	ETag         string                `json:"etag,omitempty"`
+	LastModified string                `json:"last_modified,omitempty"`
 	ctx          context.Context       `json:"-"`
 	cancel       context.CancelFunc    `json:"-"`
 	progressChan chan<- ProgressUpdate `json:"-"`
 	mutex        sync.RWMutex          `json:"-"`
+	speedTime    time.Time
+	speedBytes   int64
 }
 
+// ByteRange is the share of a ranged download fetched over one connection
+type ByteRange struct {
+	Start      int64 `json:"start"`
+	End        int64 `json:"end"` // inclusive
+	Downloaded int64 `json:"downloaded"`
+}
+
+// remaining returns the number of bytes still to fetch
+func (r *ByteRange) remaining() int64 {
+	return r.End - r.Start + 1 - r.Downloaded
+}
+
+// errRangeNotSupported means the server answered a range request with the
+// whole file
+var errRangeNotSupported = errors.New("server does not support range requests")
+
 // ProgressUpdate represents a progress update
 type ProgressUpdate struct {
 	JobID      string
@@ -68,12 +94,17 @@ type ProgressUpdate struct {
 
 // ResumableConfig holds configuration for resumable downloader
 type ResumableConfig struct {
-	MetaDir    string
-	TempDir    string
-	ChunkSize  int64
-	MaxRetries int
-	Timeout    time.Duration
-	UserAgent  string
+	MetaDir   string
+	TempDir   string
+	ChunkSize int64
+	// Connections is the number of parallel ranged requests per file
+	Connections int
+	MaxRetries  int
+	Timeout     time.Duration
+	UserAgent   string
+	// Transport, when set, carries the download requests instead of the
+	// default transport
+	Transport http.RoundTripper
 }
 
 // NewResumableDownloader creates a new resumable downloader
@@ -87,6 +118,9 @@ func NewResumableDownloader(config ResumableConfig) *ResumableDownloader {
 	if config.ChunkSize == 0 {
 		config.ChunkSize = 1024 * 1024 // 1MB
 	}
+	if config.Connections <= 0 {
+		config.Connections = 1
+	}
 	if config.MaxRetries == 0 {
 		config.MaxRetries = 3
 	}
@@ -95,18 +129,20 @@ func NewResumableDownloader(config ResumableConfig) *ResumableDownloader {
 	}
 
 	client := &http.Client{
-		Timeout: config.Timeout,
+		Transport: config.Transport,
+		Timeout:   config.Timeout,
 	}
 
 	rd := &ResumableDownloader{
-		client:     client,
-		logger:     zerolog.New(nil).With().Str("component", "resumable_downloader").Logger(),
-		metaDir:    config.MetaDir,
-		tempDir:    config.TempDir,
-		chunkSize:  config.ChunkSize,
-		maxRetries: config.MaxRetries,
-		timeout:    config.Timeout,
-		activeJobs: make(map[string]*ResumableJob),
+		client:      client,
+		logger:      zerolog.New(nil).With().Str("component", "resumable_downloader").Logger(),
+		metaDir:     config.MetaDir,
+		tempDir:     config.TempDir,
+		chunkSize:   config.ChunkSize,
+		connections: config.Connections,
+		maxRetries:  config.MaxRetries,
+		timeout:     config.Timeout,
+		activeJobs:  make(map[string]*ResumableJob),
 	}
 
 	// Create directories
@@ -164,6 +200,75 @@ func (rd *ResumableDownloader) StartDownload(url, filePath string, headers map[s
 	return job, nil
 }
 
+// Download runs a resumable download in the calling goroutine and returns
+// once it completes, fails or ctx is cancelled. A job left over from an
+// earlier run for the same URL and path continues from the bytes already
+// on disk.
+func (rd *ResumableDownloader) Download(ctx context.Context, url, filePath string, headers map[string]string, progressChan chan<- ProgressUpdate) error {
+	jobID := rd.generateJobID(url, filePath)
+
+	rd.jobsMutex.Lock()
+	job, exists := rd.activeJobs[jobID]
+	if exists {
+		// Jobs loaded from metadata have no context; only one started in
+		// this process can still be running
+		job.mutex.Lock()
+		running := job.ctx != nil && job.ctx.Err() == nil
+		job.mutex.Unlock()
+		if running {
+			rd.jobsMutex.Unlock()
+			return fmt.Errorf("download already in progress: %s", jobID)
+		}
+	} else {
+		job = &ResumableJob{
+			ID:        jobID,
+			URL:       url,
+			FilePath:  filePath,
+			TempPath:  filepath.Join(rd.tempDir, jobID+".tmp"),
+			MetaPath:  filepath.Join(rd.metaDir, jobID+".json"),
+			Status:    "pending",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		rd.activeJobs[jobID] = job
+	}
+
+	job.mutex.Lock()
+	job.Headers = headers
+	job.progressChan = progressChan
+	job.ctx, job.cancel = context.WithCancel(ctx)
+	cancel := job.cancel
+	job.mutex.Unlock()
+	rd.jobsMutex.Unlock()
+
+	defer cancel()
+
+	rd.downloadJob(job)
+
+	job.mutex.Lock()
+	if job.Status == "downloading" || job.Status == "initializing" {
+		// Aborted between attempts
+		job.Status = "paused"
+	}
+	status, lastError := job.Status, job.LastError
+	job.progressChan = nil
+	job.mutex.Unlock()
+
+	switch {
+	case status == "completed":
+		rd.jobsMutex.Lock()
+		delete(rd.activeJobs, jobID)
+		rd.jobsMutex.Unlock()
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case lastError != "":
+		return errors.New(lastError)
+	default:
+		return fmt.Errorf("download ended in state %s", status)
+	}
+}
+
 // ResumeDownload resumes a paused download
 func (rd *ResumableDownloader) ResumeDownload(jobID string, progressChan chan<- ProgressUpdate) (*ResumableJob, error) {
 	rd.jobsMutex.RLock()
@@ -214,10 +319,13 @@ func (rd *ResumableDownloader) downloadJob(job *ResumableJob) {
 	// Save initial metadata
 	rd.saveJobMetadata(job)
 
-	// Get file information
+	// Get file information; some CDNs reject HEAD, so carry on without a
+	// known size rather than failing the job
 	if err := rd.getFileInfo(job); err != nil {
-		rd.handleJobError(job, fmt.Errorf("failed to get file info: %w", err))
-		return
+		if job.ctx.Err() != nil {
+			return
+		}
+		rd.logger.Warn().Err(err).Str("job_id", job.ID).Msg("Failed to get file info")
 	}
 
 	// Check if file already exists
@@ -321,15 +429,225 @@ func (rd *ResumableDownloader) getFileInfo(job *ResumableJob) error {
 	}
 
 	// Check if server supports range requests
-	if resp.Header.Get("Accept-Ranges") != "bytes" {
+	acceptRanges := resp.Header.Get("Accept-Ranges") == "bytes"
+	job.mutex.Lock()
+	job.AcceptRanges = acceptRanges
+	job.mutex.Unlock()
+
+	rd.checkValidators(job, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"))
+	if !acceptRanges {
 		rd.logger.Warn().Str("job_id", job.ID).Msg("Server does not support range requests")
 	}
 
 	return nil
 }
 
-// performDownload performs the actual file download
+// checkValidators records the ETag and Last-Modified of the file on the
+// server. If either differs from the one recorded when the bytes on disk
+// were fetched, the file has changed and the download starts over.
+func (rd *ResumableDownloader) checkValidators(job *ResumableJob, etag, lastModified string) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	changed := (job.ETag != "" && etag != "" && job.ETag != etag) ||
+		(job.LastModified != "" && lastModified != "" && job.LastModified != lastModified)
+	if changed {
+		rd.logger.Warn().Str("job_id", job.ID).Msg("File changed on the server, restarting download")
+		job.Ranges = nil
+		job.Downloaded = 0
+		if err := os.Remove(job.TempPath); err != nil && !os.IsNotExist(err) {
+			rd.logger.Warn().Err(err).Str("job_id", job.ID).Msg("Failed to remove temp file")
+		}
+	}
+
+	job.ETag = etag
+	job.LastModified = lastModified
+}
+
+// performDownload performs the actual file download, over several ranged
+// connections when the server allows it
 func (rd *ResumableDownloader) performDownload(job *ResumableJob) error {
+	job.mutex.Lock()
+	if n := len(job.Ranges); n > 0 && job.Ranges[n-1].End != job.FileSize-1 {
+		// The file changed size since the ranges were planned
+		rd.logger.Warn().Str("job_id", job.ID).Msg("File size changed, restarting download")
+		job.Ranges = nil
+		job.Downloaded = 0
+		os.Remove(job.TempPath)
+	}
+	if len(job.Ranges) == 0 {
+		job.Ranges = rd.planRanges(job)
+	}
+	ranged := len(job.Ranges) > 0
+	job.mutex.Unlock()
+
+	if ranged {
+		err := rd.performRangedDownload(job)
+		if !errors.Is(err, errRangeNotSupported) {
+			return err
+		}
+
+		// Fall back to a single connection from scratch
+		rd.logger.Warn().Str("job_id", job.ID).Msg("Range not honoured, falling back to a single connection")
+		job.mutex.Lock()
+		job.Ranges = nil
+		job.AcceptRanges = false
+		job.Downloaded = 0
+		job.mutex.Unlock()
+		if err := os.Remove(job.TempPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove temp file: %w", err)
+		}
+		rd.saveJobMetadata(job)
+	}
+
+	return rd.performSingleDownload(job)
+}
+
+// planRanges splits a new download into one range per connection, each at
+// least a chunk long. It returns nil when the download should use a single
+// connection. Must be called with the job mutex held.
+func (rd *ResumableDownloader) planRanges(job *ResumableJob) []*ByteRange {
+	if rd.connections <= 1 || !job.AcceptRanges || job.FileSize < 2*rd.chunkSize {
+		return nil
+	}
+
+	// A partial single-connection download keeps going as it was
+	if stat, err := os.Stat(job.TempPath); err == nil && stat.Size() > 0 {
+		return nil
+	}
+
+	count := min(int64(rd.connections), job.FileSize/rd.chunkSize)
+	size := job.FileSize / count
+
+	ranges := make([]*ByteRange, 0, count)
+	for i := int64(0); i < count; i++ {
+		r := &ByteRange{Start: i * size, End: (i+1)*size - 1}
+		if i == count-1 {
+			r.End = job.FileSize - 1
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// performRangedDownload fetches the unfinished ranges of a job in
+// parallel, writing each at its offset in the temp file
+func (rd *ResumableDownloader) performRangedDownload(job *ResumableJob) error {
+	file, err := os.OpenFile(job.TempPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open temp file: %w", err)
+	}
+	defer file.Close()
+
+	job.mutex.Lock()
+	fileSize := job.FileSize
+	ranges := job.Ranges
+	job.Downloaded = 0
+	for _, r := range ranges {
+		job.Downloaded += r.Downloaded
+	}
+	job.mutex.Unlock()
+
+	if err := file.Truncate(fileSize); err != nil {
+		return fmt.Errorf("failed to allocate temp file: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	var errs []error
+	var errsMutex sync.Mutex
+
+	for _, r := range ranges {
+		job.mutex.RLock()
+		done := r.remaining() <= 0
+		job.mutex.RUnlock()
+		if done {
+			continue
+		}
+
+		wg.Add(1)
+		go func(r *ByteRange) {
+			defer wg.Done()
+
+			if err := rd.downloadRange(job, file, r); err != nil {
+				errsMutex.Lock()
+				errs = append(errs, err)
+				errsMutex.Unlock()
+			}
+		}(r)
+	}
+
+	wg.Wait()
+	rd.saveJobMetadata(job)
+
+	if job.ctx.Err() != nil {
+		return job.ctx.Err()
+	}
+
+	return errors.Join(errs...)
+}
+
+// downloadRange fetches the rest of one range
+func (rd *ResumableDownloader) downloadRange(job *ResumableJob, file *os.File, r *ByteRange) error {
+	job.mutex.RLock()
+	start := r.Start + r.Downloaded
+	job.mutex.RUnlock()
+
+	req, err := http.NewRequestWithContext(job.ctx, "GET", job.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create GET request: %w", err)
+	}
+
+	for key, value := range job.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, r.End))
+
+	resp, err := rd.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return errRangeNotSupported
+	default:
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := checkContentRange(resp.Header.Get("Content-Range"), start); err != nil {
+		return err
+	}
+
+	pw := &progressWriter{
+		writer: io.NewOffsetWriter(file, start),
+		job:    job,
+		rd:     rd,
+		rng:    r,
+	}
+
+	if _, err := io.Copy(pw, io.LimitReader(resp.Body, r.End-start+1)); err != nil {
+		if job.ctx.Err() != nil {
+			return job.ctx.Err()
+		}
+		return fmt.Errorf("failed to download range %d-%d: %w", r.Start, r.End, err)
+	}
+
+	job.mutex.RLock()
+	remaining := r.remaining()
+	job.mutex.RUnlock()
+	if remaining > 0 {
+		return fmt.Errorf("range %d-%d ended %d bytes early", r.Start, r.End, remaining)
+	}
+
+	return nil
+}
+
+// performSingleDownload downloads over one connection, appending to the
+// temp file
+func (rd *ResumableDownloader) performSingleDownload(job *ResumableJob) error {
 	// Check current downloaded size
 	var startByte int64
 	if stat, err := os.Stat(job.TempPath); err == nil {
@@ -368,12 +686,24 @@ func (rd *ResumableDownloader) performDownload(job *ResumableJob) error {
 		expectedStatus = http.StatusPartialContent
 	}
 
-	if resp.StatusCode != expectedStatus {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if startByte > 0 && resp.StatusCode == http.StatusOK {
+		// Server ignored the range, start over
+		rd.logger.Warn().Str("job_id", job.ID).Msg("Range not honoured, restarting download")
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		job.mutex.Lock()
+		job.Downloaded = 0
+		job.mutex.Unlock()
+	} else if resp.StatusCode != expectedStatus {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	} else if startByte > 0 {
+		if err := checkContentRange(resp.Header.Get("Content-Range"), startByte); err != nil {
+			return err
+		}
 	}
 
 	// Open temp file for writing
-	file, err := os.OpenFile(job.TempPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, err := os.OpenFile(job.TempPath, flags, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open temp file: %w", err)
 	}
@@ -381,15 +711,17 @@ func (rd *ResumableDownloader) performDownload(job *ResumableJob) error {
 
 	// Create progress writer
 	pw := &progressWriter{
-		writer:   file,
-		job:      job,
-		rd:       rd,
-		lastTime: time.Now(),
+		writer: file,
+		job:    job,
+		rd:     rd,
 	}
 
 	// Copy data
 	_, err = io.Copy(pw, resp.Body)
-	if err != nil && job.ctx.Err() == nil {
+	if err != nil {
+		if job.ctx.Err() != nil {
+			return job.ctx.Err()
+		}
 		return fmt.Errorf("failed to download file: %w", err)
 	}
 
@@ -522,7 +854,9 @@ func (rd *ResumableDownloader) fileExistsAndValid(job *ResumableJob) bool {
 
 // saveJobMetadata saves job metadata to disk
 func (rd *ResumableDownloader) saveJobMetadata(job *ResumableJob) {
+	job.mutex.RLock()
 	data, err := json.MarshalIndent(job, "", "  ")
+	job.mutex.RUnlock()
 	if err != nil {
 		rd.logger.Error().Err(err).Str("job_id", job.ID).Msg("Failed to marshal job metadata")
 		return
@@ -643,12 +977,12 @@ func (rd *ResumableDownloader) DeleteJob(jobID string) error {
 
 // progressWriter wraps a writer to track progress
 type progressWriter struct {
-	writer      io.Writer
-	job         *ResumableJob
-	rd          *ResumableDownloader
-	written     int64
-	lastTime    time.Time
-	lastWritten int64
+	writer    io.Writer
+	job       *ResumableJob
+	rd        *ResumableDownloader
+	rng       *ByteRange // nil for single-connection downloads
+	written   int64
+	lastSaved int64
 }
 
 func (pw *progressWriter) Write(p []byte) (n int, err error) {
@@ -658,17 +992,22 @@ func (pw *progressWriter) Write(p []byte) (n int, err error) {
 
 		pw.job.mutex.Lock()
 		pw.job.Downloaded += int64(n)
+		if pw.rng != nil {
+			pw.rng.Downloaded += int64(n)
+		}
 
 		// Calculate progress
 		if pw.job.FileSize > 0 {
 			pw.job.Progress = float64(pw.job.Downloaded) / float64(pw.job.FileSize) * 100
 		}
 
-		// Calculate speed and ETA
+		// Calculate speed and ETA across all connections of the job
 		now := time.Now()
-		if now.Sub(pw.lastTime) >= time.Second {
-			elapsed := now.Sub(pw.lastTime)
-			bytes := pw.written - pw.lastWritten
+		if pw.job.speedTime.IsZero() {
+			pw.job.speedTime = now
+			pw.job.speedBytes = pw.job.Downloaded
+		} else if elapsed := now.Sub(pw.job.speedTime); elapsed >= time.Second {
+			bytes := pw.job.Downloaded - pw.job.speedBytes
 			pw.job.Speed = float64(bytes) / elapsed.Seconds()
 
 			if pw.job.Speed > 0 && pw.job.FileSize > 0 {
@@ -676,39 +1015,55 @@ func (pw *progressWriter) Write(p []byte) (n int, err error) {
 				pw.job.ETA = time.Duration(float64(remaining)/pw.job.Speed) * time.Second
 			}
 
-			pw.lastTime = now
-			pw.lastWritten = pw.written
+			pw.job.speedTime = now
+			pw.job.speedBytes = pw.job.Downloaded
 		}
 
 		pw.job.UpdatedAt = now
+		update := ProgressUpdate{
+			JobID:      pw.job.ID,
+			Progress:   pw.job.Progress,
+			Downloaded: pw.job.Downloaded,
+			FileSize:   pw.job.FileSize,
+			Speed:      pw.job.Speed,
+			ETA:        pw.job.ETA,
+			Status:     pw.job.Status,
+		}
+		progressChan := pw.job.progressChan
 		pw.job.mutex.Unlock()
 
 		// Send progress update
-		if pw.job.progressChan != nil {
+		if progressChan != nil {
 			select {
-			case pw.job.progressChan <- ProgressUpdate{
-				JobID:      pw.job.ID,
-				Progress:   pw.job.Progress,
-				Downloaded: pw.job.Downloaded,
-				FileSize:   pw.job.FileSize,
-				Speed:      pw.job.Speed,
-				ETA:        pw.job.ETA,
-				Status:     pw.job.Status,
-			}:
+			case progressChan <- update:
 			default:
 				// Don't block if channel is full
 			}
 		}
 
-		// Save metadata periodically
-		if pw.written%pw.rd.chunkSize == 0 {
+		// Save metadata every chunk, so a restart loses little
+		if pw.written-pw.lastSaved >= pw.rd.chunkSize {
 			pw.rd.saveJobMetadata(pw.job)
+			pw.lastSaved = pw.written
 		}
 	}
 
 	return n, err
 }
 
+// checkContentRange checks that a 206 response starts at the byte asked
+// for, so its body is not written at the wrong offset
+func checkContentRange(contentRange string, start int64) error {
+	var first, last int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d", &first, &last); err != nil {
+		return fmt.Errorf("invalid Content-Range %q: %w", contentRange, err)
+	}
+	if first != start {
+		return fmt.Errorf("server returned bytes from %d, requested %d", first, start)
+	}
+	return nil
+}
+
 // parseFileSize parses file size from string
 func parseFileSize(s string) (int64, error) {
 	var size int64
@@ -0,0 +1,370 @@
+package resume
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testChunkSize = 4096
+
+// rangeServer serves content with Range support and records the Range
+// header of each GET
+type rangeServer struct {
+	*httptest.Server
+	content []byte
+
+	// noRanges serves the whole file to every request and leaves out
+	// Accept-Ranges; ignoreRanges advertises ranges but answers with 200
+	noRanges     bool
+	ignoreRanges bool
+	// wrongOffset answers every range request with the start of the file
+	wrongOffset bool
+
+	mutex sync.Mutex
+	// stallAfter, when set, holds each response open after that many
+	// bytes until the client gives up
+	stallAfter int
+	// etag is sent with every response when set
+	etag   string
+	ranges []string
+}
+
+func newRangeServer(t *testing.T, size int) *rangeServer {
+	t.Helper()
+
+	s := &rangeServer{content: make([]byte, size)}
+	for i := range s.content {
+		s.content[i] = byte(i * 7 % 251)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rangeServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	content, etag := s.content, s.etag
+	s.mutex.Unlock()
+
+	if !s.noRanges {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		return
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	s.mutex.Lock()
+	s.ranges = append(s.ranges, rangeHeader)
+	stallAfter := s.stallAfter
+	s.mutex.Unlock()
+
+	start, end := 0, len(content)-1
+	status := http.StatusOK
+	if rangeHeader != "" && !s.noRanges && !s.ignoreRanges {
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil {
+			if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil {
+				http.Error(w, "bad range", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+		}
+		if s.wrongOffset {
+			end -= start
+			start = 0
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		status = http.StatusPartialContent
+	}
+
+	body := content[start : end+1]
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+
+	if stallAfter > 0 && stallAfter < len(body) {
+		w.Write(body[:stallAfter])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	}
+	w.Write(body)
+}
+
+// requestedRanges returns the Range headers received so far, sorted
+func (s *rangeServer) requestedRanges() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ranges := slices.Clone(s.ranges)
+	slices.Sort(ranges)
+	return ranges
+}
+
+func newTestDownloader(dir string, connections int) *ResumableDownloader {
+	return NewResumableDownloader(ResumableConfig{
+		MetaDir:     filepath.Join(dir, "meta"),
+		TempDir:     filepath.Join(dir, "temp"),
+		ChunkSize:   testChunkSize,
+		Connections: connections,
+		MaxRetries:  1,
+	})
+}
+
+func assertContent(t *testing.T, path string, want []byte) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected %d bytes, got %d different bytes", len(want), len(got))
+	}
+}
+
+func TestPlanRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileSize    int64
+		connections int
+		want        [][2]int64
+	}{
+		{"one range per connection", 8 * testChunkSize, 4, [][2]int64{{0, 8191}, {8192, 16383}, {16384, 24575}, {24576, 32767}}},
+		{"last range takes the rest", 3*testChunkSize + 10, 2, [][2]int64{{0, 6148}, {6149, 12297}}},
+		{"at least a chunk per range", 3 * testChunkSize, 8, [][2]int64{{0, 4095}, {4096, 8191}, {8192, 12287}}},
+		{"too small to split", 2*testChunkSize - 1, 4, nil},
+		{"single connection", 8 * testChunkSize, 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rd := newTestDownloader(t.TempDir(), tt.connections)
+			job := &ResumableJob{FileSize: tt.fileSize, AcceptRanges: true, TempPath: filepath.Join(t.TempDir(), "job.tmp")}
+
+			var got [][2]int64
+			for _, r := range rd.planRanges(job) {
+				got = append(got, [2]int64{r.Start, r.End})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected ranges %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRangedDownload(t *testing.T) {
+	server := newRangeServer(t, 10*testChunkSize+123)
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.mp4")
+
+	rd := newTestDownloader(dir, 4)
+	if err := rd.Download(context.Background(), server.URL+"/video.mp4", output, nil, nil); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	assertContent(t, output, server.content)
+
+	want := []string{"bytes=0-10269", "bytes=10270-20539", "bytes=20540-30809", "bytes=30810-41082"}
+	if got := server.requestedRanges(); !slices.Equal(got, want) {
+		t.Errorf("Expected ranges %v, got %v", want, got)
+	}
+}
+
+func TestRangedDownloadResume(t *testing.T) {
+	server := newRangeServer(t, 8*testChunkSize)
+	server.stallAfter = 1000
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.mp4")
+	url := server.URL + "/video.mp4"
+
+	rd := newTestDownloader(dir, 4)
+	jobID := rd.generateJobID(url, output)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- rd.Download(ctx, url, output, nil, nil) }()
+
+	// Cancel once every range has received its first bytes
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if job, ok := rd.GetJob(jobID); ok {
+			job.mutex.RLock()
+			downloaded := job.Downloaded
+			job.mutex.RUnlock()
+			if downloaded == 4*1000 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the ranges to start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the download to be cancelled, got %v", err)
+	}
+
+	// The offset reached in each range is kept in the job metadata
+	data, err := os.ReadFile(filepath.Join(dir, "meta", jobID+".json"))
+	if err != nil {
+		t.Fatalf("Failed to read job metadata: %v", err)
+	}
+	var saved ResumableJob
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Failed to parse job metadata: %v", err)
+	}
+	if len(saved.Ranges) != 4 {
+		t.Fatalf("Expected 4 ranges in the metadata, got %d", len(saved.Ranges))
+	}
+	for _, r := range saved.Ranges {
+		if r.Downloaded != 1000 {
+			t.Errorf("Expected range %d-%d to have 1000 bytes, got %d", r.Start, r.End, r.Downloaded)
+		}
+	}
+
+	// A new process picks the job up from the metadata
+	server.mutex.Lock()
+	server.stallAfter = 0
+	server.ranges = nil
+	server.mutex.Unlock()
+
+	rd = newTestDownloader(dir, 4)
+	if err := rd.Download(context.Background(), url, output, nil, nil); err != nil {
+		t.Fatalf("Resumed download failed: %v", err)
+	}
+
+	assertContent(t, output, server.content)
+
+	want := []string{"bytes=1000-8191", "bytes=17384-24575", "bytes=25576-32767", "bytes=9192-16383"}
+	if got := server.requestedRanges(); !slices.Equal(got, want) {
+		t.Errorf("Expected ranges %v, got %v", want, got)
+	}
+}
+
+func TestRangedDownloadFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		noRanges     bool
+		ignoreRanges bool
+		// ranged is the number of Range requests made before falling back
+		ranged int
+	}{
+		{"no accept-ranges", true, false, 0},
+		{"range ignored", false, true, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRangeServer(t, 8*testChunkSize)
+			server.noRanges = tt.noRanges
+			server.ignoreRanges = tt.ignoreRanges
+			dir := t.TempDir()
+			output := filepath.Join(dir, "out.mp4")
+
+			rd := newTestDownloader(dir, 4)
+			if err := rd.Download(context.Background(), server.URL+"/video.mp4", output, nil, nil); err != nil {
+				t.Fatalf("Download failed: %v", err)
+			}
+
+			assertContent(t, output, server.content)
+
+			ranges := server.requestedRanges()
+			if len(ranges) != tt.ranged+1 || ranges[0] != "" {
+				t.Errorf("Expected %d range requests and one plain GET, got %q", tt.ranged, ranges)
+			}
+		})
+	}
+}
+
+func TestRangedDownloadWrongOffset(t *testing.T) {
+	server := newRangeServer(t, 8*testChunkSize)
+	server.wrongOffset = true
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.mp4")
+
+	rd := newTestDownloader(dir, 4)
+	err := rd.Download(context.Background(), server.URL+"/video.mp4", output, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "download failed") {
+		t.Fatalf("Expected the download to fail, got %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file, got %v", err)
+	}
+}
+
+func TestRangedDownloadFileChanged(t *testing.T) {
+	server := newRangeServer(t, 8*testChunkSize)
+	server.etag = `"v1"`
+	server.stallAfter = 1000
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.mp4")
+	url := server.URL + "/video.mp4"
+
+	rd := newTestDownloader(dir, 4)
+	jobID := rd.generateJobID(url, output)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- rd.Download(ctx, url, output, nil, nil) }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if job, ok := rd.GetJob(jobID); ok {
+			job.mutex.RLock()
+			downloaded := job.Downloaded
+			job.mutex.RUnlock()
+			if downloaded == 4*1000 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the ranges to start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// The file is replaced by one of the same size before the restart
+	changed := make([]byte, len(server.content))
+	for i := range changed {
+		changed[i] = byte(i * 13 % 251)
+	}
+	server.mutex.Lock()
+	server.content = changed
+	server.etag = `"v2"`
+	server.stallAfter = 0
+	server.ranges = nil
+	server.mutex.Unlock()
+
+	rd = newTestDownloader(dir, 4)
+	if err := rd.Download(context.Background(), url, output, nil, nil); err != nil {
+		t.Fatalf("Restarted download failed: %v", err)
+	}
+
+	assertContent(t, output, changed)
+
+	want := []string{"bytes=0-8191", "bytes=16384-24575", "bytes=24576-32767", "bytes=8192-16383"}
+	if got := server.requestedRanges(); !slices.Equal(got, want) {
+		t.Errorf("Expected the download to start over with ranges %v, got %v", want, got)
+	}
+}
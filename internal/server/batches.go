@@ -0,0 +1,131 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"video-downloader/internal/batch"
+	"video-downloader/internal/downloader"
+)
+
+// batchRequest is the body of POST /batches and of the older
+// POST /videos/batch, which starts the same jobs
+type batchRequest struct {
+	Type         string   `json:"type"`
+	URLs         []string `json:"urls" binding:"required"`
+	OutputPath   string   `json:"output_path"`
+	Format       string   `json:"format"`
+	Quality      string   `json:"quality"`
+	Metadata     bool     `json:"metadata"`
+	Sidecars     bool     `json:"sidecars"`
+	Music        string   `json:"music"`
+	FileNaming   string   `json:"file_naming"`
+	SkipExisting bool     `json:"skip_existing"`
+	Priority     string   `json:"priority"`
+}
+
+// config builds the job configuration, saving to savePath unless the
+// request names an output path
+func (req *batchRequest) config(savePath string) batch.BatchDownloadConfig {
+	config := batch.BatchDownloadConfig{
+		OutputPath:   req.OutputPath,
+		Format:       req.Format,
+		Quality:      req.Quality,
+		Metadata:     req.Metadata,
+		Sidecars:     req.Sidecars,
+		Music:        downloader.MusicMode(req.Music),
+		FileNaming:   req.FileNaming,
+		SkipExisting: req.SkipExisting,
+		Priority:     downloader.Priority(req.Priority),
+	}
+	if config.OutputPath == "" {
+		config.OutputPath = savePath
+	}
+	return config
+}
+
+// Create batch job handler
+func (s *Server) createBatch(c *gin.Context) {
+	var req batchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobType := batch.BatchJobTypeURLList
+	if req.Type != "" {
+		jobType = batch.BatchJobType(req.Type)
+	}
+
+	job, err := s.batchManager.StartBatchDownload(jobType, req.URLs, req.config(s.config.Download.SavePath))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job.Info(false))
+}
+
+// List batch jobs handler
+func (s *Server) listBatches(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			limit = parsed
+		}
+	}
+
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil {
+			offset = parsed
+		}
+	}
+
+	jobs, err := s.batchManager.ListJobs(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batches": jobs,
+		"total":   len(jobs),
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// Get batch job handler
+func (s *Server) getBatch(c *gin.Context) {
+	job, err := s.batchManager.GetJob(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Cancel batch job handler
+func (s *Server) cancelBatch(c *gin.Context) {
+	if err := s.batchManager.CancelJob(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Batch job cancelled"})
+}
+
+// Retry failed batch items handler
+func (s *Server) retryBatch(c *gin.Context) {
+	job, err := s.batchManager.RetryFailed(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job.Info(false))
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"video-downloader/internal/comment"
+	"video-downloader/pkg/models"
+)
+
+// commentSortColumns maps the sort query parameter to comment columns
+var commentSortColumns = map[string]string{
+	"time":    "created_at",
+	"likes":   "like_count",
+	"replies": "reply_count",
+}
+
+// commentThread is a stored comment with the replies to it
+type commentThread struct {
+	*models.CommentRecord
+	Replies []*models.CommentRecord `json:"replies,omitempty"`
+}
+
+// Get video comments handler. Lists the stored top-level comments of a
+// video; replies=true includes the replies to each.
+func (s *Server) getVideoComments(c *gin.Context) {
+	id := c.Param("id")
+
+	video, err := s.storage.GetVideoInfo(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	filter := models.CommentFilter{
+		VideoID:   id,
+		TopLevel:  true,
+		Limit:     50,
+		OrderDesc: c.DefaultQuery("order", "desc") != "asc",
+	}
+
+	column, ok := commentSortColumns[c.DefaultQuery("sort", "time")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be time, likes or replies"})
+		return
+	}
+	filter.OrderBy = column
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = l
+		}
+	}
+
+	if offset := c.Query("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil {
+			filter.Offset = o
+		}
+	}
+
+	total, err := s.storage.CountComments(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	comments, err := s.storage.ListComments(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	withReplies := c.Query("replies") == "true"
+	threads := make([]commentThread, 0, len(comments))
+	for _, comment := range comments {
+		thread := commentThread{CommentRecord: comment}
+
+		if withReplies && comment.ReplyCount > 0 {
+			thread.Replies, err = s.storage.ListComments(models.CommentFilter{
+				ParentID: comment.ID,
+				OrderBy:  "created_at",
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		threads = append(threads, thread)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id": id,
+		"comments": threads,
+		"total":    total,
+		"limit":    filter.Limit,
+		"offset":   filter.Offset,
+	})
+}
+
+// Comment extraction limits. Threads are stored as they are fetched, so
+// the limit bounds the time a request takes rather than its memory.
+const (
+	defaultCommentLimit   = 100
+	maxCommentLimit       = 1000
+	commentExtractTimeout = 5 * time.Minute
+)
+
+// Extract video comments handler. Resolves a share URL to its video and
+// stores the video and up to limit of its comment threads, saving each
+// thread as it arrives. The comments are listed with comments_url.
+func (s *Server) extractVideoComments(c *gin.Context) {
+	var req struct {
+		URL     string `json:"url" binding:"required"`
+		Limit   int    `json:"limit" binding:"min=0,max=1000"`
+		Replies bool   `json:"replies"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultCommentLimit
+	}
+
+	if !s.registry.ValidateURL(req.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported URL"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), commentExtractTimeout)
+	defer cancel()
+
+	video, err := s.registry.ExtractVideoInfo(ctx, req.URL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keep the record of a video downloaded earlier
+	stored, err := s.storage.GetVideoInfo(video.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stored == nil {
+		if err := s.storage.SaveVideoInfo(video); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	config := comment.ConfigForVideo(s.config, video)
+	config.Limit = req.Limit
+	config.IncludeReplies = req.Replies
+
+	commentsURL := "/api/v1/videos/" + url.PathEscape(video.ID) + "/comments"
+
+	// The threads saved before a failure stay stored and are reported
+	result, err := s.comments.Store(ctx, config, s.storage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":        err.Error(),
+			"stored":       result,
+			"comments_url": commentsURL,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":     video.ID,
+		"platform":     video.Platform,
+		"limit":        req.Limit,
+		"stored":       result,
+		"comments_url": commentsURL,
+	})
+}
@@ -16,33 +16,67 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"video-downloader/internal/auth"
+	"video-downloader/internal/batch"
+	"video-downloader/internal/comment"
 	"video-downloader/internal/downloader"
 	"video-downloader/internal/monitor"
 	"video-downloader/internal/ratelimit"
+	"video-downloader/internal/registry"
+	"video-downloader/internal/subscription"
 	"video-downloader/internal/utils"
 	"video-downloader/pkg/models"
 )
 
 // Server represents the API server
 type Server struct {
-	config       *models.Config
-	storage      models.Storage
-	downloader   *downloader.Manager
-	monitor      *monitor.Monitor
-	authService  *auth.AuthService
-	rateLimitMgr *ratelimit.Manager
-	httpServer   *http.Server
-	logger       zerolog.Logger
-}
-
-// NewServer creates a new API server
-func NewServer(cfg *models.Config, storage models.Storage) *Server {
+	config        *models.Config
+	storage       models.Storage
+	downloader    *downloader.Manager
+	batchManager  *batch.BatchManager
+	registry      *registry.Registry
+	comments      *comment.CommentExtractor
+	subscriptions *subscription.Scheduler
+	monitor       *monitor.Monitor
+	authService   *auth.AuthService
+	rateLimitMgr  *ratelimit.Manager
+	httpServer    *http.Server
+	logger        zerolog.Logger
+}
+
+// NewServer creates a new API server. The download manager and the
+// extractors share the transport of transports; nil creates one from cfg.
+func NewServer(cfg *models.Config, storage models.Storage, transports *utils.TransportFactory) *Server {
+	if transports == nil {
+		transports = utils.NewTransportFactory(cfg)
+	}
+
 	// Create download manager
-	dm := downloader.NewManager(cfg, storage)
+	dm := downloader.NewManager(cfg, storage, transports)
 	if err := dm.Start(); err != nil {
 		log.Fatal().Err(err).Msg("Error starting download manager")
 	}
 
+	// Create batch manager
+	reg := registry.NewRegistry()
+	if err := reg.RegisterDefaultPlatforms(cfg, transports); err != nil {
+		log.Fatal().Err(err).Msg("Error registering platforms")
+	}
+	bm := batch.NewBatchManager(reg, dm, cfg.Download.MaxWorkers)
+	bm.SetStorage(storage)
+	if err := bm.RestoreJobs(); err != nil {
+		log.Warn().Err(err).Msg("Failed to restore batch jobs")
+	}
+
+	// Create subscription scheduler
+	scheduler := subscription.NewScheduler(reg, bm, storage)
+	if cfg.Subscriptions.Enabled {
+		checkInterval := time.Duration(cfg.Subscriptions.CheckInterval) * time.Second
+		if checkInterval <= 0 {
+			checkInterval = time.Minute
+		}
+		scheduler.Start(checkInterval)
+	}
+
 	// Create monitor
 	mon := monitor.NewMonitor()
 	mon.Start()
@@ -81,13 +115,17 @@ func NewServer(cfg *models.Config, storage models.Storage) *Server {
 	rateLimitMgr := ratelimit.NewManager(rateLimitConfig)
 
 	return &Server{
-		config:       cfg,
-		storage:      storage,
-		downloader:   dm,
-		monitor:      mon,
-		authService:  authSvc,
-		rateLimitMgr: rateLimitMgr,
-		logger:       zerolog.New(os.Stdout).With().Timestamp().Logger(),
+		config:        cfg,
+		storage:       storage,
+		downloader:    dm,
+		batchManager:  bm,
+		registry:      reg,
+		comments:      comment.NewCommentExtractor(transports.Transport()),
+		subscriptions: scheduler,
+		monitor:       mon,
+		authService:   authSvc,
+		rateLimitMgr:  rateLimitMgr,
+		logger:        zerolog.New(os.Stdout).With().Timestamp().Logger(),
 	}
 }
 
@@ -134,6 +172,14 @@ func (s *Server) Stop() error {
 	// Stop monitor
 	s.monitor.Stop()
 
+	// Stop the scheduler first so that it starts no more batch jobs
+	s.subscriptions.Stop()
+
+	// Stop batch jobs before the downloads they wait on
+	if err := s.batchManager.Close(); err != nil {
+		s.logger.Error().Err(err).Msg("Error stopping batch manager")
+	}
+
 	// Stop download manager
 	if err := s.downloader.Stop(); err != nil {
 		s.logger.Error().Err(err).Msg("Error stopping download manager")
@@ -190,10 +236,12 @@ func (s *Server) setupRoutes(router *gin.Engine) {
 				videos.Use(downloadLimiter.Middleware(2, 5)) // 2 downloads per second, burst 5
 
 				videos.POST("/download", s.downloadVideo)
-				videos.POST("/batch", s.batchDownload)
+				videos.POST("/batch", s.createBatch) // same as POST /batches
 				videos.GET("/:id", s.getVideo)
+				videos.GET("/:id/comments", s.getVideoComments)
 				videos.GET("", s.listVideos)
 				videos.POST("/info", s.getVideoInfo)
+				videos.POST("/comments", s.extractVideoComments)
 			}
 
 			// Download routes - same strict limits
@@ -203,11 +251,42 @@ func (s *Server) setupRoutes(router *gin.Engine) {
 				downloads.Use(downloadLimiter.Middleware(2, 5))
 
 				downloads.GET("", s.getDownloads)
+				downloads.GET("/events", s.streamEventsSSE)
+				downloads.GET("/ws", s.streamEventsWS)
 				downloads.GET("/:id", s.getDownload)
+				downloads.GET("/:id/events", s.streamEventsSSE)
+				downloads.GET("/:id/ws", s.streamEventsWS)
 				downloads.DELETE("/:id", s.cancelDownload)
 				downloads.POST("/:id/retry", s.retryDownload)
 			}
 
+			// Batch job routes
+			batches := protected.Group("/batches")
+			{
+				batchLimiter := ratelimit.NewRateLimiter()
+				batches.Use(batchLimiter.Middleware(2, 5))
+
+				batches.POST("", s.createBatch)
+				batches.GET("", s.listBatches)
+				batches.GET("/:id", s.getBatch)
+				batches.DELETE("/:id", s.cancelBatch)
+				batches.POST("/:id/retry", s.retryBatch)
+			}
+
+			// Subscription routes
+			subscriptions := protected.Group("/subscriptions")
+			{
+				subscriptionLimiter := ratelimit.NewRateLimiter()
+				subscriptions.Use(subscriptionLimiter.Middleware(2, 5))
+
+				subscriptions.POST("", s.createSubscription)
+				subscriptions.GET("", s.listSubscriptions)
+				subscriptions.GET("/:id", s.getSubscription)
+				subscriptions.PUT("/:id", s.updateSubscription)
+				subscriptions.DELETE("/:id", s.deleteSubscription)
+				subscriptions.POST("/:id/sync", s.syncSubscription)
+			}
+
 			// Author routes - moderate rate limiting
 			authors := protected.Group("/authors")
 			{
@@ -266,11 +345,17 @@ func (s *Server) healthCheck(c *gin.Context) {
 // Download video handler
 func (s *Server) downloadVideo(c *gin.Context) {
 	var req struct {
-		URL        string `json:"url" binding:"required"`
-		OutputPath string `json:"output_path"`
-		Format     string `json:"format"`
-		Quality    string `json:"quality"`
-		Download   bool   `json:"download"`
+		URL           string `json:"url" binding:"required"`
+		OutputPath    string `json:"output_path"`
+		Format        string `json:"format"`
+		Quality       string `json:"quality"`
+		Priority      string `json:"priority"`
+		DownloadAudio bool   `json:"download_audio"`
+		Metadata      bool   `json:"metadata"`
+		Sidecars      bool   `json:"sidecars"`
+		Music         string `json:"music"`
+		FileNaming    string `json:"file_naming"`
+		Download      bool   `json:"download"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -278,74 +363,42 @@ func (s *Server) downloadVideo(c *gin.Context) {
 		return
 	}
 
-	// Download options
-	options := &downloader.DownloadOptions{
-		OutputPath: req.OutputPath,
-		Format:     req.Format,
-		Quality:    req.Quality,
-		Progress:   true,
-	}
-
-	// Start download
-	result, err := s.downloader.Download(req.URL, options)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Prepare response
-	response := gin.H{
-		"success": result.Success,
-		"message": result.Message,
-	}
-
-	if result.Video != nil {
-		response["video"] = result.Video
-	}
-
-	if result.Error != nil {
-		response["error"] = result.Error.Error()
-	}
-
-	c.JSON(http.StatusOK, response)
-}
-
-// Batch download handler
-func (s *Server) batchDownload(c *gin.Context) {
-	var req struct {
-		URLs       []string `json:"urls" binding:"required"`
-		OutputPath string   `json:"output_path"`
-		Format     string   `json:"format"`
-		Quality    string   `json:"quality"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	// Single downloads jump ahead of bulk work unless told otherwise
+	priority := downloader.PriorityInteractive
+	if req.Priority != "" {
+		p, err := downloader.ParsePriority(req.Priority)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		priority = p
 	}
 
 	// Download options
 	options := &downloader.DownloadOptions{
-		OutputPath: req.OutputPath,
-		Format:     req.Format,
-		Quality:    req.Quality,
-		Progress:   true,
-	}
-
-	// Start batch download
-	results, err := s.downloader.DownloadBatch(req.URLs, options)
+		Priority:      priority,
+		OutputPath:    req.OutputPath,
+		Format:        req.Format,
+		Quality:       req.Quality,
+		DownloadAudio: req.DownloadAudio,
+		Metadata:      req.Metadata,
+		Sidecars:      req.Sidecars,
+		Music:         downloader.MusicMode(req.Music),
+		FileNaming:    req.FileNaming,
+		Progress:      true,
+	}
+
+	// Queue download; progress is tracked under /downloads/:id
+	taskID, err := s.downloader.Download(c.Request.Context(), req.URL, options)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Prepare response
-	response := gin.H{
-		"total":   len(results),
-		"results": results,
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusAccepted, gin.H{
+		"task_id": taskID,
+		"status":  downloader.TaskStatusPending,
+	})
 }
 
 // Get video handler
@@ -430,7 +483,7 @@ func (s *Server) getVideoInfo(c *gin.Context) {
 	}
 
 	// Get video info
-	videoInfo, err := s.downloader.GetVideoInfo(req.URL)
+	videoInfo, err := s.downloader.GetVideoInfo(c.Request.Context(), req.URL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -454,7 +507,7 @@ func (s *Server) getDownloads(c *gin.Context) {
 func (s *Server) getDownload(c *gin.Context) {
 	id := c.Param("id")
 
-	task, err := s.storage.GetDownloadTask(id)
+	task, err := s.downloader.GetTask(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -497,7 +550,7 @@ func (s *Server) getAuthor(c *gin.Context) {
 	platform := models.Platform(c.Param("platform"))
 	id := c.Param("id")
 
-	author, err := s.downloader.GetAuthorInfo(platform, id)
+	author, err := s.downloader.GetAuthorInfo(c.Request.Context(), platform, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
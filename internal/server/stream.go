@@ -0,0 +1,138 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"video-downloader/internal/downloader"
+)
+
+// streamHeartbeat is how often an idle stream sends a keep-alive
+const streamHeartbeat = 15 * time.Second
+
+// eventFilter builds an event filter from the :id path parameter or the
+// task_id and batch_id query parameters
+func eventFilter(c *gin.Context) downloader.EventFilter {
+	filter := downloader.EventFilter{
+		TaskID:  c.Param("id"),
+		BatchID: c.Query("batch_id"),
+	}
+	if filter.TaskID == "" {
+		filter.TaskID = c.Query("task_id")
+	}
+	return filter
+}
+
+// streamDone reports whether a single-task stream has seen the last event
+// for its task
+func streamDone(filter downloader.EventFilter, event downloader.ProgressEvent) bool {
+	return filter.TaskID != "" && event.Type == downloader.EventStatus && downloader.IsFinalStatus(event.Status)
+}
+
+// Stream progress events over Server-Sent Events
+func (s *Server) streamEventsSSE(c *gin.Context) {
+	filter := eventFilter(c)
+
+	events, unsubscribe := s.downloader.SubscribeEvents(filter)
+	defer unsubscribe()
+
+	// Streams outlive the server write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Debug().Err(err).Msg("Error clearing write deadline")
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// Current state first, so clients don't miss what already happened
+	for _, event := range s.downloader.EventSnapshot(filter) {
+		c.SSEvent(event.Type, event)
+		if streamDone(filter, event) {
+			c.Writer.Flush()
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return !streamDone(filter, event)
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"timestamp": time.Now().Unix()})
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// Stream progress events over WebSocket
+func (s *Server) streamEventsWS(c *gin.Context) {
+	filter := eventFilter(c)
+
+	// Auth is enforced by the route middleware; origins are already open
+	// via CORS, so skip the origin check
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// The hijacked connection keeps the server deadlines
+			if err := ws.SetDeadline(time.Time{}); err != nil {
+				s.logger.Debug().Err(err).Msg("Error clearing websocket deadline")
+			}
+
+			events, unsubscribe := s.downloader.SubscribeEvents(filter)
+			defer unsubscribe()
+
+			// Reading is only used to notice the client going away
+			closed := make(chan struct{})
+			go func() {
+				io.Copy(io.Discard, ws)
+				close(closed)
+			}()
+
+			for _, event := range s.downloader.EventSnapshot(filter) {
+				if err := websocket.JSON.Send(ws, event); err != nil || streamDone(filter, event) {
+					return
+				}
+			}
+
+			heartbeat := time.NewTicker(streamHeartbeat)
+			defer heartbeat.Stop()
+
+			for {
+				select {
+				case event, ok := <-events:
+					if !ok {
+						return
+					}
+					if err := websocket.JSON.Send(ws, event); err != nil || streamDone(filter, event) {
+						return
+					}
+				case <-heartbeat.C:
+					if err := websocket.JSON.Send(ws, gin.H{"type": "ping", "timestamp": time.Now().Unix()}); err != nil {
+						return
+					}
+				case <-closed:
+					return
+				}
+			}
+		},
+	}
+
+	server.ServeHTTP(c.Writer, c.Request)
+}
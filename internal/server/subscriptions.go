@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"video-downloader/internal/subscription"
+	"video-downloader/pkg/models"
+)
+
+// subscriptionRequest is the body of create and update requests. Fields
+// left out of an update keep their value.
+type subscriptionRequest struct {
+	Platform   string  `json:"platform"`
+	AuthorID   string  `json:"author_id"`
+	URL        *string `json:"url"`
+	Interval   *int    `json:"interval"`
+	Limit      *int    `json:"limit"`
+	Enabled    *bool   `json:"enabled"`
+	OutputPath *string `json:"output_path"`
+	Format     *string `json:"format"`
+	Quality    *string `json:"quality"`
+	Music      *string `json:"music"`
+	FileNaming *string `json:"file_naming"`
+	Metadata   *bool   `json:"metadata"`
+	Sidecars   *bool   `json:"sidecars"`
+}
+
+// apply copies the fields set in the request to sub
+func (req *subscriptionRequest) apply(sub *models.Subscription) {
+	setField(&sub.URL, req.URL)
+	setField(&sub.Interval, req.Interval)
+	setField(&sub.Limit, req.Limit)
+	setField(&sub.Enabled, req.Enabled)
+	setField(&sub.OutputPath, req.OutputPath)
+	setField(&sub.Format, req.Format)
+	setField(&sub.Quality, req.Quality)
+	setField(&sub.Music, req.Music)
+	setField(&sub.FileNaming, req.FileNaming)
+	setField(&sub.Metadata, req.Metadata)
+	setField(&sub.Sidecars, req.Sidecars)
+}
+
+func setField[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// Create subscription handler
+func (s *Server) createSubscription(c *gin.Context) {
+	var req subscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Platform == "" || req.AuthorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "platform and author_id are required"})
+		return
+	}
+
+	sub := &models.Subscription{
+		Platform: models.Platform(req.Platform),
+		AuthorID: req.AuthorID,
+		Enabled:  true,
+	}
+	req.apply(sub)
+
+	if err := s.subscriptions.Add(sub); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, subscription.ErrSubscribed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+// List subscriptions handler
+func (s *Server) listSubscriptions(c *gin.Context) {
+	subs, err := s.storage.ListSubscriptions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": subs,
+		"total":         len(subs),
+	})
+}
+
+// Get subscription handler
+func (s *Server) getSubscription(c *gin.Context) {
+	sub, ok := s.findSubscription(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// Update subscription handler. The platform and author cannot change; a
+// new interval reschedules the next sync.
+func (s *Server) updateSubscription(c *gin.Context) {
+	sub, ok := s.findSubscription(c)
+	if !ok {
+		return
+	}
+
+	var req subscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (req.Platform != "" && models.Platform(req.Platform) != sub.Platform) || (req.AuthorID != "" && req.AuthorID != sub.AuthorID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "platform and author_id cannot be changed"})
+		return
+	}
+
+	// Apply the changes to the subscription as it is once any sync in
+	// progress has finished, not to the copy loaded above
+	sub, err := s.subscriptions.Update(sub.ID, req.apply)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, subscription.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// Delete subscription handler. Videos already downloaded are kept.
+func (s *Server) deleteSubscription(c *gin.Context) {
+	sub, ok := s.findSubscription(c)
+	if !ok {
+		return
+	}
+
+	if err := s.subscriptions.Remove(sub.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscription deleted"})
+}
+
+// Sync subscription handler. Syncs now instead of waiting for the
+// schedule; new posts are downloaded by the batch job in the result.
+func (s *Server) syncSubscription(c *gin.Context) {
+	sub, ok := s.findSubscription(c)
+	if !ok {
+		return
+	}
+
+	result, err := s.subscriptions.Sync(c.Request.Context(), sub.ID)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, subscription.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// findSubscription loads the subscription named by the id parameter,
+// writing the error response if there is none
+func (s *Server) findSubscription(c *gin.Context) (*models.Subscription, bool) {
+	sub, err := s.storage.GetSubscription(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if sub == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return nil, false
+	}
+
+	return sub, true
+}
@@ -8,6 +8,7 @@ import (
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 
 	"video-downloader/pkg/models"
@@ -37,7 +38,10 @@ func NewSQLite(path string) (*SQLite, error) {
 	if err := db.AutoMigrate(
 		&models.VideoInfo{},
 		&models.DownloadTask{},
+		&models.BatchJobRecord{},
 		&models.AuthorInfo{},
+		&models.CommentRecord{},
+		&models.Subscription{},
 		&models.User{},
 		&models.Session{},
 	); err != nil {
@@ -146,6 +150,19 @@ func (s *SQLite) GetDownloadTask(id string) (*models.DownloadTask, error) {
 	return &task, nil
 }
 
+// ListDownloadTasks lists download tasks in any of the given statuses
+func (s *SQLite) ListDownloadTasks(statuses ...string) ([]*models.DownloadTask, error) {
+	var tasks []*models.DownloadTask
+	query := s.db.Order("created_at ASC")
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
 // UpdateDownloadProgress updates download progress
 func (s *SQLite) UpdateDownloadProgress(id string, progress float64) error {
 	return s.db.Model(&models.DownloadTask{}).
@@ -156,6 +173,39 @@ func (s *SQLite) UpdateDownloadProgress(id string, progress float64) error {
 		}).Error
 }
 
+// SaveBatchJob saves a batch job
+func (s *SQLite) SaveBatchJob(job *models.BatchJobRecord) error {
+	return s.db.Save(job).Error
+}
+
+// GetBatchJob retrieves a batch job
+func (s *SQLite) GetBatchJob(id string) (*models.BatchJobRecord, error) {
+	var job models.BatchJobRecord
+	if err := s.db.Where("id = ?", id).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListBatchJobs lists batch jobs, newest first
+func (s *SQLite) ListBatchJobs(limit, offset int) ([]*models.BatchJobRecord, error) {
+	var jobs []*models.BatchJobRecord
+	query := s.db.Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	if err := query.Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
 // SaveAuthorInfo saves author information
 func (s *SQLite) SaveAuthorInfo(info *models.AuthorInfo) error {
 	return s.db.Save(info).Error
@@ -173,6 +223,114 @@ func (s *SQLite) GetAuthorInfo(platform models.Platform, id string) (*models.Aut
 	return &author, nil
 }
 
+// commentBatchSize bounds the comments written per statement, within
+// SQLite's limit on query variables
+const commentBatchSize = 500
+
+// SaveComments upserts comments by ID. Stored comments only get their
+// like and reply counts updated.
+func (s *SQLite) SaveComments(comments []*models.CommentRecord) (int, error) {
+	added := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(comments); start += commentBatchSize {
+			batch := comments[start:min(start+commentBatchSize, len(comments))]
+
+			ids := make([]string, len(batch))
+			for i, comment := range batch {
+				ids[i] = comment.ID
+			}
+
+			var existing []string
+			if err := tx.Model(&models.CommentRecord{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+				return err
+			}
+
+			stored := make(map[string]bool, len(existing))
+			for _, id := range existing {
+				stored[id] = true
+			}
+			for _, id := range ids {
+				if !stored[id] {
+					stored[id] = true
+					added++
+				}
+			}
+
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"like_count", "reply_count", "updated_at"}),
+			}).Create(batch).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return added, nil
+}
+
+// commentQuery applies the filters of filter, except for paging
+func (s *SQLite) commentQuery(filter models.CommentFilter) *gorm.DB {
+	query := s.db.Model(&models.CommentRecord{})
+
+	if filter.VideoID != "" {
+		query = query.Where("video_id = ?", filter.VideoID)
+	}
+
+	if filter.ParentID != "" {
+		query = query.Where("parent_id = ?", filter.ParentID)
+	} else if filter.TopLevel {
+		query = query.Where("parent_id = ''")
+	}
+
+	return query
+}
+
+// ListComments lists comments with filters, oldest first by default
+func (s *SQLite) ListComments(filter models.CommentFilter) ([]*models.CommentRecord, error) {
+	var comments []*models.CommentRecord
+	query := s.commentQuery(filter)
+
+	// Apply ordering
+	if filter.OrderBy != "" {
+		order := filter.OrderBy
+		if filter.OrderDesc {
+			order += " DESC"
+		} else {
+			order += " ASC"
+		}
+		query = query.Order(order)
+	} else {
+		query = query.Order("created_at ASC")
+	}
+
+	// Apply pagination
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	if err := query.Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// CountComments counts comments with filters
+func (s *SQLite) CountComments(filter models.CommentFilter) (int64, error) {
+	var count int64
+	if err := s.commentQuery(filter).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 // Close closes the storage connection
 func (s *SQLite) Close() error {
 	db, err := s.db.DB()
@@ -283,15 +441,48 @@ func (s *SQLite) SearchVideos(query string, limit int) ([]*models.VideoInfo, err
 // GetVideosByAuthor returns videos by author
 func (s *SQLite) GetVideosByAuthor(authorID string, platform models.Platform, limit int) ([]*models.VideoInfo, error) {
 	var videos []*models.VideoInfo
-	if err := s.db.Where("author_id = ? AND platform = ?", authorID, platform).
-		Order("published_at DESC").
-		Limit(limit).
-		Find(&videos).Error; err != nil {
+	query := s.db.Where("author_id = ? AND platform = ?", authorID, platform).
+		Order("published_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&videos).Error; err != nil {
 		return nil, err
 	}
 	return videos, nil
 }
 
+// SaveSubscription saves an author subscription
+func (s *SQLite) SaveSubscription(sub *models.Subscription) error {
+	return s.db.Save(sub).Error
+}
+
+// GetSubscription retrieves a subscription
+func (s *SQLite) GetSubscription(id string) (*models.Subscription, error) {
+	var sub models.Subscription
+	if err := s.db.Where("id = ?", id).First(&sub).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListSubscriptions lists subscriptions, oldest first
+func (s *SQLite) ListSubscriptions() ([]*models.Subscription, error) {
+	var subs []*models.Subscription
+	if err := s.db.Order("created_at ASC").Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// DeleteSubscription deletes a subscription
+func (s *SQLite) DeleteSubscription(id string) error {
+	return s.db.Delete(&models.Subscription{}, "id = ?", id).Error
+}
+
 // SaveUser saves a user
 func (s *SQLite) SaveUser(user *models.User) error {
 	return s.db.Save(user).Error
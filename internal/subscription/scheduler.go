@@ -0,0 +1,439 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"video-downloader/internal/batch"
+	"video-downloader/internal/downloader"
+	"video-downloader/internal/registry"
+	"video-downloader/internal/utils"
+	"video-downloader/pkg/models"
+)
+
+const (
+	// DefaultInterval is the number of minutes between syncs of a
+	// subscription that does not set one
+	DefaultInterval = 60
+
+	// DefaultLimit is the number of posts listed per sync of a
+	// subscription that does not set one
+	DefaultLimit = 30
+)
+
+var (
+	// ErrSubscribed is returned by Add for an author already subscribed to
+	ErrSubscribed = errors.New("already subscribed")
+
+	// ErrNotFound is returned for a subscription that does not exist
+	ErrNotFound = errors.New("subscription not found")
+)
+
+// profileURLs are the profile pages subscriptions list, by platform
+var profileURLs = map[models.Platform]string{
+	models.PlatformTikTok:   "https://www.tiktok.com/@%s",
+	models.PlatformXHS:      "https://www.xiaohongshu.com/user/profile/%s",
+	models.PlatformKuaishou: "https://www.kuaishou.com/profile/%s",
+}
+
+// SyncResult summarises one sync of a subscription
+type SyncResult struct {
+	SubscriptionID string     `json:"subscription_id"`
+	Found          int        `json:"found"`
+	New            int        `json:"new"`
+	BatchID        string     `json:"batch_id,omitempty"`
+	LastSeenAt     *time.Time `json:"last_seen_at"`
+}
+
+// Scheduler syncs author subscriptions. Each sync lists the author's
+// profile, compares it with the videos already downloaded for the author
+// and starts a batch job for the new posts only.
+type Scheduler struct {
+	registry *registry.Registry
+	batches  *batch.BatchManager
+	storage  models.Storage
+	logger   zerolog.Logger
+
+	// syncMutex runs one sync at a time, scheduled or not
+	syncMutex sync.Mutex
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+// NewScheduler creates a subscription scheduler. Profiles are listed
+// through reg and new posts downloaded as jobs of bm.
+func NewScheduler(reg *registry.Registry, bm *batch.BatchManager, storage models.Storage) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		registry: reg,
+		batches:  bm,
+		storage:  storage,
+		logger:   zerolog.New(nil).With().Str("component", "subscription_scheduler").Logger(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start checks for due subscriptions now and then every checkInterval
+// until Stop is called
+func (s *Scheduler) Start(checkInterval time.Duration) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			s.syncDue()
+
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler, cancelling a sync in progress. Batch jobs
+// already started keep running.
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.workers.Wait()
+}
+
+// syncDue syncs the enabled subscriptions whose next sync is due
+func (s *Scheduler) syncDue() {
+	subs, err := s.storage.ListSubscriptions()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Error listing subscriptions")
+		return
+	}
+
+	now := time.Now()
+	for _, sub := range subs {
+		if s.ctx.Err() != nil {
+			return
+		}
+		if !sub.Enabled || sub.NextSyncAt.After(now) {
+			continue
+		}
+
+		if _, err := s.Sync(s.ctx, sub.ID); err != nil {
+			s.logger.Error().Err(err).Str("subscription_id", sub.ID).Msg("Subscription sync failed")
+		}
+	}
+}
+
+// Add validates a new subscription, fills in its defaults and saves it.
+// The first sync is due right away.
+func (s *Scheduler) Add(sub *models.Subscription) error {
+	if sub.URL == "" {
+		pattern, ok := profileURLs[sub.Platform]
+		if !ok {
+			return fmt.Errorf("unsupported platform: %s", sub.Platform)
+		}
+		sub.URL = fmt.Sprintf(pattern, sub.AuthorID)
+	}
+
+	// Check and save under the lock, so two subscriptions to the same
+	// author cannot both pass the check
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	subs, err := s.storage.ListSubscriptions()
+	if err != nil {
+		return fmt.Errorf("error listing subscriptions: %w", err)
+	}
+	for _, existing := range subs {
+		if existing.Platform == sub.Platform && existing.AuthorID == sub.AuthorID {
+			return fmt.Errorf("%w to %s author %s", ErrSubscribed, sub.Platform, sub.AuthorID)
+		}
+	}
+
+	sub.ID = fmt.Sprintf("sub_%d", time.Now().UnixNano())
+	sub.NextSyncAt = time.Now()
+
+	if err := s.validate(sub); err != nil {
+		return err
+	}
+
+	return s.storage.SaveSubscription(sub)
+}
+
+// Update applies fn to the stored subscription with the given ID and saves
+// it. The subscription is loaded after any sync in progress has finished,
+// so fn sees and keeps the sync's outcome. A changed interval reschedules
+// the next sync.
+func (s *Scheduler) Update(id string, fn func(sub *models.Subscription)) (*models.Subscription, error) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	sub, err := s.storage.GetSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	interval := sub.Interval
+	fn(sub)
+
+	if err := s.validate(sub); err != nil {
+		return nil, err
+	}
+	if sub.Interval != interval && sub.LastSyncAt != nil {
+		sub.NextSyncAt = sub.LastSyncAt.Add(time.Duration(sub.Interval) * time.Minute)
+	}
+
+	if err := s.storage.SaveSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// validate checks a subscription and fills in its defaults
+func (s *Scheduler) validate(sub *models.Subscription) error {
+	if sub.AuthorID == "" {
+		return fmt.Errorf("author ID is required")
+	}
+	if sub.Interval <= 0 {
+		sub.Interval = DefaultInterval
+	}
+	if sub.Limit <= 0 {
+		sub.Limit = DefaultLimit
+	}
+
+	platform, err := s.registry.DetectPlatform(sub.URL)
+	if err != nil {
+		return err
+	}
+	if platform != sub.Platform {
+		return fmt.Errorf("URL %s is not a %s profile", sub.URL, sub.Platform)
+	}
+
+	if _, err := downloader.ParseQuality(sub.Quality); err != nil {
+		return err
+	}
+	if _, err := downloader.ParseMusicMode(sub.Music); err != nil {
+		return err
+	}
+	if sub.FileNaming != "" {
+		if _, err := utils.ParsePathTemplate(sub.FileNaming); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove deletes a subscription, waiting for a sync in progress
+func (s *Scheduler) Remove(id string) error {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	return s.storage.DeleteSubscription(id)
+}
+
+// Sync syncs a subscription now, whether or not it is enabled or due. It
+// lists the profile, downloads the posts that are neither downloaded,
+// being downloaded nor older than the last one seen and schedules the next
+// sync. The outcome is recorded on the subscription either way, unless the
+// sync was interrupted by ctx; the subscription is then left as it was so
+// it is still due.
+func (s *Scheduler) Sync(ctx context.Context, id string) (*SyncResult, error) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	// Load it under the lock to see changes saved since it was listed
+	sub, err := s.storage.GetSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	result, err := s.syncProfile(ctx, sub)
+	if err != nil && ctx.Err() != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	sub.LastSyncAt = &now
+	sub.NextSyncAt = now.Add(time.Duration(sub.Interval) * time.Minute)
+	sub.LastError = ""
+	if err != nil {
+		sub.LastError = err.Error()
+	}
+
+	if saveErr := s.storage.SaveSubscription(sub); saveErr != nil && err == nil {
+		err = fmt.Errorf("error saving subscription: %w", saveErr)
+	}
+
+	return result, err
+}
+
+// syncProfile does the work of Sync, updating sub in place
+func (s *Scheduler) syncProfile(ctx context.Context, sub *models.Subscription) (*SyncResult, error) {
+	result := &SyncResult{SubscriptionID: sub.ID, LastSeenAt: sub.LastSeenAt}
+
+	videos, err := s.registry.ExtractBatch(ctx, sub.URL, sub.Limit)
+	if err != nil {
+		return result, fmt.Errorf("error listing profile: %w", err)
+	}
+	result.Found = len(videos)
+
+	downloaded, err := s.downloadedVideos(sub, videos)
+	if err != nil {
+		return result, err
+	}
+	active, err := s.activeVideos(sub)
+	if err != nil {
+		return result, err
+	}
+
+	// Move past the posts downloaded since the last sync, but never past
+	// one that has yet to be downloaded, so that a failed download is
+	// tried again
+	sub.LastSeenAt = lastSeen(sub.LastSeenAt, videos, downloaded)
+	result.LastSeenAt = sub.LastSeenAt
+
+	var fresh []*models.VideoInfo
+	for _, video := range videos {
+		if downloaded[video.ID] || active[video.ID] || active[video.URL] {
+			continue
+		}
+		if sub.LastSeenAt != nil && !video.PublishedAt.IsZero() && !video.PublishedAt.After(*sub.LastSeenAt) {
+			continue
+		}
+		fresh = append(fresh, video)
+	}
+
+	if len(videos) > 0 && videos[0].AuthorName != "" {
+		sub.AuthorName = videos[0].AuthorName
+	}
+
+	if len(fresh) > 0 {
+		job, err := s.batches.StartVideoBatch(fresh, batchConfig(sub))
+		if err != nil {
+			return result, fmt.Errorf("error starting batch job: %w", err)
+		}
+		sub.LastBatchID = job.ID
+		result.BatchID = job.ID
+	}
+	result.New = len(fresh)
+
+	s.logger.Info().Str("subscription_id", sub.ID).Int("found", result.Found).Int("new", result.New).Msg("Subscription synced")
+	return result, nil
+}
+
+// lastSeen returns the publish time of the newest post up to which every
+// listed post published after since has been downloaded
+func lastSeen(since *time.Time, videos []*models.VideoInfo, downloaded map[string]bool) *time.Time {
+	var newer []*models.VideoInfo
+	for _, video := range videos {
+		if video.PublishedAt.IsZero() || (since != nil && !video.PublishedAt.After(*since)) {
+			continue
+		}
+		newer = append(newer, video)
+	}
+	slices.SortFunc(newer, func(a, b *models.VideoInfo) int {
+		return a.PublishedAt.Compare(b.PublishedAt)
+	})
+
+	for _, video := range newer {
+		if !downloaded[video.ID] {
+			break
+		}
+		published := video.PublishedAt
+		since = &published
+	}
+
+	return since
+}
+
+// downloadedVideos returns the IDs of the downloaded videos by the authors
+// of videos. Videos stored without being downloaded, or whose download
+// failed, are left out. The profile's videos carry the platform's own
+// author ID, which may differ from the handle the subscription was made
+// with.
+func (s *Scheduler) downloadedVideos(sub *models.Subscription, videos []*models.VideoInfo) (map[string]bool, error) {
+	authors := map[string]bool{sub.AuthorID: true}
+	for _, video := range videos {
+		if video.AuthorID != "" {
+			authors[video.AuthorID] = true
+		}
+	}
+
+	downloaded := make(map[string]bool)
+	for authorID := range authors {
+		existing, err := s.storage.GetVideosByAuthor(authorID, sub.Platform, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error listing stored videos: %w", err)
+		}
+		for _, video := range existing {
+			if video.Status == downloader.TaskStatusCompleted {
+				downloaded[video.ID] = true
+			}
+		}
+	}
+
+	return downloaded, nil
+}
+
+// activeVideos returns the video IDs and URLs of downloads still queued
+// or running, including the items of the subscription's last batch job
+// while it runs
+func (s *Scheduler) activeVideos(sub *models.Subscription) (map[string]bool, error) {
+	active := make(map[string]bool)
+
+	tasks, err := s.storage.ListDownloadTasks(downloader.TaskStatusPending, downloader.TaskStatusDownloading)
+	if err != nil {
+		return nil, fmt.Errorf("error listing download tasks: %w", err)
+	}
+	for _, task := range tasks {
+		active[task.URL] = true
+		if task.VideoID != "" {
+			active[task.VideoID] = true
+		}
+	}
+
+	if sub.LastBatchID != "" {
+		if job, err := s.batches.GetJobStatus(sub.LastBatchID); err == nil {
+			info := job.Info(false)
+			if info.Status == batch.JobStatusPending || info.Status == batch.JobStatusRunning {
+				for _, url := range info.URLs {
+					active[url] = true
+				}
+			}
+		}
+	}
+
+	return active, nil
+}
+
+// batchConfig returns the options new posts of sub are downloaded with
+func batchConfig(sub *models.Subscription) batch.BatchDownloadConfig {
+	return batch.BatchDownloadConfig{
+		OutputPath:   sub.OutputPath,
+		Format:       sub.Format,
+		Quality:      sub.Quality,
+		Metadata:     sub.Metadata,
+		Sidecars:     sub.Sidecars,
+		Music:        downloader.MusicMode(sub.Music),
+		FileNaming:   sub.FileNaming,
+		SkipExisting: true,
+	}
+}
@@ -0,0 +1,53 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"video-downloader/pkg/models"
+)
+
+func TestLastSeen(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		t := start.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+
+	// Listed newest first, as profiles are
+	videos := []*models.VideoInfo{
+		{ID: "d", PublishedAt: *at(4)},
+		{ID: "c", PublishedAt: *at(3)},
+		{ID: "b", PublishedAt: *at(2)},
+		{ID: "undated"},
+		{ID: "a", PublishedAt: *at(1)},
+	}
+
+	tests := []struct {
+		name       string
+		since      *time.Time
+		downloaded []string
+		want       *time.Time
+	}{
+		{"nothing downloaded", nil, nil, nil},
+		{"all downloaded", nil, []string{"a", "b", "c", "d"}, at(4)},
+		{"failed download holds back", nil, []string{"a", "c", "d"}, at(1)},
+		{"oldest failed", nil, []string{"b", "c", "d"}, nil},
+		{"older posts ignored", at(2), []string{"c"}, at(3)},
+		{"never moves back", at(3), nil, at(3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloaded := make(map[string]bool)
+			for _, id := range tt.downloaded {
+				downloaded[id] = true
+			}
+
+			got := lastSeen(tt.since, videos, downloaded)
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,16 +1,20 @@
 package utils
 
 import (
-	"bufio"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -54,6 +58,9 @@ type DownloadConfig struct {
 	RetryCount int
 	TempDir    string
 	Timeout    time.Duration
+	// Transport, when set, carries the downloads' requests, see
+	// ClientConfig.Transport
+	Transport http.RoundTripper
 }
 
 // NewDownloadManager creates a new download manager
@@ -63,7 +70,7 @@ func NewDownloadManager(config DownloadConfig) *DownloadManager {
 	}
 
 	return &DownloadManager{
-		client:     NewHTTPClient(ClientConfig{Timeout: config.Timeout}),
+		client:     NewHTTPClient(ClientConfig{Timeout: config.Timeout, Transport: config.Transport}),
 		logger:     zerolog.New(os.Stdout).With().Timestamp().Logger(),
 		maxWorkers: config.MaxWorkers,
 		chunkSize:  config.ChunkSize,
@@ -74,8 +81,9 @@ func NewDownloadManager(config DownloadConfig) *DownloadManager {
 	}
 }
 
-// Download downloads a file to the specified path
-func (dm *DownloadManager) Download(url, filePath string, progressChan chan<- float64) error {
+// Download downloads a file to the specified path. It blocks until the
+// download finishes, fails, or ctx is cancelled.
+func (dm *DownloadManager) Download(ctx context.Context, url, filePath string, progressChan chan<- float64) error {
 	// Create job
 	job := &DownloadJob{
 		ID:        generateJobID(),
@@ -91,18 +99,23 @@ func (dm *DownloadManager) Download(url, filePath string, progressChan chan<- fl
 	dm.activeJobs[job.ID] = job
 	dm.jobsMutex.Unlock()
 
-	// Start download in goroutine
-	go dm.downloadFile(job, progressChan)
+	defer func() {
+		dm.jobsMutex.Lock()
+		delete(dm.activeJobs, job.ID)
+		dm.jobsMutex.Unlock()
+	}()
 
-	return nil
+	dm.downloadFile(ctx, job, progressChan)
+
+	return job.Error
 }
 
 // downloadFile performs the actual file download
-func (dm *DownloadManager) downloadFile(job *DownloadJob, progressChan chan<- float64) {
+func (dm *DownloadManager) downloadFile(ctx context.Context, job *DownloadJob, progressChan chan<- float64) {
 	defer close(job.Progress)
 
 	// Create context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	job.CancelFunc = cancel
 	defer cancel()
 
@@ -168,7 +181,7 @@ Generated at: %s
 	}
 
 	// Get file size
-	size, err := dm.client.GetFileSize(job.URL)
+	size, err := dm.client.GetFileSize(ctx, job.URL)
 	if err != nil {
 		job.Error = fmt.Errorf("error getting file size: %w", err)
 		job.Status = "failed"
@@ -269,6 +282,9 @@ Generated at: %s
 	if _, err := io.Copy(file, reader); err != nil {
 		job.Error = fmt.Errorf("error downloading file: %w", err)
 		job.Status = "failed"
+		if ctx.Err() != nil {
+			job.Status = "cancelled"
+		}
 		return
 	}
 
@@ -379,124 +395,532 @@ type M3U8Downloader struct {
 	logger     zerolog.Logger
 	tempDir    string
 	maxWorkers int
+	retryCount int
+	retryDelay time.Duration
+}
+
+// HLSOptions controls an HLS download
+type HLSOptions struct {
+	// Quality selects the variant of a master playlist, see SelectVariant
+	Quality string
+	// Headers are sent with every playlist and segment request
+	Headers map[string]string
 }
 
+// HLSManifest records which parts of an HLS download are already on disk.
+// It is saved next to the parts, so a download interrupted by a failure or
+// a restart only fetches what is missing. PlaylistURL is the media playlist
+// the parts came from and Fingerprint a hash of their URIs, byte ranges and
+// keys in order; the parts are only reused while both still match.
+type HLSManifest struct {
+	URL         string           `json:"url"`
+	PlaylistURL string           `json:"playlist_url"`
+	OutputPath  string           `json:"output_path"`
+	Parts       int              `json:"parts"`
+	Fingerprint string           `json:"fingerprint"`
+	Completed   map[string]int64 `json:"completed"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	path        string
+	mutex       sync.Mutex
+}
+
+const (
+	// maxPlaylistDepth bounds how many master playlists are followed
+	maxPlaylistDepth = 3
+	// maxSegmentBackoff caps the delay between segment retries
+	maxSegmentBackoff = 30 * time.Second
+)
+
 // NewM3U8Downloader creates a new M3U8 downloader
 func NewM3U8Downloader(config DownloadConfig) *M3U8Downloader {
+	if config.TempDir == "" {
+		config.TempDir = "./temp"
+	}
+	if config.MaxWorkers <= 0 {
+		config.MaxWorkers = 1
+	}
+
 	return &M3U8Downloader{
-		client:     NewHTTPClient(ClientConfig{Timeout: config.Timeout}),
+		client:     NewHTTPClient(ClientConfig{Timeout: config.Timeout, Transport: config.Transport}),
 		logger:     zerolog.New(os.Stdout).With().Timestamp().Logger(),
 		tempDir:    config.TempDir,
 		maxWorkers: config.MaxWorkers,
+		retryCount: config.RetryCount,
+		retryDelay: time.Second,
 	}
 }
 
-// DownloadM3U8 downloads an M3U8 playlist and merges the segments
-func (md *M3U8Downloader) DownloadM3U8(m3u8URL, outputPath string, progressChan chan<- float64) error {
-	// Download M3U8 playlist
-	resp, err := md.client.Get(m3u8URL, nil)
+// hlsPart is a resource to fetch: a media segment or an init segment
+type hlsPart struct {
+	url       string
+	byteRange *HLSByteRange
+	key       *HLSKey
+	iv        []byte
+	file      string
+}
+
+// hlsKeys fetches each key of a stream once
+type hlsKeys struct {
+	mutex sync.Mutex
+	keys  map[string][]byte
+}
+
+// DownloadM3U8 downloads an M3U8 playlist and merges the segments. A master
+// playlist is resolved to one of its variants first. Segments are kept
+// until the merge succeeds, so calling it again with the same URL and
+// output path resumes a failed download.
+func (md *M3U8Downloader) DownloadM3U8(ctx context.Context, m3u8URL, outputPath string, options HLSOptions, progressChan chan<- float64) error {
+	playlist, playlistURL, err := md.loadMediaPlaylist(ctx, m3u8URL, options)
 	if err != nil {
-		return fmt.Errorf("error downloading M3U8 playlist: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if len(playlist.Segments) == 0 {
+		return fmt.Errorf("no segments found in M3U8 playlist")
+	}
+
+	if !playlist.EndList {
+		md.logger.Warn().Str("url", m3u8URL).Msg("Playlist has no end tag, downloading listed segments only")
 	}
 
-	// Parse M3U8 playlist
-	scanner := bufio.NewScanner(resp.Body)
-	var segments []string
-	var baseURI = m3u8URL[:strings.LastIndex(m3u8URL, "/")+1]
+	for _, segment := range playlist.Segments {
+		if segment.Key != nil && segment.Key.Method != HLSMethodAES128 {
+			return fmt.Errorf("unsupported HLS encryption method: %s", segment.Key.Method)
+		}
+	}
+
+	workDir := md.workDir(m3u8URL, outputPath)
+
+	// Each init segment is fetched once, however many segments use it
+	var parts []hlsPart
+	mapFiles := make(map[*HLSMap]string)
+	for _, segment := range playlist.Segments {
+		if segment.Map == nil {
+			continue
+		}
+		if _, ok := mapFiles[segment.Map]; ok {
+			continue
+		}
+		file := filepath.Join(workDir, fmt.Sprintf("init_%04d.mp4", len(mapFiles)))
+		mapFiles[segment.Map] = file
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			if strings.HasPrefix(line, "http") {
-				segments = append(segments, line)
-			} else {
-				segments = append(segments, baseURI+line)
+		part := hlsPart{url: segment.Map.URI, byteRange: segment.Map.ByteRange, key: segment.Map.Key, file: file}
+		if part.key != nil {
+			if part.iv, err = segment.Map.IV(); err != nil {
+				return err
 			}
 		}
+		parts = append(parts, part)
 	}
 
-	if len(segments) == 0 {
-		return fmt.Errorf("no segments found in M3U8 playlist")
+	segmentFiles := make([]string, len(playlist.Segments))
+	for i, segment := range playlist.Segments {
+		segmentFiles[i] = filepath.Join(workDir, fmt.Sprintf("segment_%05d", i))
+
+		part := hlsPart{url: segment.URI, byteRange: segment.ByteRange, key: segment.Key, file: segmentFiles[i]}
+		if part.key != nil {
+			part.iv = segment.IV()
+		}
+		parts = append(parts, part)
 	}
 
-	// Create temp directory
-	tempDir := filepath.Join(md.tempDir, "m3u8_"+strconv.FormatInt(time.Now().Unix(), 10))
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return fmt.Errorf("error creating temp directory: %w", err)
+	manifest, err := md.loadManifest(workDir, m3u8URL, playlistURL, outputPath, parts)
+	if err != nil {
+		return err
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Download segments
-	segmentFiles := make([]string, len(segments))
+	keys := &hlsKeys{keys: make(map[string][]byte)}
+
+	// Download missing parts
 	sem := make(chan struct{}, md.maxWorkers)
 	var wg sync.WaitGroup
 	var downloadErrors []error
 	var errorsMu sync.Mutex
+	var completed atomic.Int64
+
+	for _, part := range parts {
+		if manifest.has(part.file) {
+			completed.Add(1)
+			continue
+		}
 
-	for i, segmentURL := range segments {
 		wg.Add(1)
-		go func(index int, url string) {
+		go func(part hlsPart) {
 			defer wg.Done()
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			segmentFile := filepath.Join(tempDir, fmt.Sprintf("segment_%04d.ts", index))
-			if err := md.downloadSegment(url, segmentFile); err != nil {
+			if err := md.downloadPart(ctx, part, keys, options.Headers); err != nil {
 				errorsMu.Lock()
 				downloadErrors = append(downloadErrors, err)
 				errorsMu.Unlock()
-				md.logger.Error().Err(err).Msg("Error downloading segment")
+				md.logger.Error().Err(err).Str("url", part.url).Msg("Error downloading segment")
 				return
 			}
-			segmentFiles[index] = segmentFile
+
+			if err := manifest.complete(part.file); err != nil {
+				md.logger.Warn().Err(err).Str("file", part.file).Msg("Error updating HLS manifest")
+			}
 
 			// Report progress
 			if progressChan != nil {
-				progress := float64(index+1) / float64(len(segments)) * 100
+				progress := float64(completed.Add(1)) / float64(len(parts)) * 100
 				progressChan <- progress
 			}
-		}(i, segmentURL)
+		}(part)
 	}
 
 	wg.Wait()
 
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("M3U8 download cancelled: %w", err)
+	}
+
 	if len(downloadErrors) > 0 {
-		return fmt.Errorf("errors occurred while downloading segments: %v", downloadErrors)
+		return fmt.Errorf("errors occurred while downloading segments: %w", errors.Join(downloadErrors...))
+	}
+
+	// Merge segments, writing each init segment ahead of the segments
+	// that use it
+	var files []string
+	var currentMap *HLSMap
+	for i, segment := range playlist.Segments {
+		if segment.Map != nil && segment.Map != currentMap {
+			files = append(files, mapFiles[segment.Map])
+			currentMap = segment.Map
+		}
+		files = append(files, segmentFiles[i])
 	}
 
-	// Merge segments
-	return md.mergeSegments(segmentFiles, outputPath)
+	if err := md.mergeSegments(files, outputPath); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(workDir); err != nil {
+		md.logger.Warn().Err(err).Str("dir", workDir).Msg("Error removing HLS work directory")
+	}
+
+	return nil
 }
 
-// downloadSegment downloads a single segment
-func (md *M3U8Downloader) downloadSegment(url, filePath string) error {
-	resp, err := md.client.Get(url, nil)
+// workDir returns the directory the parts of a download are kept in. It
+// is derived from the download, so a later attempt finds it again.
+func (md *M3U8Downloader) workDir(m3u8URL, outputPath string) string {
+	hash := md5.Sum([]byte(m3u8URL + ":" + outputPath))
+	return filepath.Join(md.tempDir, "m3u8_"+hex.EncodeToString(hash[:]))
+}
+
+// loadManifest returns the manifest in workDir if it belongs to the same
+// download of the same parts, or else starts a new one in an empty
+// workDir. Another variant or a refreshed playlist lists other parts, so
+// their files are never mixed with the ones on disk.
+func (md *M3U8Downloader) loadManifest(workDir, m3u8URL, playlistURL, outputPath string, parts []hlsPart) (*HLSManifest, error) {
+	path := filepath.Join(workDir, "manifest.json")
+	fingerprint := partsFingerprint(parts)
+
+	if data, err := os.ReadFile(path); err == nil {
+		manifest := &HLSManifest{}
+		if err := json.Unmarshal(data, manifest); err == nil &&
+			manifest.URL == m3u8URL && manifest.PlaylistURL == playlistURL && manifest.OutputPath == outputPath &&
+			manifest.Parts == len(parts) && manifest.Fingerprint == fingerprint {
+			manifest.path = path
+			if manifest.Completed == nil {
+				manifest.Completed = make(map[string]int64)
+			}
+			md.logger.Info().Str("url", m3u8URL).Int("completed", len(manifest.Completed)).Int("parts", len(parts)).Msg("Resuming HLS download")
+			return manifest, nil
+		}
+
+		// The playlist or the chosen variant changed since the last attempt
+		md.logger.Info().Str("url", m3u8URL).Msg("Discarding stale HLS segments")
+	}
+
+	if err := os.RemoveAll(workDir); err != nil {
+		return nil, fmt.Errorf("error clearing temp directory: %w", err)
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating temp directory: %w", err)
+	}
+
+	now := time.Now()
+	manifest := &HLSManifest{
+		URL:         m3u8URL,
+		PlaylistURL: playlistURL,
+		OutputPath:  outputPath,
+		Parts:       len(parts),
+		Fingerprint: fingerprint,
+		Completed:   make(map[string]int64),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		path:        path,
+	}
+
+	return manifest, manifest.save()
+}
+
+// partsFingerprint hashes what each part is fetched from, in order
+func partsFingerprint(parts []hlsPart) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%s %s", filepath.Base(part.file), part.url)
+		if part.byteRange != nil {
+			fmt.Fprintf(hash, " %d@%d", part.byteRange.Length, part.byteRange.Offset)
+		}
+		if part.key != nil {
+			fmt.Fprintf(hash, " %s %s %x", part.key.Method, part.key.URI, part.iv)
+		}
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// has reports whether file was completed and is still intact
+func (m *HLSManifest) has(file string) bool {
+	m.mutex.Lock()
+	size, ok := m.Completed[filepath.Base(file)]
+	m.mutex.Unlock()
+	if !ok {
+		return false
+	}
+
+	stat, err := os.Stat(file)
+	return err == nil && stat.Size() == size
+}
+
+// complete records file as downloaded
+func (m *HLSManifest) complete(file string) error {
+	stat, err := os.Stat(file)
 	if err != nil {
-		return fmt.Errorf("error downloading segment: %w", err)
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.Completed[filepath.Base(file)] = stat.Size()
+	m.UpdatedAt = time.Now()
+	return m.save()
+}
+
+// save writes the manifest. Must be called with the mutex held, or before
+// the manifest is shared.
+func (m *HLSManifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+
+	// Write then rename, so a crash never leaves a torn manifest
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// downloadPart downloads a part, retrying with exponential backoff
+func (md *M3U8Downloader) downloadPart(ctx context.Context, part hlsPart, keys *hlsKeys, headers map[string]string) error {
+	delay := md.retryDelay
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = md.downloadSegment(ctx, part, keys, headers); err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil || attempt >= md.retryCount {
+			return err
+		}
+
+		md.logger.Warn().
+			Int("attempt", attempt+1).
+			Int("max", md.retryCount).
+			Str("url", part.url).
+			Err(err).
+			Msg("Segment failed, retrying...")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		delay = min(delay*2, maxSegmentBackoff)
+	}
+}
+
+// loadMediaPlaylist fetches the playlist at m3u8URL, following master
+// playlists to the variant chosen by options.Quality. It returns the media
+// playlist and its URL.
+func (md *M3U8Downloader) loadMediaPlaylist(ctx context.Context, m3u8URL string, options HLSOptions) (*HLSPlaylist, string, error) {
+	playlistURL := m3u8URL
+	for depth := 0; depth < maxPlaylistDepth; depth++ {
+		playlist, err := md.fetchPlaylist(ctx, playlistURL, options.Headers)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !playlist.Master {
+			return playlist, playlistURL, nil
+		}
+
+		variant, err := SelectVariant(playlist.Variants, options.Quality)
+		if err != nil {
+			return nil, "", err
+		}
+
+		md.logger.Info().
+			Str("url", variant.URI).
+			Int64("bandwidth", variant.Bandwidth).
+			Int("height", variant.Height).
+			Msg("Selected HLS variant")
+
+		playlistURL = variant.URI
+	}
+
+	return nil, "", fmt.Errorf("too many nested master playlists")
+}
+
+// fetchPlaylist downloads and parses a playlist
+func (md *M3U8Downloader) fetchPlaylist(ctx context.Context, playlistURL string, headers map[string]string) (*HLSPlaylist, error) {
+	resp, err := md.client.Get(ctx, playlistURL, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading M3U8 playlist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// Resolve against the final URL, in case of redirects
+	playlist, err := ParseM3U8(resp.Body, resp.Request.URL.String())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing M3U8 playlist: %w", err)
+	}
+
+	return playlist, nil
+}
+
+// fetchKey returns the key at keyURL, downloading it on first use
+func (md *M3U8Downloader) fetchKey(ctx context.Context, keys *hlsKeys, keyURL string, headers map[string]string) ([]byte, error) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	if key, ok := keys.keys[keyURL]; ok {
+		return key, nil
+	}
+
+	resp, err := md.client.Get(ctx, keyURL, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading key: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code for key: %d", resp.StatusCode)
+	}
+
+	key, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return nil, fmt.Errorf("error reading key: %w", err)
+	}
+	if len(key) != 16 {
+		return nil, fmt.Errorf("invalid AES-128 key length: %d", len(key))
+	}
+
+	keys.keys[keyURL] = key
+	return key, nil
+}
+
+// downloadSegment downloads a single segment, or the byte range of it
+// given by the playlist, decrypting it if needed
+func (md *M3U8Downloader) downloadSegment(ctx context.Context, part hlsPart, keys *hlsKeys, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", part.url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if part.byteRange != nil {
+		req.Header.Set("Range", part.byteRange.Header())
+	}
+
+	resp, err := md.client.Do(req, headers)
+	if err != nil {
+		return fmt.Errorf("error downloading segment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// expected is the segment length, or -1 if the server does not say
+	var body io.Reader = resp.Body
+	expected := resp.ContentLength
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && part.byteRange != nil:
+		body = io.LimitReader(resp.Body, part.byteRange.Length)
+		expected = part.byteRange.Length
+	case resp.StatusCode == http.StatusOK && part.byteRange != nil:
+		// Server ignored the range, cut it out of the full response
+		if _, err := io.CopyN(io.Discard, resp.Body, part.byteRange.Offset); err != nil {
+			return fmt.Errorf("error skipping to byte range: %w", err)
+		}
+		body = io.LimitReader(resp.Body, part.byteRange.Length)
+		expected = part.byteRange.Length
+	case resp.StatusCode != http.StatusOK:
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	file, err := os.Create(filePath)
+	if part.key != nil {
+		key, err := md.fetchKey(ctx, keys, part.key.URI, headers)
+		if err != nil {
+			return err
+		}
+
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("error downloading segment: %w", err)
+		}
+		if err := checkSegmentLength(int64(len(data)), expected); err != nil {
+			return err
+		}
+
+		plain, err := DecryptAES128(data, key, part.iv)
+		if err != nil {
+			return fmt.Errorf("error decrypting segment: %w", err)
+		}
+
+		return os.WriteFile(part.file, plain, 0644)
+	}
+
+	file, err := os.Create(part.file)
 	if err != nil {
 		return fmt.Errorf("error creating segment file: %w", err)
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
-	return err
+	// A short segment is an error, so that it is retried rather than
+	// recorded as complete in the manifest
+	written, err := io.Copy(file, body)
+	if err != nil {
+		return fmt.Errorf("error downloading segment: %w", err)
+	}
+	return checkSegmentLength(written, expected)
+}
+
+// checkSegmentLength returns an error if a segment of got bytes is not of
+// the expected length. An expected length of -1 is not checked.
+func checkSegmentLength(got, expected int64) error {
+	if expected >= 0 && got != expected {
+		return fmt.Errorf("truncated segment: got %d of %d bytes", got, expected)
+	}
+	return nil
 }
 
-// mergeSegments merges TS segments into a single file
+// mergeSegments merges segment files into a single file
 func (md *M3U8Downloader) mergeSegments(segmentFiles []string, outputPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
 	output, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("error creating output file: %w", err)
@@ -0,0 +1,334 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hlsServer serves a media playlist of segments seg0.ts, seg1.ts, ...
+// and counts the requests for each path. A segment can be made to fail a
+// number of times before it is served. master.m3u8 lists a low and a high
+// variant of the playlist, and refresh republishes the playlist with new
+// segment URIs and content.
+type hlsServer struct {
+	*httptest.Server
+	count int
+
+	mutex    sync.Mutex
+	version  int
+	requests map[string]int
+	failures map[string]int
+}
+
+func newHLSServer(t *testing.T, count int) *hlsServer {
+	t.Helper()
+
+	s := &hlsServer{
+		count:    count,
+		requests: make(map[string]int),
+		failures: make(map[string]int),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *hlsServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests[r.URL.Path]++
+	fail := s.failures[r.URL.Path]
+	if fail > 0 {
+		s.failures[r.URL.Path]--
+	}
+	version := s.version
+	s.mutex.Unlock()
+
+	if fail != 0 {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	variant := r.URL.Query().Get("variant")
+	switch r.URL.Path {
+	case "/master.m3u8":
+		w.Write([]byte("#EXTM3U\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nindex.m3u8?variant=low\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720\nindex.m3u8?variant=high\n"))
+		return
+	case "/index.m3u8":
+		var playlist strings.Builder
+		playlist.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:4\n")
+		for i := 0; i < s.count; i++ {
+			fmt.Fprintf(&playlist, "#EXTINF:4.0,\nseg%d.ts?variant=%s&v=%d\n", i, variant, version)
+		}
+		playlist.WriteString("#EXT-X-ENDLIST\n")
+		w.Write([]byte(playlist.String()))
+		return
+	}
+
+	var i int
+	if _, err := fmt.Sscanf(r.URL.Path, "/seg%d.ts", &i); err != nil || i >= s.count {
+		http.NotFound(w, r)
+		return
+	}
+	segmentVersion, _ := strconv.Atoi(r.URL.Query().Get("v"))
+	w.Write(s.segment(variant, segmentVersion, i))
+}
+
+// segment returns the content of segment i of a variant and version
+func (s *hlsServer) segment(variant string, version, i int) []byte {
+	prefix := fmt.Sprintf("%s%d:", variant, version)
+	return append([]byte(prefix), bytes.Repeat([]byte{byte('a' + i)}, 1000+i)...)
+}
+
+// fail makes path fail times times; -1 fails it until cleared
+func (s *hlsServer) fail(path string, times int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures[path] = times
+}
+
+// refresh republishes the playlists with new segments
+func (s *hlsServer) refresh() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.version++
+}
+
+func (s *hlsServer) requestCount(path string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[path]
+}
+
+// joined returns the content of the current version of a variant
+func (s *hlsServer) joined(variant string) []byte {
+	s.mutex.Lock()
+	version := s.version
+	s.mutex.Unlock()
+
+	var content []byte
+	for i := 0; i < s.count; i++ {
+		content = append(content, s.segment(variant, version, i)...)
+	}
+	return content
+}
+
+func newTestM3U8Downloader(t *testing.T, tempDir string, retries int) *M3U8Downloader {
+	t.Helper()
+
+	md := NewM3U8Downloader(DownloadConfig{MaxWorkers: 2, RetryCount: retries, TempDir: tempDir})
+	md.retryDelay = time.Millisecond
+	return md
+}
+
+func assertFileContent(t *testing.T, path string, want []byte) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected %d bytes, got %d different bytes", len(want), len(got))
+	}
+}
+
+func TestDownloadM3U8Retry(t *testing.T) {
+	server := newHLSServer(t, 3)
+	server.fail("/seg1.ts", 2)
+
+	output := filepath.Join(t.TempDir(), "out.ts")
+	md := newTestM3U8Downloader(t, t.TempDir(), 2)
+	if err := md.DownloadM3U8(context.Background(), server.URL+"/index.m3u8", output, HLSOptions{}, nil); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	assertFileContent(t, output, server.joined(""))
+	if n := server.requestCount("/seg1.ts"); n != 3 {
+		t.Errorf("Expected segment to be requested 3 times, got %d", n)
+	}
+}
+
+func TestDownloadM3U8Resume(t *testing.T) {
+	server := newHLSServer(t, 4)
+	server.fail("/seg2.ts", -1)
+
+	tempDir := t.TempDir()
+	output := filepath.Join(t.TempDir(), "out.ts")
+	playlistURL := server.URL + "/index.m3u8"
+
+	md := newTestM3U8Downloader(t, tempDir, 0)
+	if err := md.DownloadM3U8(context.Background(), playlistURL, output, HLSOptions{}, nil); err == nil {
+		t.Fatal("Expected the first attempt to fail")
+	}
+
+	// The segments that did arrive are recorded for the next attempt
+	data, err := os.ReadFile(filepath.Join(md.workDir(playlistURL, output), "manifest.json"))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest HLSManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if len(manifest.Completed) != 3 {
+		t.Errorf("Expected 3 completed segments, got %v", manifest.Completed)
+	}
+
+	server.fail("/seg2.ts", 0)
+	if err := md.DownloadM3U8(context.Background(), playlistURL, output, HLSOptions{}, nil); err != nil {
+		t.Fatalf("Resumed download failed: %v", err)
+	}
+
+	assertFileContent(t, output, server.joined(""))
+	for i, want := range []int{1, 1, 2, 1} {
+		path := fmt.Sprintf("/seg%d.ts", i)
+		if n := server.requestCount(path); n != want {
+			t.Errorf("Expected %s to be requested %d times, got %d", path, want, n)
+		}
+	}
+}
+
+func TestDownloadM3U8StaleManifest(t *testing.T) {
+	server := newHLSServer(t, 2)
+
+	tempDir := t.TempDir()
+	output := filepath.Join(t.TempDir(), "out.ts")
+	playlistURL := server.URL + "/index.m3u8"
+
+	md := newTestM3U8Downloader(t, tempDir, 0)
+
+	// Left behind by a download of another playlist to the same work
+	// directory, with a first segment that looks complete
+	workDir := md.workDir(playlistURL, output)
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stale := []byte("stale segment")
+	if err := os.WriteFile(filepath.Join(workDir, "segment_00000"), stale, 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(HLSManifest{
+		URL:        server.URL + "/other.m3u8",
+		OutputPath: output,
+		Parts:      2,
+		Completed:  map[string]int64{"segment_00000": int64(len(stale))},
+	})
+	if err := os.WriteFile(filepath.Join(workDir, "manifest.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := md.DownloadM3U8(context.Background(), playlistURL, output, HLSOptions{}, nil); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	assertFileContent(t, output, server.joined(""))
+	if n := server.requestCount("/seg0.ts"); n != 1 {
+		t.Errorf("Expected the stale segment to be fetched again, got %d requests", n)
+	}
+}
+
+func TestDownloadM3U8ChangedParts(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		// change is made between the failed attempt and the retry
+		change  func(s *hlsServer) HLSOptions
+		variant string
+	}{
+		{
+			name: "refreshed playlist",
+			url:  "/index.m3u8",
+			change: func(s *hlsServer) HLSOptions {
+				s.refresh()
+				return HLSOptions{}
+			},
+		},
+		{
+			name: "other variant",
+			url:  "/master.m3u8",
+			change: func(s *hlsServer) HLSOptions {
+				return HLSOptions{Quality: "720p"}
+			},
+			variant: "high",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newHLSServer(t, 3)
+			server.fail("/seg1.ts", -1)
+
+			output := filepath.Join(t.TempDir(), "out.ts")
+			playlistURL := server.URL + tt.url
+
+			md := newTestM3U8Downloader(t, t.TempDir(), 0)
+			if err := md.DownloadM3U8(context.Background(), playlistURL, output, HLSOptions{Quality: "360p"}, nil); err == nil {
+				t.Fatal("Expected the first attempt to fail")
+			}
+
+			// Same number of segments, but not the ones on disk
+			options := tt.change(server)
+			server.fail("/seg1.ts", 0)
+			if err := md.DownloadM3U8(context.Background(), playlistURL, output, options, nil); err != nil {
+				t.Fatalf("Retry failed: %v", err)
+			}
+
+			assertFileContent(t, output, server.joined(tt.variant))
+			if n := server.requestCount("/seg0.ts"); n != 2 {
+				t.Errorf("Expected the segments of the first attempt to be fetched again, got %d requests", n)
+			}
+		})
+	}
+}
+
+func TestDownloadSegmentTruncated(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var mutex sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		first := requests == 1
+		mutex.Unlock()
+
+		// The first response ends early but is otherwise well-formed
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 100-599/%d", len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		if first {
+			w.Write(content[100:300])
+			return
+		}
+		w.Write(content[100:600])
+	}))
+	t.Cleanup(server.Close)
+
+	md := newTestM3U8Downloader(t, t.TempDir(), 1)
+	part := hlsPart{
+		url:       server.URL + "/media.ts",
+		byteRange: &HLSByteRange{Length: 500, Offset: 100},
+		file:      filepath.Join(t.TempDir(), "segment"),
+	}
+
+	if err := md.downloadPart(context.Background(), part, nil, nil); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	assertFileContent(t, part.file, content[100:600])
+	if requests != 2 {
+		t.Errorf("Expected the truncated segment to be retried, got %d requests", requests)
+	}
+}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"video-downloader/pkg/models"
+)
+
+// MaxFilenameLength is the longest file or directory name, in bytes, that
+// a rendered template produces. Most filesystems allow 255.
+const MaxFilenameLength = 200
+
+// DefaultFileNaming is the template used when none is configured
+const DefaultFileNaming = "{platform}_{author}_{title}_{id}"
+
+// templateFields maps placeholder names to VideoInfo values. Time fields
+// take a Go layout after a colon, other fields a maximum length in
+// characters, e.g. {published_at:2006-01} or {title:50}.
+var templateFields = map[string]func(v *models.VideoInfo) any{
+	"platform":     func(v *models.VideoInfo) any { return string(v.Platform) },
+	"id":           func(v *models.VideoInfo) any { return v.ID },
+	"title":        func(v *models.VideoInfo) any { return v.Title },
+	"description":  func(v *models.VideoInfo) any { return v.Description },
+	"author":       func(v *models.VideoInfo) any { return v.AuthorName },
+	"author_name":  func(v *models.VideoInfo) any { return v.AuthorName },
+	"author_id":    func(v *models.VideoInfo) any { return v.AuthorID },
+	"media_type":   func(v *models.VideoInfo) any { return string(v.MediaType) },
+	"quality":      func(v *models.VideoInfo) any { return v.Quality },
+	"duration":     func(v *models.VideoInfo) any { return v.Duration },
+	"view_count":   func(v *models.VideoInfo) any { return v.ViewCount },
+	"like_count":   func(v *models.VideoInfo) any { return v.LikeCount },
+	"published_at": func(v *models.VideoInfo) any { return v.PublishedAt },
+	"collected_at": func(v *models.VideoInfo) any { return v.CollectedAt },
+	// date is the publish date, kept from the original naming scheme
+	"date": func(v *models.VideoInfo) any { return v.PublishedAt.Format("2006-01-02") },
+}
+
+// templatePart is literal text or a placeholder of a path template
+type templatePart struct {
+	literal string
+	field   string
+	spec    string
+}
+
+// PathTemplate renders output paths from video details. Placeholders are
+// written as {field} or {field:spec}; "/" in the template separates
+// directories. {ext} is the file extension and is appended when the
+// template does not use it.
+type PathTemplate struct {
+	segments [][]templatePart
+	hasExt   bool
+}
+
+// ParsePathTemplate parses a path template such as
+// "{platform}/{author_name}/{published_at:2006-01}/{title}_{id}.{ext}"
+func ParsePathTemplate(template string) (*PathTemplate, error) {
+	if strings.TrimSpace(template) == "" {
+		template = DefaultFileNaming
+	}
+
+	t := &PathTemplate{}
+	for _, segment := range strings.Split(filepath.ToSlash(template), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("invalid path segment %q in template %q", segment, template)
+		}
+
+		var parts []templatePart
+		rest := segment
+		for rest != "" {
+			open := strings.IndexByte(rest, '{')
+			if open < 0 {
+				if strings.ContainsRune(rest, '}') {
+					return nil, fmt.Errorf("unmatched } in template %q", template)
+				}
+				parts = append(parts, templatePart{literal: rest})
+				break
+			}
+			if open > 0 {
+				if strings.ContainsRune(rest[:open], '}') {
+					return nil, fmt.Errorf("unmatched } in template %q", template)
+				}
+				parts = append(parts, templatePart{literal: rest[:open]})
+			}
+
+			end := strings.IndexByte(rest[open:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed { in template %q", template)
+			}
+
+			field, spec, _ := strings.Cut(rest[open+1:open+end], ":")
+			if err := validatePlaceholder(field, spec); err != nil {
+				return nil, fmt.Errorf("template %q: %w", template, err)
+			}
+			if field == "ext" {
+				t.hasExt = true
+			}
+
+			parts = append(parts, templatePart{field: field, spec: spec})
+			rest = rest[open+end+1:]
+		}
+
+		t.segments = append(t.segments, parts)
+	}
+
+	if t.hasExt {
+		last := t.segments[len(t.segments)-1]
+		if last[len(last)-1].field != "ext" {
+			return nil, fmt.Errorf("template %q: {ext} must end the file name", template)
+		}
+	}
+
+	return t, nil
+}
+
+// validatePlaceholder checks a placeholder's field name and spec
+func validatePlaceholder(field, spec string) error {
+	if field == "ext" {
+		if spec != "" {
+			return fmt.Errorf("{ext} takes no format")
+		}
+		return nil
+	}
+
+	value, ok := templateFields[field]
+	if !ok {
+		return fmt.Errorf("unknown placeholder {%s}", field)
+	}
+	if spec == "" {
+		return nil
+	}
+
+	if _, isTime := value(&models.VideoInfo{}).(time.Time); isTime {
+		return nil
+	}
+	if n, err := strconv.Atoi(spec); err != nil || n <= 0 {
+		return fmt.Errorf("invalid length %q for {%s}", spec, field)
+	}
+	return nil
+}
+
+// Render returns the relative path for video, with ext as the file
+// extension. Each directory and file name is sanitised and cut to
+// MaxFilenameLength; values that render empty become "unknown".
+func (t *PathTemplate) Render(video *models.VideoInfo, ext string) string {
+	ext = strings.TrimPrefix(ext, ".")
+
+	names := make([]string, 0, len(t.segments))
+	for i, parts := range t.segments {
+		last := i == len(t.segments)-1
+
+		var name strings.Builder
+		for _, part := range parts {
+			switch {
+			case part.field == "":
+				name.WriteString(part.literal)
+			case part.field == "ext":
+				// Added after sanitising so truncation keeps it
+			default:
+				name.WriteString(renderField(video, part.field, part.spec))
+			}
+		}
+
+		stem := strings.TrimSuffix(name.String(), ".")
+		suffix := ""
+		if last && ext != "" {
+			suffix = "." + ext
+		}
+
+		stem = truncateUTF8(SanitizeFilename(stem), MaxFilenameLength-len(suffix))
+		if stem == "" {
+			stem = "unknown"
+		}
+
+		names = append(names, stem+suffix)
+	}
+
+	return filepath.Join(names...)
+}
+
+// renderField formats one placeholder value
+func renderField(video *models.VideoInfo, field, spec string) string {
+	switch value := templateFields[field](video).(type) {
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		if spec == "" {
+			spec = "2006-01-02"
+		}
+		return value.Format(spec)
+	case int:
+		return strconv.Itoa(value)
+	case string:
+		if n, err := strconv.Atoi(spec); err == nil && utf8.RuneCountInString(value) > n {
+			value = string([]rune(value)[:n])
+		}
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// truncateUTF8 cuts s to at most max bytes without splitting a character
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return strings.TrimSpace(s[:max])
+}
+
+// UniquePath returns path, or path with a numeric suffix before the
+// extension if path already exists or taken reports it as in use
+func UniquePath(path string, taken func(string) bool) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+
+	candidate := path
+	for n := 1; ; n++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) && (taken == nil || !taken(candidate)) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d%s", stem, n, ext)
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"video-downloader/pkg/models"
+)
+
+func TestPathTemplateRender(t *testing.T) {
+	video := &models.VideoInfo{
+		ID:          "123",
+		Platform:    models.PlatformTikTok,
+		Title:       "Hello/World: a \"test\"\nvideo",
+		AuthorName:  "jane",
+		PublishedAt: time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		template, want string
+	}{
+		{"", "tiktok_jane_Hello_World_ a _test_ video_123.mp4"},
+		{"{platform}/{author_name}/{published_at:2006-01}/{title:5}_{id}.{ext}", filepath.Join("tiktok", "jane", "2024-03", "Hello_123.mp4")},
+		{"{date}_{id}", "2024-03-09_123.mp4"},
+		{"{author_id}/{id}", filepath.Join("unknown", "123.mp4")},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := ParsePathTemplate(tt.template)
+		if err != nil {
+			t.Errorf("ParsePathTemplate(%q): unexpected error: %v", tt.template, err)
+			continue
+		}
+		if got := tmpl.Render(video, "mp4"); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestPathTemplateLength(t *testing.T) {
+	tmpl, err := ParsePathTemplate("{title}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := tmpl.Render(&models.VideoInfo{Title: strings.Repeat("视频", 100)}, "mp4")
+	if len(got) > MaxFilenameLength || !utf8.ValidString(got) || !strings.HasSuffix(got, ".mp4") {
+		t.Errorf("Expected a valid name of at most %d bytes ending in .mp4, got %d bytes: %q", MaxFilenameLength, len(got), got)
+	}
+}
+
+func TestParsePathTemplateInvalid(t *testing.T) {
+	for _, template := range []string{
+		"{unknown}",
+		"{title",
+		"title}",
+		"{title:abc}",
+		"{ext}/{id}",
+		"{platform}//{id}",
+		"../{id}",
+	} {
+		if _, err := ParsePathTemplate(template); err == nil {
+			t.Errorf("ParsePathTemplate(%q): expected error", template)
+		}
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "video.mp4")
+
+	if got := UniquePath(path, nil); got != path {
+		t.Errorf("Expected unused path unchanged, got %s", got)
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	taken := func(p string) bool { return p == filepath.Join(dir, "video_1.mp4") }
+
+	if got := UniquePath(path, taken); got != filepath.Join(dir, "video_2.mp4") {
+		t.Errorf("Expected video_2.mp4, got %s", got)
+	}
+}
@@ -0,0 +1,456 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HLSPlaylist is a parsed M3U8 playlist. A master playlist only has
+// variants; a media playlist only has segments.
+type HLSPlaylist struct {
+	Master         bool
+	Variants       []HLSVariant
+	Segments       []HLSSegment
+	MediaSequence  int64
+	TargetDuration float64
+	EndList        bool
+}
+
+// HLSVariant is a rendition listed by #EXT-X-STREAM-INF
+type HLSVariant struct {
+	URI       string
+	Bandwidth int64
+	Width     int
+	Height    int
+	Codecs    string
+	FrameRate float64
+}
+
+// HLSByteRange is a sub-range of a resource
+type HLSByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// Header returns the value for a Range request header
+func (r *HLSByteRange) Header() string {
+	return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+}
+
+// HLS encryption methods
+const (
+	HLSMethodNone      = "NONE"
+	HLSMethodAES128    = "AES-128"
+	HLSMethodSampleAES = "SAMPLE-AES"
+)
+
+// HLSKey is an encryption key declared by #EXT-X-KEY
+type HLSKey struct {
+	Method string
+	URI    string
+	// IV is nil when the playlist leaves it to the media sequence number
+	IV []byte
+}
+
+// HLSMap is an init segment declared by #EXT-X-MAP. Segments that share
+// an init segment point at the same HLSMap.
+type HLSMap struct {
+	URI       string
+	ByteRange *HLSByteRange
+	Key       *HLSKey
+}
+
+// IV returns the initialisation vector for the init segment, which the
+// playlist must give explicitly
+func (m *HLSMap) IV() ([]byte, error) {
+	if m.Key.IV == nil {
+		return nil, fmt.Errorf("encrypted init segment without IV")
+	}
+	return m.Key.IV, nil
+}
+
+// HLSSegment is a single media segment
+type HLSSegment struct {
+	URI       string
+	Duration  float64
+	Sequence  int64
+	ByteRange *HLSByteRange
+	Map       *HLSMap
+	// Key is nil for unencrypted segments
+	Key *HLSKey
+}
+
+// IV returns the initialisation vector for the segment: the key's IV, or
+// else the media sequence number as a 128-bit big-endian integer
+func (s *HLSSegment) IV() []byte {
+	if s.Key.IV != nil {
+		return s.Key.IV
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(s.Sequence))
+	return iv
+}
+
+// IsM3U8URL reports whether the URL points at an HLS playlist
+func IsM3U8URL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".m3u8")
+}
+
+// ParseM3U8 parses a playlist. Relative URIs are resolved against
+// playlistURL.
+func ParseM3U8(r io.Reader, playlistURL string) (*HLSPlaylist, error) {
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid playlist URL: %w", err)
+	}
+
+	playlist := &HLSPlaylist{}
+
+	var (
+		header    bool
+		variant   *HLSVariant
+		segment   HLSSegment
+		initMap   *HLSMap
+		key       *HLSKey
+		sequence  int64
+		lastURI   string
+		lastEnd   int64
+		sawInf    bool
+		lineNum   int
+		mediaSeen bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !header {
+			if !strings.HasPrefix(line, "#EXTM3U") {
+				return nil, fmt.Errorf("not an M3U8 playlist")
+			}
+			header = true
+			continue
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			uri := resolveURI(base, line)
+
+			if variant != nil {
+				variant.URI = uri
+				playlist.Variants = append(playlist.Variants, *variant)
+				variant = nil
+				continue
+			}
+
+			if !sawInf {
+				return nil, fmt.Errorf("line %d: segment without #EXTINF", lineNum)
+			}
+
+			segment.URI = uri
+			segment.Sequence = sequence
+			segment.Map = initMap
+			segment.Key = key
+			if segment.ByteRange != nil && segment.ByteRange.Offset < 0 {
+				// No offset: continues from the previous sub-range
+				if uri != lastURI {
+					return nil, fmt.Errorf("line %d: byte range without offset", lineNum)
+				}
+				segment.ByteRange.Offset = lastEnd
+			}
+			if segment.ByteRange != nil {
+				lastEnd = segment.ByteRange.Offset + segment.ByteRange.Length
+			}
+			lastURI = uri
+
+			playlist.Segments = append(playlist.Segments, segment)
+			segment = HLSSegment{}
+			sawInf = false
+			sequence++
+			continue
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch tag {
+		case "#EXT-X-STREAM-INF":
+			attrs := parseAttributes(value)
+			variant = &HLSVariant{Codecs: attrs["CODECS"]}
+			variant.Bandwidth, _ = strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+			variant.FrameRate, _ = strconv.ParseFloat(attrs["FRAME-RATE"], 64)
+			if w, h, ok := strings.Cut(attrs["RESOLUTION"], "x"); ok {
+				variant.Width, _ = strconv.Atoi(w)
+				variant.Height, _ = strconv.Atoi(h)
+			}
+			playlist.Master = true
+
+		case "#EXTINF":
+			duration, _, _ := strings.Cut(value, ",")
+			d, err := strconv.ParseFloat(strings.TrimSpace(duration), 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid segment duration: %w", lineNum, err)
+			}
+			segment.Duration = d
+			sawInf = true
+			mediaSeen = true
+
+		case "#EXT-X-BYTERANGE":
+			br, err := parseByteRange(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			segment.ByteRange = br
+
+		case "#EXT-X-MAP":
+			attrs := parseAttributes(value)
+			if attrs["URI"] == "" {
+				return nil, fmt.Errorf("line %d: #EXT-X-MAP without URI", lineNum)
+			}
+			initMap = &HLSMap{URI: resolveURI(base, attrs["URI"]), Key: key}
+			if attrs["BYTERANGE"] != "" {
+				br, err := parseByteRange(attrs["BYTERANGE"])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNum, err)
+				}
+				if br.Offset < 0 {
+					br.Offset = 0
+				}
+				initMap.ByteRange = br
+			}
+
+		case "#EXT-X-KEY":
+			k, err := parseKey(base, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			key = k
+
+		case "#EXT-X-MEDIA-SEQUENCE":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid media sequence: %w", lineNum, err)
+			}
+			playlist.MediaSequence = n
+			sequence = n
+
+		case "#EXT-X-TARGETDURATION":
+			playlist.TargetDuration, _ = strconv.ParseFloat(value, 64)
+
+		case "#EXT-X-ENDLIST":
+			playlist.EndList = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading playlist: %w", err)
+	}
+
+	if !header {
+		return nil, fmt.Errorf("not an M3U8 playlist")
+	}
+
+	if playlist.Master && mediaSeen {
+		return nil, fmt.Errorf("playlist mixes variants and segments")
+	}
+
+	return playlist, nil
+}
+
+// SelectVariant picks a variant for the requested quality. "best", "hd"
+// or an empty string pick the highest rendition, "worst" the lowest,
+// "sd" caps at 480p, and a height such as "720p" picks the best variant
+// no taller than that, or the shortest if all are taller. Variants without
+// a RESOLUTION, such as audio-only ones, are only considered when no
+// variant has one; they are ranked by bandwidth and a height picks the
+// highest.
+func SelectVariant(variants []HLSVariant, quality string) (*HLSVariant, error) {
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("playlist has no variants")
+	}
+
+	// Lowest first, leaving out variants of unknown height if possible
+	var sorted []HLSVariant
+	for _, variant := range variants {
+		if variant.Height > 0 {
+			sorted = append(sorted, variant)
+		}
+	}
+	if len(sorted) == 0 {
+		sorted = append(sorted, variants...)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Height != sorted[j].Height {
+			return sorted[i].Height < sorted[j].Height
+		}
+		return sorted[i].Bandwidth < sorted[j].Bandwidth
+	})
+
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	switch quality {
+	case "", "best", "highest", "high", "hd", "source":
+		return &sorted[len(sorted)-1], nil
+	case "worst", "lowest", "low":
+		return &sorted[0], nil
+	case "sd":
+		quality = "480p"
+	}
+
+	height, err := strconv.Atoi(strings.TrimSuffix(quality, "p"))
+	if err != nil || height <= 0 {
+		return nil, fmt.Errorf("unknown quality: %s", quality)
+	}
+
+	// Only bandwidths to go by
+	if sorted[0].Height == 0 {
+		return &sorted[len(sorted)-1], nil
+	}
+
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if sorted[i].Height <= height {
+			return &sorted[i], nil
+		}
+	}
+
+	// Everything is taller than asked for
+	return &sorted[0], nil
+}
+
+// parseKey parses the attributes of #EXT-X-KEY. METHOD=NONE yields nil.
+func parseKey(base *url.URL, value string) (*HLSKey, error) {
+	attrs := parseAttributes(value)
+
+	method := attrs["METHOD"]
+	if method == "" {
+		return nil, fmt.Errorf("#EXT-X-KEY without METHOD")
+	}
+	if method == HLSMethodNone {
+		return nil, nil
+	}
+
+	if attrs["URI"] == "" {
+		return nil, fmt.Errorf("#EXT-X-KEY without URI")
+	}
+
+	key := &HLSKey{
+		Method: method,
+		URI:    resolveURI(base, attrs["URI"]),
+	}
+
+	if iv := attrs["IV"]; iv != "" {
+		hexIV := strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X")
+		decoded, err := hex.DecodeString(hexIV)
+		if err != nil || len(decoded) > aes.BlockSize {
+			return nil, fmt.Errorf("invalid key IV: %s", iv)
+		}
+		// Left-pad short values, the IV is a 128-bit number
+		key.IV = make([]byte, aes.BlockSize)
+		copy(key.IV[aes.BlockSize-len(decoded):], decoded)
+	}
+
+	return key, nil
+}
+
+// DecryptAES128 decrypts an AES-128 CBC segment and strips its PKCS#7
+// padding
+func DecryptAES128(data, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("ciphertext is not a multiple of the block size")
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid IV length: %d", len(iv))
+	}
+
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plain[len(plain)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("invalid padding, wrong key?")
+	}
+
+	return plain[:len(plain)-padding], nil
+}
+
+// parseByteRange parses "<length>[@<offset>]". A missing offset is
+// returned as -1.
+func parseByteRange(s string) (*HLSByteRange, error) {
+	length, offset, hasOffset := strings.Cut(strings.TrimSpace(s), "@")
+
+	br := &HLSByteRange{Offset: -1}
+
+	var err error
+	if br.Length, err = strconv.ParseInt(length, 10, 64); err != nil || br.Length <= 0 {
+		return nil, fmt.Errorf("invalid byte range: %s", s)
+	}
+	if hasOffset {
+		if br.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil || br.Offset < 0 {
+			return nil, fmt.Errorf("invalid byte range: %s", s)
+		}
+	}
+
+	return br, nil
+}
+
+// parseAttributes parses an attribute list such as
+// BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2"
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+
+	for len(s) > 0 {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		attrs[key] = strings.TrimSpace(value)
+		s = rest
+	}
+
+	return attrs
+}
+
+// resolveURI resolves a playlist reference against the playlist URL
+func resolveURI(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"strings"
+	"testing"
+)
+
+const testMasterPlaylist = `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+360p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2"
+1080p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720
+https://cdn.example.com/720p/index.m3u8
+`
+
+const testMediaPlaylist = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+#EXTINF:6.0,
+#EXT-X-BYTERANGE:1000@720
+media.mp4
+#EXTINF:6.0,
+#EXT-X-BYTERANGE:2000
+media.mp4
+#EXTINF:4.5,
+/other/seg.m4s
+#EXT-X-ENDLIST
+`
+
+func TestParseM3U8Master(t *testing.T) {
+	playlist, err := ParseM3U8(strings.NewReader(testMasterPlaylist), "https://example.com/live/master.m3u8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !playlist.Master || len(playlist.Variants) != 3 {
+		t.Fatalf("Expected master playlist with 3 variants, got master=%v variants=%d", playlist.Master, len(playlist.Variants))
+	}
+
+	first := playlist.Variants[0]
+	if first.URI != "https://example.com/live/360p/index.m3u8" {
+		t.Errorf("Expected resolved variant URI, got %s", first.URI)
+	}
+	if first.Height != 360 || first.Bandwidth != 800000 || first.Codecs != "avc1.4d401e,mp4a.40.2" {
+		t.Errorf("Unexpected variant attributes: %+v", first)
+	}
+
+	tests := map[string]int{
+		"":      1080,
+		"best":  1080,
+		"worst": 360,
+		"720p":  720,
+		"1000":  720,
+		"240p":  360,
+	}
+	for quality, height := range tests {
+		variant, err := SelectVariant(playlist.Variants, quality)
+		if err != nil {
+			t.Errorf("Quality %q: unexpected error: %v", quality, err)
+			continue
+		}
+		if variant.Height != height {
+			t.Errorf("Quality %q: expected %dp, got %dp", quality, height, variant.Height)
+		}
+	}
+
+	if _, err := SelectVariant(playlist.Variants, "ultra"); err == nil {
+		t.Error("Expected error for unknown quality")
+	}
+}
+
+func TestSelectVariantUnknownHeight(t *testing.T) {
+	audio := HLSVariant{URI: "audio", Bandwidth: 128000}
+	low := HLSVariant{URI: "low", Bandwidth: 800000}
+	high := HLSVariant{URI: "high", Bandwidth: 5000000}
+	p720 := HLSVariant{URI: "720p", Bandwidth: 2500000, Height: 720}
+	p1080 := HLSVariant{URI: "1080p", Bandwidth: 5000000, Height: 1080}
+
+	tests := []struct {
+		name     string
+		variants []HLSVariant
+		quality  string
+		want     string
+	}{
+		{"audio-only skipped for a height", []HLSVariant{audio, p1080}, "720p", "1080p"},
+		{"audio-only skipped for worst", []HLSVariant{audio, p720, p1080}, "worst", "720p"},
+		{"shortest known height when all taller", []HLSVariant{audio, p1080, p720}, "480p", "720p"},
+		{"bandwidth only, height", []HLSVariant{high, audio, low}, "720p", "high"},
+		{"bandwidth only, best", []HLSVariant{low, high}, "best", "high"},
+		{"bandwidth only, worst", []HLSVariant{high, audio, low}, "worst", "audio"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variant, err := SelectVariant(tt.variants, tt.quality)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if variant.URI != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, variant.URI)
+			}
+		})
+	}
+}
+
+func TestParseM3U8Media(t *testing.T) {
+	playlist, err := ParseM3U8(strings.NewReader(testMediaPlaylist), "https://example.com/vod/index.m3u8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if playlist.Master || !playlist.EndList || len(playlist.Segments) != 3 {
+		t.Fatalf("Unexpected playlist: master=%v endlist=%v segments=%d", playlist.Master, playlist.EndList, len(playlist.Segments))
+	}
+
+	first, second, third := playlist.Segments[0], playlist.Segments[1], playlist.Segments[2]
+
+	if first.Sequence != 10 || third.Sequence != 12 {
+		t.Errorf("Expected sequences 10..12, got %d..%d", first.Sequence, third.Sequence)
+	}
+
+	if first.Map == nil || first.Map != third.Map {
+		t.Fatal("Expected all segments to share the init segment")
+	}
+	if first.Map.URI != "https://example.com/vod/init.mp4" || first.Map.ByteRange.Header() != "bytes=0-719" {
+		t.Errorf("Unexpected init segment: %s %s", first.Map.URI, first.Map.ByteRange.Header())
+	}
+
+	if first.ByteRange.Header() != "bytes=720-1719" {
+		t.Errorf("Unexpected first range: %s", first.ByteRange.Header())
+	}
+	// No offset continues from the previous range
+	if second.ByteRange.Header() != "bytes=1720-3719" {
+		t.Errorf("Unexpected second range: %s", second.ByteRange.Header())
+	}
+
+	if third.URI != "https://example.com/other/seg.m4s" || third.ByteRange != nil || third.Duration != 4.5 {
+		t.Errorf("Unexpected third segment: %+v", third)
+	}
+}
+
+func TestParseM3U8Invalid(t *testing.T) {
+	if _, err := ParseM3U8(strings.NewReader("<html></html>"), "https://example.com/a.m3u8"); err == nil {
+		t.Error("Expected error for non-playlist input")
+	}
+}
+
+const testEncryptedPlaylist = `#EXTM3U
+#EXT-X-MEDIA-SEQUENCE:7
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin"
+#EXTINF:2.0,
+a.ts
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example.com/k2",IV=0x0102
+#EXTINF:2.0,
+b.ts
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:2.0,
+c.ts
+`
+
+func TestParseM3U8Keys(t *testing.T) {
+	playlist, err := ParseM3U8(strings.NewReader(testEncryptedPlaylist), "https://example.com/live/index.m3u8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	a, b, c := playlist.Segments[0], playlist.Segments[1], playlist.Segments[2]
+
+	if a.Key == nil || a.Key.Method != HLSMethodAES128 || a.Key.URI != "https://example.com/live/key.bin" {
+		t.Fatalf("Unexpected key for first segment: %+v", a.Key)
+	}
+
+	// Without an IV attribute the media sequence number is the IV
+	wantIV := make([]byte, aes.BlockSize)
+	wantIV[15] = 7
+	if !bytes.Equal(a.IV(), wantIV) {
+		t.Errorf("Expected sequence IV %x, got %x", wantIV, a.IV())
+	}
+
+	wantIV = make([]byte, aes.BlockSize)
+	wantIV[14], wantIV[15] = 0x01, 0x02
+	if !bytes.Equal(b.IV(), wantIV) {
+		t.Errorf("Expected explicit IV %x, got %x", wantIV, b.IV())
+	}
+
+	if c.Key != nil {
+		t.Errorf("Expected METHOD=NONE to clear the key, got %+v", c.Key)
+	}
+}
+
+func TestDecryptAES128(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := make([]byte, aes.BlockSize)
+	plain := []byte("segment payload")
+
+	// PKCS#7 pad to a full block
+	padded := append(append([]byte(nil), plain...), 1)
+	block, _ := aes.NewCipher(key)
+	data := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, padded)
+
+	got, err := DecryptAES128(data, key, iv)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("Expected %q, got %q", plain, got)
+	}
+
+	if _, err := DecryptAES128(data, []byte("fedcba9876543210"), iv); err == nil {
+		t.Error("Expected padding error with the wrong key")
+	}
+}
@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,16 +9,15 @@ import (
 	"os"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/rs/zerolog"
-	"golang.org/x/net/proxy"
 )
 
 // HTTPClient represents a configurable HTTP client
 type HTTPClient struct {
-	client    *http.Client
-	transport *http.Transport
-	logger    zerolog.Logger
+	client *http.Client
+	logger zerolog.Logger
 }
 
 // ClientConfig represents HTTP client configuration
@@ -32,42 +31,32 @@ type ClientConfig struct {
 	TLSInsecure     bool
 	MaxRetries      int
 	RetryDelay      time.Duration
+
+	// Transport replaces the client's own transport, e.g. the shared one of
+	// a TransportFactory or one replaying recorded responses in tests. Proxy,
+	// TLS and connection pool settings are then ignored.
+	Transport http.RoundTripper
 }
 
 // NewHTTPClient creates a new HTTP client with the given configuration
 func NewHTTPClient(config ClientConfig) *HTTPClient {
-	// Create transport
-	transport := &http.Transport{
-		MaxIdleConns:        config.MaxIdleConns,
-		IdleConnTimeout:     config.IdleConnTimeout,
-		DisableCompression:  false,
-		DisableKeepAlives:   false,
-		ForceAttemptHTTP2:   true,
-		MaxIdleConnsPerHost: 10,
-	}
-
-	// Configure proxy if provided
-	if config.ProxyURL != "" {
-		proxyURL, err := url.Parse(config.ProxyURL)
-		if err == nil {
-			switch proxyURL.Scheme {
-			case "http", "https":
-				transport.Proxy = http.ProxyURL(proxyURL)
-			case "socks5":
-				dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
-				if err == nil {
-					transport.DialContext = dialer.(proxy.ContextDialer).DialContext
-				}
-			}
+	if config.Transport != nil {
+		return &HTTPClient{
+			client: &http.Client{
+				Transport: config.Transport,
+				Timeout:   config.Timeout,
+			},
+			logger: zerolog.New(os.Stdout).With().Timestamp().Logger(),
 		}
 	}
 
-	// Configure TLS
-	if config.TLSInsecure {
-		transport.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
-	}
+	// Create transport
+	transport := NewTransport(TransportConfig{
+		ProxyURL:        config.ProxyURL,
+		MaxIdleConns:    config.MaxIdleConns,
+		IdleConnTimeout: config.IdleConnTimeout,
+		TLSInsecure:     config.TLSInsecure,
+	})
 
 	// Create client
 	client := &http.Client{
@@ -76,15 +65,14 @@ func NewHTTPClient(config ClientConfig) *HTTPClient {
 	}
 
 	return &HTTPClient{
-		client:    client,
-		transport: transport,
-		logger:    zerolog.New(os.Stdout).With().Timestamp().Logger(),
+		client: client,
+		logger: zerolog.New(os.Stdout).With().Timestamp().Logger(),
 	}
 }
 
 // Get performs a GET request
-func (c *HTTPClient) Get(url string, headers map[string]string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (c *HTTPClient) Get(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -93,8 +81,8 @@ func (c *HTTPClient) Get(url string, headers map[string]string) (*http.Response,
 }
 
 // Post performs a POST request
-func (c *HTTPClient) Post(url, contentType string, body strings.Reader, headers map[string]string) (*http.Response, error) {
-	req, err := http.NewRequest("POST", url, &body)
+func (c *HTTPClient) Post(ctx context.Context, url, contentType string, body strings.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, &body)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -107,13 +95,13 @@ func (c *HTTPClient) Post(url, contentType string, body strings.Reader, headers
 }
 
 // PostJSON performs a POST request with JSON data
-func (c *HTTPClient) PostJSON(url string, data any, headers map[string]string) (*http.Response, error) {
+func (c *HTTPClient) PostJSON(ctx context.Context, url string, data any, headers map[string]string) (*http.Response, error) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling JSON: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -143,15 +131,23 @@ func (c *HTTPClient) Do(req *http.Request, headers map[string]string) (*http.Res
 }
 
 // GetWithRetry performs a GET request with retry logic
-func (c *HTTPClient) GetWithRetry(url string, headers map[string]string, maxRetries int, retryDelay time.Duration) (*http.Response, error) {
+func (c *HTTPClient) GetWithRetry(ctx context.Context, url string, headers map[string]string, maxRetries int, retryDelay time.Duration) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 
 	for i := range maxRetries {
-		resp, err = c.Get(url, headers)
+		resp, err = c.Get(ctx, url, headers)
 		if err == nil && resp.StatusCode < 500 {
 			return resp, nil
 		}
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		// Don't retry once the caller has given up
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 
 		if i < maxRetries-1 {
 			c.logger.Warn().
@@ -161,7 +157,11 @@ func (c *HTTPClient) GetWithRetry(url string, headers map[string]string, maxRetr
 				Err(err).
 				Msg("Request failed, retrying...")
 
-			time.Sleep(retryDelay)
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 	}
 
@@ -169,8 +169,8 @@ func (c *HTTPClient) GetWithRetry(url string, headers map[string]string, maxRetr
 }
 
 // Head performs a HEAD request
-func (c *HTTPClient) Head(url string, headers map[string]string) (*http.Response, error) {
-	req, err := http.NewRequest("HEAD", url, nil)
+func (c *HTTPClient) Head(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -179,8 +179,8 @@ func (c *HTTPClient) Head(url string, headers map[string]string) (*http.Response
 }
 
 // GetFileSize returns the size of a remote file
-func (c *HTTPClient) GetFileSize(url string) (int64, error) {
-	resp, err := c.Head(url, nil)
+func (c *HTTPClient) GetFileSize(ctx context.Context, url string) (int64, error) {
+	resp, err := c.Head(ctx, url, nil)
 	if err != nil {
 		return 0, fmt.Errorf("error getting file size: %w", err)
 	}
@@ -199,8 +199,8 @@ func (c *HTTPClient) GetFileSize(url string) (int64, error) {
 }
 
 // SupportsResume checks if the server supports range requests
-func (c *HTTPClient) SupportsResume(url string) bool {
-	resp, err := c.Head(url, nil)
+func (c *HTTPClient) SupportsResume(ctx context.Context, url string) bool {
+	resp, err := c.Head(ctx, url, nil)
 	if err != nil {
 		return false
 	}
@@ -211,7 +211,7 @@ func (c *HTTPClient) SupportsResume(url string) bool {
 
 // Close closes the HTTP client and cleans up resources
 func (c *HTTPClient) Close() error {
-	c.transport.CloseIdleConnections()
+	c.client.CloseIdleConnections()
 	return nil
 }
 
@@ -272,16 +272,20 @@ func SanitizeFilename(filename string) string {
 		result = strings.ReplaceAll(result, char, "_")
 	}
 
+	// Line breaks and other control characters become spaces
+	result = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, result)
+
 	// Remove leading/trailing whitespace and dots
 	result = strings.TrimSpace(result)
 	result = strings.Trim(result, ".")
 
-	// Limit length
-	if len(result) > 200 {
-		result = result[:200]
-	}
-
-	return result
+	// Limit length without splitting a multi-byte character
+	return truncateUTF8(result, MaxFilenameLength)
 }
 
 // FormatBytes formats bytes to human readable string
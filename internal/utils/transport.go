@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+	"video-downloader/pkg/models"
+)
+
+// TransportConfig represents the connection settings of a transport
+type TransportConfig struct {
+	ProxyURL            string
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	TLSInsecure         bool
+}
+
+// NewTransport creates an HTTP transport with the given configuration
+func NewTransport(config TransportConfig) *http.Transport {
+	if config.MaxIdleConnsPerHost == 0 {
+		config.MaxIdleConnsPerHost = 10
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:        config.MaxIdleConns,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		DisableCompression:  false,
+		DisableKeepAlives:   false,
+		ForceAttemptHTTP2:   true,
+	}
+
+	// Configure proxy if provided
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err == nil {
+			switch proxyURL.Scheme {
+			case "http", "https":
+				transport.Proxy = http.ProxyURL(proxyURL)
+			case "socks5":
+				dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+				if err == nil {
+					transport.DialContext = dialer.(proxy.ContextDialer).DialContext
+				}
+			}
+		}
+	}
+
+	// Configure TLS
+	if config.TLSInsecure {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+
+	return transport
+}
+
+// TransportFactory hands out the transport shared by the network
+// components: extractors, downloaders and the comment extractor. Components
+// built from the same factory share one connection pool, and replacing or
+// wrapping the factory's transport reroutes all of their requests, e.g. to
+// a local test server or through request tracing.
+type TransportFactory struct {
+	mu        sync.Mutex
+	base      http.RoundTripper
+	wrappers  []func(http.RoundTripper) http.RoundTripper
+	transport http.RoundTripper
+}
+
+// NewTransportFactory creates a transport factory from the proxy and HTTP
+// settings of cfg
+func NewTransportFactory(cfg *models.Config) *TransportFactory {
+	return &TransportFactory{
+		base: NewTransport(TransportConfig{
+			ProxyURL:            ProxyURL(cfg),
+			MaxIdleConns:        cfg.HTTP.MaxIdleConns,
+			MaxIdleConnsPerHost: cfg.HTTP.MaxIdleConnsPerHost,
+			IdleConnTimeout:     time.Duration(cfg.HTTP.IdleConnTimeout) * time.Second,
+			TLSInsecure:         cfg.HTTP.TLSInsecure,
+		}),
+	}
+}
+
+// TransportFactoryFor creates a transport factory that hands out rt, such
+// as the client transport of an httptest server
+func TransportFactoryFor(rt http.RoundTripper) *TransportFactory {
+	return &TransportFactory{base: rt}
+}
+
+// Wrap adds a middleware around the factory's transport. Middlewares apply
+// in the order they are added, the last one seeing requests first, and
+// only affect components created afterwards.
+func (f *TransportFactory) Wrap(middleware func(http.RoundTripper) http.RoundTripper) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.wrappers = append(f.wrappers, middleware)
+	f.transport = nil
+}
+
+// Transport returns the shared transport. A nil factory returns nil, which
+// leaves components to build their own.
+func (f *TransportFactory) Transport() http.RoundTripper {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.transport == nil {
+		transport := f.base
+		for _, wrap := range f.wrappers {
+			transport = wrap(transport)
+		}
+		f.transport = transport
+	}
+
+	return f.transport
+}
+
+// ProxyURL returns the proxy URL configured in cfg, or "" if the proxy is
+// disabled
+func ProxyURL(cfg *models.Config) string {
+	if !cfg.Proxy.Enabled {
+		return ""
+	}
+
+	if cfg.Proxy.Username != "" && cfg.Proxy.Password != "" {
+		return fmt.Sprintf("%s://%s:%s@%s:%d",
+			cfg.Proxy.Type,
+			cfg.Proxy.Username,
+			cfg.Proxy.Password,
+			cfg.Proxy.Host,
+			cfg.Proxy.Port,
+		)
+	}
+
+	return fmt.Sprintf("%s://%s:%d",
+		cfg.Proxy.Type,
+		cfg.Proxy.Host,
+		cfg.Proxy.Port,
+	)
+}
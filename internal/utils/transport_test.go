@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportFactoryWrap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	factory := TransportFactoryFor(srv.Client().Transport)
+
+	var seen []string
+	factory.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			seen = append(seen, req.URL.Path)
+			return next.RoundTrip(req)
+		})
+	})
+
+	client := NewHTTPClient(ClientConfig{Transport: factory.Transport()})
+	resp, err := client.Get(context.Background(), srv.URL+"/video", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(seen) != 1 || seen[0] != "/video" {
+		t.Errorf("Middleware saw %v, want [/video]", seen)
+	}
+}
@@ -1,17 +1,23 @@
 package models
 
-import "time"
-
-// PlatformExtractor defines the interface for platform-specific extractors
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// PlatformExtractor defines the interface for platform-specific extractors.
+// Network-bound methods take a context so callers can cancel in-flight
+// requests or bound them with a deadline.
 type PlatformExtractor interface {
 	// ExtractVideoInfo extracts video information from a URL
-	ExtractVideoInfo(url string) (*VideoInfo, error)
+	ExtractVideoInfo(ctx context.Context, url string) (*VideoInfo, error)
 
 	// ExtractAuthorInfo extracts author information
-	ExtractAuthorInfo(authorID string) (*AuthorInfo, error)
+	ExtractAuthorInfo(ctx context.Context, authorID string) (*AuthorInfo, error)
 
 	// ExtractBatch extracts multiple videos from a page
-	ExtractBatch(url string, limit int) ([]*VideoInfo, error)
+	ExtractBatch(ctx context.Context, url string, limit int) ([]*VideoInfo, error)
 
 	// ValidateURL validates if the URL belongs to this platform
 	ValidateURL(url string) bool
@@ -58,19 +64,57 @@ type Storage interface {
 	// GetDownloadTask retrieves a download task
 	GetDownloadTask(id string) (*DownloadTask, error)
 
+	// ListDownloadTasks lists download tasks in any of the given statuses,
+	// oldest first
+	ListDownloadTasks(statuses ...string) ([]*DownloadTask, error)
+
 	// UpdateDownloadProgress updates download progress
 	UpdateDownloadProgress(id string, progress float64) error
 
+	// SaveBatchJob saves a batch job
+	SaveBatchJob(job *BatchJobRecord) error
+
+	// GetBatchJob retrieves a batch job
+	GetBatchJob(id string) (*BatchJobRecord, error)
+
+	// ListBatchJobs lists batch jobs, newest first
+	ListBatchJobs(limit, offset int) ([]*BatchJobRecord, error)
+
 	// SaveAuthorInfo saves author information
 	SaveAuthorInfo(info *AuthorInfo) error
 
 	// GetAuthorInfo retrieves author information
 	GetAuthorInfo(platform Platform, id string) (*AuthorInfo, error)
 
+	// SaveComments inserts new comments and updates the like and reply
+	// counts of those already stored, matched by ID. It returns how many
+	// were new.
+	SaveComments(comments []*CommentRecord) (int, error)
+
+	// ListComments lists stored comments with filters
+	ListComments(filter CommentFilter) ([]*CommentRecord, error)
+
+	// CountComments counts stored comments matching the filter, ignoring
+	// its limit and offset
+	CountComments(filter CommentFilter) (int64, error)
+
+	// SaveSubscription saves an author subscription
+	SaveSubscription(sub *Subscription) error
+
+	// GetSubscription retrieves a subscription, nil if there is none
+	GetSubscription(id string) (*Subscription, error)
+
+	// ListSubscriptions lists subscriptions, oldest first
+	ListSubscriptions() ([]*Subscription, error)
+
+	// DeleteSubscription deletes a subscription
+	DeleteSubscription(id string) error
+
 	// Close closes the storage connection
 	Close() error
 
-	// GetVideosByAuthor retrieves videos by author
+	// GetVideosByAuthor retrieves videos by author, newest first. A limit
+	// of 0 returns all of them.
 	GetVideosByAuthor(authorID string, platform Platform, limit int) ([]*VideoInfo, error)
 
 	// GetStats returns download statistics
@@ -112,6 +156,19 @@ type VideoFilter struct {
 	OrderDesc bool
 }
 
+// CommentFilter defines filters for listing comments
+type CommentFilter struct {
+	VideoID string
+	// ParentID lists the replies to a comment; TopLevel lists comments
+	// that are not replies
+	ParentID  string
+	TopLevel  bool
+	Limit     int
+	Offset    int
+	OrderBy   string
+	OrderDesc bool
+}
+
 // ProgressCallback defines the callback for download progress
 type ProgressCallback func(progress float64, speed string, eta string)
 
@@ -122,4 +179,8 @@ type ExtractorConfig struct {
 	UserAgent  string
 	Cookie     string
 	MaxRetries int
+
+	// Transport, when set, carries the extractor's requests instead of a
+	// transport built from the settings above
+	Transport http.RoundTripper
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -63,15 +64,75 @@ type VideoInfo struct {
 	RetryCount   int    `json:"retry_count" gorm:"default:0"`
 	ErrorMessage string `json:"error_message"`
 
+	// MediaItems lists every file of a multi-file post, such as the images
+	// of an image note, in display order. Single-file posts leave it empty
+	// and use DownloadURL.
+	MediaItems []MediaItem `json:"media_items,omitempty" gorm:"serializer:json;type:text"`
+
+	// Renditions lists the encodings the platform offers for a video, from
+	// which the downloader picks one by quality. Platforms that report a
+	// single URL leave it empty and use DownloadURL.
+	Renditions []Rendition `json:"renditions,omitempty" gorm:"serializer:json;type:text"`
+
+	// MusicID is the ID of the post's soundtrack record. Music holds the
+	// soundtrack as extracted; it is stored as its own MediaTypeAudio
+	// record rather than in this one.
+	MusicID string     `json:"music_id,omitempty" gorm:"index"`
+	Music   *VideoInfo `json:"music,omitempty" gorm:"-"`
+
 	// Additional metadata
 	Metadata    string `json:"metadata" gorm:"type:text"`
 	ExtractFrom string `json:"extract_from"`
+
+	// RawData is the platform payload the info was extracted from. It is
+	// kept in info.json sidecars rather than the database.
+	RawData json.RawMessage `json:"raw_data,omitempty" gorm:"-"`
+}
+
+// Rendition is one encoding of a video
+type Rendition struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// Bitrate is in bits per second
+	Bitrate int `json:"bitrate,omitempty"`
+	// Codec is the video codec as reported, e.g. "h264", "hevc" or "avc1.64001f"
+	Codec     string `json:"codec,omitempty"`
+	Format    string `json:"format,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Watermark bool   `json:"watermark,omitempty"`
+	// Label is the platform's own name for the rendition
+	Label string `json:"label,omitempty"`
+}
+
+// MusicRecordID returns the ID a soundtrack with the platform's sound ID
+// is stored under, so that it cannot collide with a video ID
+func MusicRecordID(soundID string) string {
+	return "music_" + soundID
+}
+
+// MediaItem is one file of a multi-file post
+type MediaItem struct {
+	Index     int       `json:"index"`
+	MediaType MediaType `json:"media_type"`
+	URL       string    `json:"url"`
+	// FallbackURL is tried when URL fails, e.g. the display-size image
+	// when the original cannot be fetched
+	FallbackURL string `json:"fallback_url,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+
+	// Set once downloaded
+	FilePath string `json:"file_path,omitempty"`
+	FileSize int64  `json:"file_size,omitempty"`
 }
 
 // DownloadTask represents a download task
 type DownloadTask struct {
 	ID          string     `json:"id" gorm:"primaryKey"`
 	VideoID     string     `json:"video_id" gorm:"index"`
+	BatchID     string     `json:"batch_id" gorm:"index"`
 	URL         string     `json:"url"`
 	Platform    Platform   `json:"platform"`
 	Status      string     `json:"status" gorm:"default:pending"`
@@ -79,6 +140,8 @@ type DownloadTask struct {
 	Speed       string     `json:"speed"`
 	ETA         string     `json:"eta"`
 	FilePath    string     `json:"file_path"`
+	DownloadURL string     `json:"download_url"`
+	Options     string     `json:"options" gorm:"type:text"`
 	Error       string     `json:"error"`
 	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
@@ -86,6 +149,26 @@ type DownloadTask struct {
 	CompletedAt *time.Time `json:"completed_at"`
 }
 
+// BatchJobRecord is the persisted form of a batch download job. URLs,
+// config and per-URL results are stored as JSON.
+type BatchJobRecord struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status" gorm:"index"`
+	URLs        string     `json:"urls" gorm:"type:text"`
+	Config      string     `json:"config" gorm:"type:text"`
+	Results     string     `json:"results" gorm:"type:text"`
+	Total       int        `json:"total"`
+	Completed   int        `json:"completed"`
+	Failed      int        `json:"failed"`
+	Skipped     int        `json:"skipped"`
+	Error       string     `json:"error"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
 // AuthorInfo represents author information
 type AuthorInfo struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
@@ -102,6 +185,108 @@ type AuthorInfo struct {
 	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
+// CommentRecord is a stored comment or reply on the video with ID VideoID.
+// Replies point at their top-level comment through ParentID. CreatedAt is
+// when the comment was posted on the platform.
+type CommentRecord struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	VideoID      string    `json:"video_id" gorm:"index"`
+	Platform     Platform  `json:"platform"`
+	ParentID     string    `json:"parent_id" gorm:"index"`
+	Level        int       `json:"level"`
+	AuthorID     string    `json:"author_id"`
+	AuthorName   string    `json:"author_name"`
+	AuthorAvatar string    `json:"author_avatar"`
+	Content      string    `json:"content" gorm:"type:text"`
+	LikeCount    int       `json:"like_count"`
+	ReplyCount   int       `json:"reply_count"`
+	Mentions     []string  `json:"mentions" gorm:"serializer:json;type:text"`
+	IPLocation   string    `json:"ip_location"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime:false"`
+	CollectedAt  time.Time `json:"collected_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// CommentReport summarises the comments and replies of a video. The term
+// lists are ordered by count, most frequent first.
+type CommentReport struct {
+	VideoID       string    `json:"video_id"`
+	Platform      Platform  `json:"platform"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	TotalComments int       `json:"total_comments"`
+	TotalReplies  int       `json:"total_replies"`
+	TotalLikes    int       `json:"total_likes"`
+	UniqueAuthors int       `json:"unique_authors"`
+	AvgLikes      float64   `json:"avg_likes"`
+
+	Keywords    []TermCount `json:"keywords"`
+	Mentions    []TermCount `json:"mentions"`
+	Emoji       []TermCount `json:"emoji"`
+	IPLocations []TermCount `json:"ip_locations"`
+
+	// Volume counts comments per VolumeInterval ("hour" or "day"),
+	// oldest first
+	VolumeInterval string         `json:"volume_interval"`
+	Volume         []VolumeBucket `json:"volume"`
+
+	Sentiment SentimentSummary `json:"sentiment"`
+}
+
+// TermCount is how often a term occurs in a CommentReport
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// VolumeBucket is the number of comments posted in the interval starting
+// at Start
+type VolumeBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// SentimentSummary classifies comments as positive, negative or neutral.
+// Score is the mean comment score, from -1 (all negative) to 1.
+type SentimentSummary struct {
+	Score    float64 `json:"score"`
+	Positive int     `json:"positive"`
+	Negative int     `json:"negative"`
+	Neutral  int     `json:"neutral"`
+}
+
+// Subscription is an author whose new posts are downloaded on a schedule.
+// Every Interval minutes the profile at URL is listed and the posts not
+// downloaded yet are downloaded with the subscription's options. LastSeenAt
+// is the publish time of the newest post up to which every listed post has
+// been downloaded; older posts are never downloaded by a later sync.
+type Subscription struct {
+	ID         string   `json:"id" gorm:"primaryKey"`
+	Platform   Platform `json:"platform" gorm:"uniqueIndex:idx_subscription_author"`
+	AuthorID   string   `json:"author_id" gorm:"uniqueIndex:idx_subscription_author"`
+	AuthorName string   `json:"author_name"`
+	URL        string   `json:"url"`
+	Interval   int      `json:"interval"`
+	Limit      int      `json:"limit"`
+	Enabled    bool     `json:"enabled"`
+
+	// Download options, as for a batch job
+	OutputPath string `json:"output_path,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Quality    string `json:"quality,omitempty"`
+	Music      string `json:"music,omitempty"`
+	FileNaming string `json:"file_naming,omitempty"`
+	Metadata   bool   `json:"metadata,omitempty"`
+	Sidecars   bool   `json:"sidecars,omitempty"`
+
+	LastSeenAt  *time.Time `json:"last_seen_at"`
+	LastSyncAt  *time.Time `json:"last_sync_at"`
+	NextSyncAt  time.Time  `json:"next_sync_at" gorm:"index"`
+	LastBatchID string     `json:"last_batch_id,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
 // Config represents the application configuration
 type Config struct {
 	Server struct {
@@ -114,13 +299,19 @@ type Config struct {
 	Download struct {
 		MaxWorkers   int    `mapstructure:"max_workers" yaml:"max_workers"`
 		ChunkSize    int    `mapstructure:"chunk_size" yaml:"chunk_size"`
+		Connections  int    `mapstructure:"connections" yaml:"connections"`
 		Timeout      int    `mapstructure:"timeout" yaml:"timeout"`
 		RetryCount   int    `mapstructure:"retry_count" yaml:"retry_count"`
 		SavePath     string `mapstructure:"save_path" yaml:"save_path"`
 		CreateFolder bool   `mapstructure:"create_folder" yaml:"create_folder"`
 		FileNaming   string `mapstructure:"file_naming" yaml:"file_naming"`
+		ResumeQueue  bool   `mapstructure:"resume_queue" yaml:"resume_queue"`
 	} `mapstructure:"download" yaml:"download"`
 
+	FFmpeg struct {
+		Path string `mapstructure:"path" yaml:"path"`
+	} `mapstructure:"ffmpeg" yaml:"ffmpeg"`
+
 	Database struct {
 		Type     string `mapstructure:"type" yaml:"type"`
 		Path     string `mapstructure:"path" yaml:"path"`
@@ -142,24 +333,34 @@ type Config struct {
 		Password string `mapstructure:"password" yaml:"password"`
 	} `mapstructure:"proxy" yaml:"proxy"`
 
+	HTTP struct {
+		MaxIdleConns        int  `mapstructure:"max_idle_conns" yaml:"max_idle_conns"`
+		MaxIdleConnsPerHost int  `mapstructure:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host"`
+		IdleConnTimeout     int  `mapstructure:"idle_conn_timeout" yaml:"idle_conn_timeout"`
+		TLSInsecure         bool `mapstructure:"tls_insecure" yaml:"tls_insecure"`
+	} `mapstructure:"http" yaml:"http"`
+
 	Platforms struct {
 		TikTok struct {
-			Enabled   bool   `mapstructure:"enabled" yaml:"enabled"`
-			APIKey    string `mapstructure:"api_key" yaml:"api_key"`
-			Cookie    string `mapstructure:"cookie" yaml:"cookie"`
-			UserAgent string `mapstructure:"user_agent" yaml:"user_agent"`
+			Enabled       bool   `mapstructure:"enabled" yaml:"enabled"`
+			APIKey        string `mapstructure:"api_key" yaml:"api_key"`
+			Cookie        string `mapstructure:"cookie" yaml:"cookie"`
+			UserAgent     string `mapstructure:"user_agent" yaml:"user_agent"`
+			MaxConcurrent int    `mapstructure:"max_concurrent" yaml:"max_concurrent"`
 		} `mapstructure:"tiktok" yaml:"tiktok"`
 
 		XHS struct {
-			Enabled   bool   `mapstructure:"enabled" yaml:"enabled"`
-			Cookie    string `mapstructure:"cookie" yaml:"cookie"`
-			UserAgent string `mapstructure:"user_agent" yaml:"user_agent"`
+			Enabled       bool   `mapstructure:"enabled" yaml:"enabled"`
+			Cookie        string `mapstructure:"cookie" yaml:"cookie"`
+			UserAgent     string `mapstructure:"user_agent" yaml:"user_agent"`
+			MaxConcurrent int    `mapstructure:"max_concurrent" yaml:"max_concurrent"`
 		} `mapstructure:"xhs" yaml:"xhs"`
 
 		Kuaishou struct {
-			Enabled   bool   `mapstructure:"enabled" yaml:"enabled"`
-			Cookie    string `mapstructure:"cookie" yaml:"cookie"`
-			UserAgent string `mapstructure:"user_agent" yaml:"user_agent"`
+			Enabled       bool   `mapstructure:"enabled" yaml:"enabled"`
+			Cookie        string `mapstructure:"cookie" yaml:"cookie"`
+			UserAgent     string `mapstructure:"user_agent" yaml:"user_agent"`
+			MaxConcurrent int    `mapstructure:"max_concurrent" yaml:"max_concurrent"`
 		} `mapstructure:"kuaishou" yaml:"kuaishou"`
 	} `mapstructure:"platforms" yaml:"platforms"`
 
@@ -178,6 +379,11 @@ type Config struct {
 		Adaptive          bool     `mapstructure:"adaptive" yaml:"adaptive"`
 		WhitelistedIPs    []string `mapstructure:"whitelisted_ips" yaml:"whitelisted_ips"`
 	} `mapstructure:"rate_limit" yaml:"rate_limit"`
+
+	Subscriptions struct {
+		Enabled       bool `mapstructure:"enabled" yaml:"enabled"`
+		CheckInterval int  `mapstructure:"check_interval" yaml:"check_interval"`
+	} `mapstructure:"subscriptions" yaml:"subscriptions"`
 }
 
 // Stats represents download statistics